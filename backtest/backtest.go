@@ -0,0 +1,500 @@
+// Package backtest replays historical bars or ticks through a strategy and simulates order execution
+package backtest
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/quote"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// Bar is a candlestick of a symbol
+type Bar struct {
+	Symbol string
+	Time   time.Time
+	Open   decimal.Decimal
+	High   decimal.Decimal
+	Low    decimal.Decimal
+	Close  decimal.Decimal
+	Volume int64
+}
+
+// Tick is a recorded last done price of a symbol
+type Tick struct {
+	Symbol string
+	Time   time.Time
+	Price  decimal.Decimal
+	Volume int64
+}
+
+func decOrZero(d *decimal.Decimal) decimal.Decimal {
+	if d == nil {
+		return decimal.Zero
+	}
+	return *d
+}
+
+// BarsFromCandlesticks converts candlesticks of HistoryCandlesticksByDate or HistoryCandlesticksByOffset to bars
+func BarsFromCandlesticks(symbol string, sticks []*quote.Candlestick) []Bar {
+	bars := make([]Bar, 0, len(sticks))
+	for _, s := range sticks {
+		bars = append(bars, Bar{
+			Symbol: symbol,
+			Time:   time.Unix(s.Timestamp, 0),
+			Open:   decOrZero(s.Open),
+			High:   decOrZero(s.High),
+			Low:    decOrZero(s.Low),
+			Close:  decOrZero(s.Close),
+			Volume: s.Volume,
+		})
+	}
+	return bars
+}
+
+// Strategy receives market data and order updates. Orders submitted in a callback are matched from the next bar or tick.
+type Strategy interface {
+	OnBar(ctx *Context, bar Bar)
+	OnQuote(ctx *Context, tick Tick)
+	OnOrderUpdate(ctx *Context, order Order)
+}
+
+// BaseStrategy implements Strategy with no-op callbacks, embed it to implement only some of them
+type BaseStrategy struct{}
+
+// OnBar implements Strategy
+func (BaseStrategy) OnBar(ctx *Context, bar Bar) {}
+
+// OnQuote implements Strategy
+func (BaseStrategy) OnQuote(ctx *Context, tick Tick) {}
+
+// OnOrderUpdate implements Strategy
+func (BaseStrategy) OnOrderUpdate(ctx *Context, order Order) {}
+
+// SymbolRule is the trading rule of a symbol
+type SymbolRule struct {
+	// LotSize of order quantity, ODD orders are exempted. Default is 1.
+	LotSize uint64
+	// TickSize of order prices and fill prices. Default is 0.01.
+	TickSize decimal.Decimal
+}
+
+// FeeFunc returns the fee of a fill
+type FeeFunc func(symbol string, side trade.OrderSide, quantity uint64, price decimal.Decimal) decimal.Decimal
+
+// Config of a backtest. All symbols are assumed to trade in the same currency.
+type Config struct {
+	InitialCash decimal.Decimal
+	// SlippageBps makes fills worse than the reference price, limit prices are never crossed
+	SlippageBps decimal.Decimal
+	Fee         FeeFunc
+	// Rules of symbols, DefaultRule is used for symbols not in it
+	Rules       map[string]SymbolRule
+	DefaultRule SymbolRule
+	// AllowShort allows selling more than the position
+	AllowShort bool
+	// AllowMargin allows buying with negative cash
+	AllowMargin bool
+}
+
+func (c *Config) rule(symbol string) SymbolRule {
+	r, ok := c.Rules[symbol]
+	if !ok {
+		r = c.DefaultRule
+	}
+	if r.LotSize == 0 {
+		r.LotSize = 1
+	}
+	if !r.TickSize.IsPositive() {
+		r.TickSize = decimal.New(1, -2)
+	}
+	return r
+}
+
+// Order is a simulated order
+type Order struct {
+	Id               string
+	Params           trade.SubmitOrder
+	Status           trade.OrderStatus
+	ExecutedQuantity uint64
+	ExecutedPrice    decimal.Decimal // average
+	Fees             decimal.Decimal
+	Msg              string
+	SubmittedAt      time.Time
+	UpdatedAt        time.Time
+	TriggeredAt      time.Time
+	// mark is the high (sell) or low (buy) water mark of trailing orders
+	mark decimal.Decimal
+}
+
+// Fill is an execution
+type Fill struct {
+	OrderId  string
+	Symbol   string
+	Side     trade.OrderSide
+	Time     time.Time
+	Quantity uint64
+	Price    decimal.Decimal
+	Fee      decimal.Decimal
+	// Realized is the P&L of the closed quantity by average cost, fees excluded
+	Realized decimal.Decimal
+	// Closed reports whether the fill reduced a position
+	Closed bool
+}
+
+// Position of a symbol, Quantity is negative for short positions
+type Position struct {
+	Symbol    string
+	Quantity  int64
+	CostPrice decimal.Decimal
+	LastPrice decimal.Decimal
+}
+
+// MarketValue returns the last price * quantity
+func (p Position) MarketValue() decimal.Decimal {
+	return p.LastPrice.Mul(decimal.NewFromInt(p.Quantity))
+}
+
+// EquityPoint is a point of the equity curve
+type EquityPoint struct {
+	Time        time.Time
+	Cash        decimal.Decimal
+	MarketValue decimal.Decimal
+	Equity      decimal.Decimal
+}
+
+// Result of a backtest
+type Result struct {
+	Equity []EquityPoint
+	Fills  []Fill
+	Orders []Order
+	Stats  Stats
+}
+
+// Engine runs a backtest
+//
+// Example:
+//
+//	sticks, err := qctx.HistoryCandlesticksByDate(ctx, "700.HK", quote.PeriodDay, quote.AdjustTypeForward, &start, &end)
+//	engine := backtest.New(backtest.Config{
+//		InitialCash: decimal.NewFromInt(1000000),
+//		Rules:       map[string]backtest.SymbolRule{"700.HK": {LotSize: 100, TickSize: decimal.NewFromFloat(0.2)}},
+//	}, &myStrategy{})
+//	result, err := engine.RunBars(backtest.BarsFromCandlesticks("700.HK", sticks))
+//	fmt.Println(result.Stats.TotalReturn, result.Stats.MaxDrawdown)
+type Engine struct {
+	cfg       Config
+	strategy  Strategy
+	ctx       *Context
+	now       time.Time
+	seq       int
+	cash      decimal.Decimal
+	orders    []*Order
+	positions map[string]*Position
+	fills     []Fill
+	equity    []EquityPoint
+	updates   []*Order
+}
+
+// New returns an Engine
+func New(cfg Config, strategy Strategy) *Engine {
+	e := &Engine{
+		cfg:       cfg,
+		strategy:  strategy,
+		cash:      cfg.InitialCash,
+		positions: make(map[string]*Position),
+	}
+	e.ctx = &Context{e: e}
+	return e
+}
+
+// RunBars replays bars sorted by time, bars of the same time are one step
+func (e *Engine) RunBars(bars []Bar) (*Result, error) {
+	bars = append([]Bar(nil), bars...)
+	sort.SliceStable(bars, func(i, j int) bool { return bars[i].Time.Before(bars[j].Time) })
+	for i := 0; i < len(bars); {
+		j := i
+		for j < len(bars) && bars[j].Time.Equal(bars[i].Time) {
+			j++
+		}
+		step := bars[i:j]
+		e.advance(step[0].Time)
+		for _, bar := range step {
+			if err := e.validateBar(bar); err != nil {
+				return nil, err
+			}
+			e.matchBar(bar)
+			e.position(bar.Symbol).LastPrice = bar.Close
+		}
+		e.flush()
+		for _, bar := range step {
+			e.strategy.OnBar(e.ctx, bar)
+			e.flush()
+		}
+		e.record()
+		i = j
+	}
+	return e.result(), nil
+}
+
+// RunTicks replays ticks sorted by time
+func (e *Engine) RunTicks(ticks []Tick) (*Result, error) {
+	ticks = append([]Tick(nil), ticks...)
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+	for _, tick := range ticks {
+		if !tick.Price.IsPositive() {
+			return nil, errors.Errorf("invalid price %s of tick %s at %s", tick.Price, tick.Symbol, tick.Time)
+		}
+		e.advance(tick.Time)
+		// a tick is a bar of one price
+		e.matchBar(Bar{Symbol: tick.Symbol, Time: tick.Time, Open: tick.Price, High: tick.Price, Low: tick.Price, Close: tick.Price})
+		e.position(tick.Symbol).LastPrice = tick.Price
+		e.flush()
+		e.strategy.OnQuote(e.ctx, tick)
+		e.flush()
+		e.record()
+	}
+	return e.result(), nil
+}
+
+func (e *Engine) validateBar(bar Bar) error {
+	if !bar.Low.IsPositive() || bar.High.LessThan(bar.Low) || bar.Open.LessThan(bar.Low) || bar.Open.GreaterThan(bar.High) ||
+		bar.Close.LessThan(bar.Low) || bar.Close.GreaterThan(bar.High) {
+		return errors.Errorf("invalid bar of %s at %s", bar.Symbol, bar.Time)
+	}
+	return nil
+}
+
+// advance moves the clock and expires orders at the change of date.
+// Orders submitted in the last step before the change are kept for one more step,
+// so Day orders submitted on the close of a daily bar work on the next bar.
+func (e *Engine) advance(t time.Time) {
+	prev := e.now
+	e.now = t
+	if prev.IsZero() {
+		return
+	}
+	py, pm, pd := prev.Date()
+	y, m, d := t.Date()
+	if py == y && pm == m && pd == d {
+		return
+	}
+	for _, o := range e.orders {
+		if !o.Status.IsOpen() || !o.SubmittedAt.Before(prev) {
+			continue
+		}
+		expire := o.Params.TimeInForce == trade.TimeTypeDay || o.Params.TimeInForce == ""
+		if o.Params.TimeInForce == trade.TimeTypeGTD && o.Params.ExpireDate != nil {
+			ey, em, ed := o.Params.ExpireDate.Date()
+			expire = time.Date(y, m, d, 0, 0, 0, 0, time.UTC).After(time.Date(ey, em, ed, 0, 0, 0, 0, time.UTC))
+		}
+		if expire {
+			e.update(o, trade.OrderExpiredStatus, "")
+		}
+	}
+	e.flush()
+}
+
+func (e *Engine) position(symbol string) *Position {
+	p, ok := e.positions[symbol]
+	if !ok {
+		p = &Position{Symbol: symbol}
+		e.positions[symbol] = p
+	}
+	return p
+}
+
+func (e *Engine) update(o *Order, status trade.OrderStatus, msg string) {
+	o.Status = status
+	o.Msg = msg
+	o.UpdatedAt = e.now
+	e.updates = append(e.updates, o)
+}
+
+// flush delivers queued order updates, updates caused by callbacks are delivered in the same flush
+func (e *Engine) flush() {
+	for len(e.updates) > 0 {
+		o := e.updates[0]
+		e.updates = e.updates[1:]
+		e.strategy.OnOrderUpdate(e.ctx, *o)
+	}
+}
+
+func (e *Engine) marketValue() decimal.Decimal {
+	mv := decimal.Zero
+	for _, p := range e.positions {
+		mv = mv.Add(p.MarketValue())
+	}
+	return mv
+}
+
+func (e *Engine) record() {
+	mv := e.marketValue()
+	e.equity = append(e.equity, EquityPoint{Time: e.now, Cash: e.cash, MarketValue: mv, Equity: e.cash.Add(mv)})
+}
+
+func (e *Engine) result() *Result {
+	r := &Result{
+		Equity: e.equity,
+		Fills:  e.fills,
+	}
+	for _, o := range e.orders {
+		r.Orders = append(r.Orders, *o)
+	}
+	r.Stats = computeStats(e.cfg.InitialCash, e.equity, e.fills)
+	return r
+}
+
+// Context is passed to strategy callbacks to trade and read the simulated account
+type Context struct {
+	e *Engine
+}
+
+// Now returns the time of the current bar or tick
+func (c *Context) Now() time.Time {
+	return c.e.now
+}
+
+// Cash returns the cash
+func (c *Context) Cash() decimal.Decimal {
+	return c.e.cash
+}
+
+// Equity returns cash plus market value of positions
+func (c *Context) Equity() decimal.Decimal {
+	return c.e.cash.Add(c.e.marketValue())
+}
+
+// Position returns the position of symbol
+func (c *Context) Position(symbol string) Position {
+	if p, ok := c.e.positions[symbol]; ok {
+		return *p
+	}
+	return Position{Symbol: symbol}
+}
+
+// Order returns the order of id
+func (c *Context) Order(orderId string) (Order, bool) {
+	for _, o := range c.e.orders {
+		if o.Id == orderId {
+			return *o, true
+		}
+	}
+	return Order{}, false
+}
+
+// OpenOrders returns open orders
+func (c *Context) OpenOrders() []Order {
+	var list []Order
+	for _, o := range c.e.orders {
+		if o.Status.IsOpen() {
+			list = append(list, *o)
+		}
+	}
+	return list
+}
+
+// SubmitOrder submits an order which is matched from the next bar or tick.
+// It returns an error if the order breaks validation, lot size or tick size rules.
+func (c *Context) SubmitOrder(params *trade.SubmitOrder) (orderId string, err error) {
+	e := c.e
+	if err = params.Validate(); err != nil {
+		return
+	}
+	if err = e.checkRules(params.Symbol, params.OrderType, params.SubmittedQuantity,
+		params.SubmittedPrice, params.TriggerPrice, params.TrailingAmount, params.LimitOffset); err != nil {
+		return
+	}
+	if !supported(params.OrderType) {
+		return "", errors.Errorf("unsupported order type %s", params.OrderType)
+	}
+	e.seq++
+	o := &Order{
+		Id:          fmt.Sprintf("BT%06d", e.seq),
+		Params:      *params,
+		SubmittedAt: e.now,
+	}
+	e.orders = append(e.orders, o)
+	e.update(o, trade.OrderNewStatus, "")
+	return o.Id, nil
+}
+
+// ReplaceOrder replaces quantity and prices of an open order, zero prices are unchanged
+func (c *Context) ReplaceOrder(params *trade.ReplaceOrder) error {
+	e := c.e
+	o := e.find(params.OrderId)
+	if o == nil {
+		return errors.Errorf("order %s not found", params.OrderId)
+	}
+	if !o.Status.IsOpen() {
+		return errors.Errorf("order %s is %s", o.Id, o.Status)
+	}
+	if err := params.ValidateFor(o.Params.OrderType); err != nil {
+		return err
+	}
+	if params.Quantity <= o.ExecutedQuantity {
+		return errors.Errorf("quantity %d is not more than executed quantity %d", params.Quantity, o.ExecutedQuantity)
+	}
+	if err := e.checkRules(o.Params.Symbol, o.Params.OrderType, params.Quantity,
+		params.Price, params.TriggerPrice, params.TrailingAmount, params.LimitOffset); err != nil {
+		return err
+	}
+	o.Params.SubmittedQuantity = params.Quantity
+	for _, v := range []struct {
+		dst *decimal.Decimal
+		src decimal.Decimal
+	}{
+		{&o.Params.SubmittedPrice, params.Price},
+		{&o.Params.TriggerPrice, params.TriggerPrice},
+		{&o.Params.LimitOffset, params.LimitOffset},
+		{&o.Params.TrailingAmount, params.TrailingAmount},
+		{&o.Params.TrailingPercent, params.TrailingPercent},
+	} {
+		if !v.src.IsZero() {
+			*v.dst = v.src
+		}
+	}
+	e.update(o, trade.OrderReplacedStatus, "")
+	return nil
+}
+
+// CancelOrder cancels an open order
+func (c *Context) CancelOrder(orderId string) error {
+	e := c.e
+	o := e.find(orderId)
+	if o == nil {
+		return errors.Errorf("order %s not found", orderId)
+	}
+	if !o.Status.IsOpen() {
+		return errors.Errorf("order %s is %s", o.Id, o.Status)
+	}
+	e.update(o, trade.OrderCanceledStatus, "")
+	return nil
+}
+
+func (e *Engine) find(orderId string) *Order {
+	for _, o := range e.orders {
+		if o.Id == orderId {
+			return o
+		}
+	}
+	return nil
+}
+
+// checkRules checks lot size and tick size of an order
+func (e *Engine) checkRules(symbol string, orderType trade.OrderType, qty uint64, prices ...decimal.Decimal) error {
+	rule := e.cfg.rule(symbol)
+	if orderType != trade.OrderTypeODD && qty%rule.LotSize != 0 {
+		return errors.Errorf("quantity %d of %s is not a multiple of lot size %d", qty, symbol, rule.LotSize)
+	}
+	for _, p := range prices {
+		if !p.IsZero() && !p.Mod(rule.TickSize).IsZero() {
+			return errors.Errorf("price %s of %s is not a multiple of tick size %s", p, symbol, rule.TickSize)
+		}
+	}
+	return nil
+}
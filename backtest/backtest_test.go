@@ -0,0 +1,230 @@
+package backtest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/backtest"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+func bar(day int, open, high, low, close float64) backtest.Bar {
+	return backtest.Bar{
+		Symbol: "700.HK",
+		Time:   time.Date(2024, 1, day, 8, 0, 0, 0, time.UTC),
+		Open:   decimal.NewFromFloat(open),
+		High:   decimal.NewFromFloat(high),
+		Low:    decimal.NewFromFloat(low),
+		Close:  decimal.NewFromFloat(close),
+	}
+}
+
+// buys on the first bar and protects the position by a trailing stop after filled
+type trailStrategy struct {
+	backtest.BaseStrategy
+	t       *testing.T
+	updates []trade.OrderStatus
+}
+
+func (s *trailStrategy) OnBar(ctx *backtest.Context, b backtest.Bar) {
+	if len(ctx.OpenOrders()) > 0 || ctx.Position(b.Symbol).Quantity != 0 || len(s.updates) > 0 {
+		return
+	}
+	_, err := ctx.SubmitOrder(&trade.SubmitOrder{
+		Symbol: b.Symbol, OrderType: trade.OrderTypeMO, Side: trade.OrderSideBuy, SubmittedQuantity: 150, TimeInForce: trade.TimeTypeDay,
+	})
+	assert.Error(s.t, err) // breaks lot size
+	_, err = ctx.SubmitOrder(&trade.SubmitOrder{
+		Symbol: b.Symbol, OrderType: trade.OrderTypeMO, Side: trade.OrderSideBuy, SubmittedQuantity: 100, TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(s.t, err)
+}
+
+func (s *trailStrategy) OnOrderUpdate(ctx *backtest.Context, o backtest.Order) {
+	s.updates = append(s.updates, o.Status)
+	if o.Params.Side == trade.OrderSideBuy && o.Status == trade.OrderFilledStatus {
+		_, err := ctx.SubmitOrder(&trade.SubmitOrder{
+			Symbol: o.Params.Symbol, OrderType: trade.OrderTypeTSMAMT, Side: trade.OrderSideSell, SubmittedQuantity: 100,
+			TrailingAmount: decimal.NewFromInt(10), TimeInForce: trade.TimeTypeGTC,
+		})
+		assert.NoError(s.t, err)
+	}
+}
+
+func TestEngine(t *testing.T) {
+	s := &trailStrategy{t: t}
+	engine := backtest.New(backtest.Config{
+		InitialCash: decimal.NewFromInt(100000),
+		Rules:       map[string]backtest.SymbolRule{"700.HK": {LotSize: 100, TickSize: decimal.NewFromFloat(0.2)}},
+		Fee: func(symbol string, side trade.OrderSide, qty uint64, price decimal.Decimal) decimal.Decimal {
+			return decimal.NewFromInt(10)
+		},
+	}, s)
+	result, err := engine.RunBars([]backtest.Bar{
+		bar(2, 300, 305, 298, 302),
+		bar(3, 301, 310, 300, 308), // buy at 301
+		bar(4, 309, 330, 308, 325), // mark 330 after this bar
+		bar(5, 322, 324, 312, 315), // stop 330 - 10 is touched
+		bar(8, 316, 318, 310, 311),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result.Fills))
+	assert.Equal(t, "301", result.Fills[0].Price.String())
+	assert.Equal(t, "320", result.Fills[1].Price.String())
+	assert.Equal(t, "1900", result.Fills[1].Realized.String())
+	assert.Equal(t, 5, len(result.Equity))
+
+	stats := result.Stats
+	assert.Equal(t, "101880", stats.EndEquity.String())
+	assert.Equal(t, "0.0188", stats.TotalReturn.String())
+	assert.Equal(t, 1, stats.Wins)
+	assert.Equal(t, "20", stats.Fees.String())
+	assert.Equal(t, true, stats.MaxDrawdown.IsPositive())
+	assert.Equal(t, []trade.OrderStatus{
+		trade.OrderNewStatus, trade.OrderFilledStatus, trade.OrderNewStatus, trade.OrderFilledStatus,
+	}, s.updates)
+}
+
+type limitStrategy struct {
+	backtest.BaseStrategy
+	orderId string
+}
+
+func (s *limitStrategy) OnQuote(ctx *backtest.Context, tick backtest.Tick) {
+	if s.orderId == "" {
+		s.orderId, _ = ctx.SubmitOrder(&trade.SubmitOrder{
+			Symbol: tick.Symbol, OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy, SubmittedQuantity: 10,
+			SubmittedPrice: decimal.NewFromInt(99), TimeInForce: trade.TimeTypeDay,
+		})
+	}
+}
+
+func TestTicksAndExpiry(t *testing.T) {
+	s := &limitStrategy{}
+	engine := backtest.New(backtest.Config{InitialCash: decimal.NewFromInt(10000)}, s)
+	day := time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC)
+	result, err := engine.RunTicks([]backtest.Tick{
+		{Symbol: "AAPL.US", Time: day, Price: decimal.NewFromInt(100)},
+		{Symbol: "AAPL.US", Time: day.Add(time.Minute), Price: decimal.NewFromInt(100)},
+		{Symbol: "AAPL.US", Time: day.AddDate(0, 0, 1), Price: decimal.NewFromInt(98)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(result.Fills))
+	assert.Equal(t, trade.OrderExpiredStatus, result.Orders[0].Status)
+}
+
+// submits orders on the first bar
+type ordersStrategy struct {
+	backtest.BaseStrategy
+	t      *testing.T
+	orders []*trade.SubmitOrder
+	done   bool
+}
+
+func (s *ordersStrategy) OnBar(ctx *backtest.Context, b backtest.Bar) {
+	if s.done {
+		return
+	}
+	s.done = true
+	for _, o := range s.orders {
+		_, err := ctx.SubmitOrder(o)
+		assert.NoError(s.t, err)
+	}
+}
+
+func runOrders(t *testing.T, orders []*trade.SubmitOrder, bars ...backtest.Bar) *backtest.Result {
+	engine := backtest.New(backtest.Config{
+		InitialCash: decimal.NewFromInt(1000000),
+		Rules:       map[string]backtest.SymbolRule{"700.HK": {LotSize: 100, TickSize: decimal.NewFromFloat(0.2)}},
+		AllowShort:  true,
+	}, &ordersStrategy{t: t, orders: orders})
+	result, err := engine.RunBars(bars)
+	assert.NoError(t, err)
+	assert.Equal(t, len(orders), len(result.Orders))
+	return result
+}
+
+func day(d int) time.Time {
+	return time.Date(2024, 1, d, 8, 0, 0, 0, time.UTC)
+}
+
+func TestTouchedOrders(t *testing.T) {
+	order := func(orderType trade.OrderType, side trade.OrderSide, trigger, limit int64) *trade.SubmitOrder {
+		return &trade.SubmitOrder{
+			Symbol: "700.HK", OrderType: orderType, Side: side, SubmittedQuantity: 100, TimeInForce: trade.TimeTypeGTC,
+			TriggerPrice: decimal.NewFromInt(trigger), SubmittedPrice: decimal.NewFromInt(limit),
+		}
+	}
+	result := runOrders(t, []*trade.SubmitOrder{
+		order(trade.OrderTypeMIT, trade.OrderSideBuy, 290, 0),
+		order(trade.OrderTypeMIT, trade.OrderSideSell, 310, 0),
+		order(trade.OrderTypeLIT, trade.OrderSideSell, 304, 306),
+		order(trade.OrderTypeLIT, trade.OrderSideBuy, 296, 295),
+	},
+		bar(2, 300, 301, 299, 300),
+		bar(3, 300, 305, 292, 295), // sells touch 304 from below, buys touch 296 from above
+		bar(4, 294, 296, 288, 290), // the buy touches 290
+		bar(5, 312, 315, 309, 311), // gapped above 310
+	)
+	tests := []struct {
+		triggered time.Time
+		filled    time.Time
+		price     string
+	}{
+		// buys trigger at or below the trigger price, MIT fills at it
+		{triggered: day(4), filled: day(4), price: "290"},
+		// sells trigger at or above the trigger price, MIT fills at the open if gapped
+		{triggered: day(5), filled: day(5), price: "312"},
+		// LIT works as a limit order once triggered, 306 is not reached until the gap
+		{triggered: day(3), filled: day(5), price: "312"},
+		{triggered: day(3), filled: day(3), price: "295"},
+	}
+	for i, tt := range tests {
+		o := result.Orders[i]
+		assert.Equal(t, trade.OrderFilledStatus, o.Status)
+		assert.Equal(t, tt.triggered, o.TriggeredAt)
+		assert.Equal(t, tt.filled, o.UpdatedAt)
+		assert.Equal(t, tt.price, o.ExecutedPrice.String())
+	}
+}
+
+func TestTrailingLimitOrders(t *testing.T) {
+	// the sell trails the high 320, the stop 310 is gapped by the open 305 and the limit 309 is reached a bar later
+	result := runOrders(t, []*trade.SubmitOrder{{
+		Symbol: "700.HK", OrderType: trade.OrderTypeTSLPAMT, Side: trade.OrderSideSell, SubmittedQuantity: 100,
+		TrailingAmount: decimal.NewFromInt(10), LimitOffset: decimal.NewFromInt(1), TimeInForce: trade.TimeTypeGTC,
+	}},
+		bar(2, 300, 301, 299, 300),
+		bar(3, 300, 320, 299, 318),
+		bar(4, 305, 308, 300, 302),
+		bar(5, 306, 309.4, 304, 309),
+	)
+	o := result.Orders[0]
+	assert.Equal(t, day(4), o.TriggeredAt)
+	assert.Equal(t, "310", o.Params.TriggerPrice.String())
+	assert.Equal(t, "309", o.Params.SubmittedPrice.String())
+	assert.Equal(t, trade.OrderFilledStatus, o.Status)
+	assert.Equal(t, day(5), o.UpdatedAt)
+	assert.Equal(t, "309", o.ExecutedPrice.String())
+
+	// the buy trails the low 280 by 5%, the stop 294 is gapped by the open 297 and the limit 295 is reached a bar later
+	result = runOrders(t, []*trade.SubmitOrder{{
+		Symbol: "700.HK", OrderType: trade.OrderTypeTSLPPCT, Side: trade.OrderSideBuy, SubmittedQuantity: 100,
+		TrailingPercent: decimal.NewFromInt(5), LimitOffset: decimal.NewFromInt(1), TimeInForce: trade.TimeTypeGTC,
+	}},
+		bar(2, 300, 301, 299, 300),
+		bar(3, 300, 302, 280, 282),
+		bar(4, 297, 299, 296, 298),
+		bar(5, 296, 297, 294.6, 295),
+	)
+	o = result.Orders[0]
+	assert.Equal(t, day(4), o.TriggeredAt)
+	assert.Equal(t, "294", o.Params.TriggerPrice.String())
+	assert.Equal(t, "295", o.Params.SubmittedPrice.String())
+	assert.Equal(t, trade.OrderFilledStatus, o.Status)
+	assert.Equal(t, day(5), o.UpdatedAt)
+	assert.Equal(t, "295", o.ExecutedPrice.String())
+}
@@ -0,0 +1,254 @@
+package backtest
+
+import (
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+func supported(t trade.OrderType) bool {
+	switch t {
+	case trade.OrderTypeLO, trade.OrderTypeELO, trade.OrderTypeMO, trade.OrderTypeAO, trade.OrderTypeALO,
+		trade.OrderTypeODD, trade.OrderTypeLIT, trade.OrderTypeMIT, trade.OrderTypeSLO,
+		trade.OrderTypeTSLPAMT, trade.OrderTypeTSLPPCT, trade.OrderTypeTSMAMT, trade.OrderTypeTSMPCT:
+		return true
+	}
+	return false
+}
+
+func isTrailing(t trade.OrderType) bool {
+	switch t {
+	case trade.OrderTypeTSLPAMT, trade.OrderTypeTSLPPCT, trade.OrderTypeTSMAMT, trade.OrderTypeTSMPCT:
+		return true
+	}
+	return false
+}
+
+// matchBar fills open orders of the bar symbol submitted before the bar.
+//
+// Prices inside a bar are assumed to open first and then touch low and high in the worst order for the order:
+//   - MO / AO fill at the open.
+//   - LO / ELO / ALO / ODD / SLO fill at the open if it is better than the limit, otherwise at the limit if touched.
+//   - LIT / MIT trigger when a buy touches the trigger price from above or a sell from below,
+//     then MIT fills at the trigger price (or the open if gapped) and LIT works as a limit order.
+//   - Trailing orders track the high (sell) or low (buy) since submission, trigger when the price retraces
+//     by TrailingAmount or TrailingPercent, then TSM* fill like MIT and TSLP* work as a limit order
+//     with price of the stop price minus (sell) or plus (buy) LimitOffset.
+func (e *Engine) matchBar(bar Bar) {
+	for _, o := range e.orders {
+		if o.Params.Symbol != bar.Symbol || !o.Status.IsOpen() || !o.SubmittedAt.Before(bar.Time) {
+			continue
+		}
+		if price, ok := e.fillPrice(o, bar); ok {
+			e.fill(o, price)
+		}
+	}
+}
+
+func (e *Engine) fillPrice(o *Order, bar Bar) (decimal.Decimal, bool) {
+	buy := o.Params.Side == trade.OrderSideBuy
+	p := &o.Params
+	switch t := p.OrderType; {
+	case t == trade.OrderTypeMO || t == trade.OrderTypeAO:
+		return e.slip(p.Symbol, bar.Open, buy), true
+	case t == trade.OrderTypeLIT || t == trade.OrderTypeMIT:
+		price := bar.Open
+		if o.TriggeredAt.IsZero() {
+			var ok bool
+			if price, ok = touch(bar, p.TriggerPrice, buy); !ok {
+				return decimal.Zero, false
+			}
+			o.TriggeredAt = bar.Time
+		}
+		if t == trade.OrderTypeMIT {
+			return e.slip(p.Symbol, price, buy), true
+		}
+		return e.limitFill(p.Symbol, price, bar, p.SubmittedPrice, buy)
+	case isTrailing(t):
+		if o.TriggeredAt.IsZero() {
+			stop, price, ok := e.trail(o, bar, buy)
+			if !ok {
+				return decimal.Zero, false
+			}
+			o.TriggeredAt = bar.Time
+			p.TriggerPrice = stop
+			if t == trade.OrderTypeTSMAMT || t == trade.OrderTypeTSMPCT {
+				return e.slip(p.Symbol, price, buy), true
+			}
+			// fixed to a limit order once triggered
+			if buy {
+				p.SubmittedPrice = stop.Add(p.LimitOffset)
+			} else {
+				p.SubmittedPrice = stop.Sub(p.LimitOffset)
+			}
+			return e.limitFill(p.Symbol, price, bar, p.SubmittedPrice, buy)
+		}
+		return e.limitFill(p.Symbol, bar.Open, bar, p.SubmittedPrice, buy)
+	default:
+		return e.limitFill(p.Symbol, bar.Open, bar, p.SubmittedPrice, buy)
+	}
+}
+
+// touch returns the price when the bar touches trigger, buys trigger at or below it and sells at or above it
+func touch(bar Bar, trigger decimal.Decimal, buy bool) (decimal.Decimal, bool) {
+	if buy {
+		if bar.Open.LessThanOrEqual(trigger) {
+			return bar.Open, true
+		}
+		return trigger, bar.Low.LessThanOrEqual(trigger)
+	}
+	if bar.Open.GreaterThanOrEqual(trigger) {
+		return bar.Open, true
+	}
+	return trigger, bar.High.GreaterThanOrEqual(trigger)
+}
+
+// limitFill fills a limit order from reference price ref of the bar
+func (e *Engine) limitFill(symbol string, ref decimal.Decimal, bar Bar, limit decimal.Decimal, buy bool) (decimal.Decimal, bool) {
+	if buy {
+		if ref.LessThanOrEqual(limit) {
+			return decimal.Min(e.slip(symbol, ref, buy), limit), true
+		}
+		return limit, bar.Low.LessThanOrEqual(limit)
+	}
+	if ref.GreaterThanOrEqual(limit) {
+		return decimal.Max(e.slip(symbol, ref, buy), limit), true
+	}
+	return limit, bar.High.GreaterThanOrEqual(limit)
+}
+
+// trail updates the water mark of a trailing order and returns the stop price and the trigger price if triggered
+func (e *Engine) trail(o *Order, bar Bar, buy bool) (stop, price decimal.Decimal, ok bool) {
+	if o.mark.IsZero() {
+		o.mark = bar.Open
+	}
+	better := func(a, b decimal.Decimal) decimal.Decimal {
+		if buy {
+			return decimal.Min(a, b)
+		}
+		return decimal.Max(a, b)
+	}
+	// the open moves the mark first
+	o.mark = better(o.mark, bar.Open)
+	stop = e.stopPrice(o, buy)
+	// the retracement is assumed to happen before the bar extends the mark
+	if price, ok = touch(bar, stop, !buy); ok {
+		return stop, price, true
+	}
+	if buy {
+		o.mark = better(o.mark, bar.Low)
+	} else {
+		o.mark = better(o.mark, bar.High)
+	}
+	return stop, decimal.Zero, false
+}
+
+func (e *Engine) stopPrice(o *Order, buy bool) decimal.Decimal {
+	p := &o.Params
+	var distance decimal.Decimal
+	if p.OrderType == trade.OrderTypeTSLPPCT || p.OrderType == trade.OrderTypeTSMPCT {
+		distance = o.mark.Mul(p.TrailingPercent).Div(hundred)
+	} else {
+		distance = p.TrailingAmount
+	}
+	tick := e.cfg.rule(p.Symbol).TickSize
+	if buy {
+		return roundUp(o.mark.Add(distance), tick)
+	}
+	return roundDown(o.mark.Sub(distance), tick)
+}
+
+func roundUp(p, tick decimal.Decimal) decimal.Decimal {
+	return p.Div(tick).Ceil().Mul(tick)
+}
+
+func roundDown(p, tick decimal.Decimal) decimal.Decimal {
+	return p.Div(tick).Floor().Mul(tick)
+}
+
+// slip returns the price worse by slippage and rounded to tick size against the order
+func (e *Engine) slip(symbol string, price decimal.Decimal, buy bool) decimal.Decimal {
+	tick := e.cfg.rule(symbol).TickSize
+	f := e.cfg.SlippageBps.Div(decimal.NewFromInt(10000))
+	if buy {
+		return roundUp(price.Mul(decimal.NewFromInt(1).Add(f)), tick)
+	}
+	return roundDown(price.Mul(decimal.NewFromInt(1).Sub(f)), tick)
+}
+
+// fill executes the remaining quantity of the order at price
+func (e *Engine) fill(o *Order, price decimal.Decimal) {
+	p := &o.Params
+	buy := p.Side == trade.OrderSideBuy
+	qty := p.SubmittedQuantity - o.ExecutedQuantity
+	amount := price.Mul(decimal.NewFromInt(int64(qty)))
+	fee := decimal.Zero
+	if e.cfg.Fee != nil {
+		fee = e.cfg.Fee(p.Symbol, p.Side, qty, price)
+	}
+	pos := e.position(p.Symbol)
+	if buy && !e.cfg.AllowMargin && amount.Add(fee).GreaterThan(e.cash) {
+		e.update(o, trade.OrderRejectedStatus, "insufficient cash")
+		return
+	}
+	if !buy && !e.cfg.AllowShort && pos.Quantity < int64(qty) {
+		e.update(o, trade.OrderRejectedStatus, "insufficient position")
+		return
+	}
+
+	signed := int64(qty)
+	if buy {
+		e.cash = e.cash.Sub(amount).Sub(fee)
+	} else {
+		signed = -signed
+		e.cash = e.cash.Add(amount).Sub(fee)
+	}
+
+	f := Fill{OrderId: o.Id, Symbol: p.Symbol, Side: p.Side, Time: e.now, Quantity: qty, Price: price, Fee: fee}
+	switch total := pos.Quantity + signed; {
+	case pos.Quantity == 0 || (pos.Quantity > 0) == (signed > 0):
+		pos.CostPrice = pos.CostPrice.Mul(decimal.NewFromInt(pos.Quantity)).Add(amount.Mul(decimal.NewFromInt(sign(signed)))).
+			Div(decimal.NewFromInt(total))
+		pos.Quantity = total
+	default:
+		closed := -signed
+		if abs(closed) > abs(pos.Quantity) {
+			closed = pos.Quantity
+		}
+		f.Closed = true
+		f.Realized = price.Sub(pos.CostPrice).Mul(decimal.NewFromInt(closed))
+		if (pos.Quantity > 0) != (total > 0) && total != 0 {
+			// reversed, the rest opens at the fill price
+			pos.CostPrice = price
+		} else if total == 0 {
+			pos.CostPrice = decimal.Zero
+		}
+		pos.Quantity = total
+	}
+	if pos.LastPrice.IsZero() {
+		pos.LastPrice = price
+	}
+	e.fills = append(e.fills, f)
+
+	executed := o.ExecutedPrice.Mul(decimal.NewFromInt(int64(o.ExecutedQuantity))).Add(amount)
+	o.ExecutedQuantity += qty
+	o.ExecutedPrice = executed.Div(decimal.NewFromInt(int64(o.ExecutedQuantity)))
+	o.Fees = o.Fees.Add(fee)
+	e.update(o, trade.OrderFilledStatus, "")
+}
+
+func sign(v int64) int64 {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
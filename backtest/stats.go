@@ -0,0 +1,70 @@
+package backtest
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Stats of a backtest, ratios are fractions, e.g. 0.1 means 10%
+type Stats struct {
+	StartEquity decimal.Decimal
+	EndEquity   decimal.Decimal
+	TotalReturn decimal.Decimal
+	// MaxDrawdown is the largest drop from a peak of the equity curve
+	MaxDrawdown decimal.Decimal
+	Fills       int
+	// ClosedTrades is the count of fills reducing a position
+	ClosedTrades int
+	Wins         int
+	Losses       int
+	WinRate      decimal.Decimal
+	GrossProfit  decimal.Decimal
+	GrossLoss    decimal.Decimal // negative
+	// ProfitFactor is GrossProfit / -GrossLoss, zero without losses
+	ProfitFactor decimal.Decimal
+	RealizedPnL  decimal.Decimal
+	Fees         decimal.Decimal
+}
+
+func computeStats(initial decimal.Decimal, equity []EquityPoint, fills []Fill) Stats {
+	s := Stats{StartEquity: initial, EndEquity: initial, Fills: len(fills)}
+	peak := initial
+	for _, p := range equity {
+		if p.Equity.GreaterThan(peak) {
+			peak = p.Equity
+		}
+		if peak.IsPositive() {
+			if dd := peak.Sub(p.Equity).Div(peak); dd.GreaterThan(s.MaxDrawdown) {
+				s.MaxDrawdown = dd
+			}
+		}
+	}
+	if len(equity) > 0 {
+		s.EndEquity = equity[len(equity)-1].Equity
+	}
+	if initial.IsPositive() {
+		s.TotalReturn = s.EndEquity.Sub(initial).Div(initial)
+	}
+	for _, f := range fills {
+		s.Fees = s.Fees.Add(f.Fee)
+		if !f.Closed {
+			continue
+		}
+		s.ClosedTrades++
+		s.RealizedPnL = s.RealizedPnL.Add(f.Realized)
+		switch {
+		case f.Realized.IsPositive():
+			s.Wins++
+			s.GrossProfit = s.GrossProfit.Add(f.Realized)
+		case f.Realized.IsNegative():
+			s.Losses++
+			s.GrossLoss = s.GrossLoss.Add(f.Realized)
+		}
+	}
+	if s.ClosedTrades > 0 {
+		s.WinRate = decimal.NewFromInt(int64(s.Wins)).Div(decimal.NewFromInt(int64(s.ClosedTrades)))
+	}
+	if s.GrossLoss.IsNegative() {
+		s.ProfitFactor = s.GrossProfit.Div(s.GrossLoss.Neg())
+	}
+	return s
+}
@@ -57,6 +57,12 @@ type Config struct {
 	ReadBufferSize int           `env:"LONGBRIDGE_READ_BUFFER_SIZE,LONGPORT_READ_BUFFER_SIZE" yaml:"read_buffer_size" toml:"read_buffer_size"`
 	MinGzipSize    int           `env:"LONGBRIDGE_MIN_GZIP_SIZE,LONGPORT_MIN_GZIP_SIZE" yaml:"min_gzip_size" toml:"min_gzip_size"`
 	Region         Region        `env:"LONGPORT_REGION" yaml:"region" toml:"region"`
+
+	// Risk is pre-trade risk controls of TradeContext
+	Risk RiskConfig `yaml:"risk" toml:"risk"`
+
+	// RateLimit overrides the client-side rate limits of REST requests
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
 }
 
 // parseConfig is a config for toml/yaml
@@ -0,0 +1,25 @@
+package config
+
+// RateLimitConfig overrides the client-side rate limits of REST requests, see http.DefaultRateLimits
+type RateLimitConfig struct {
+	// Disable sends requests without client-side rate limits
+	Disable bool `env:"LONGPORT_RATE_LIMIT_DISABLE" yaml:"disable" toml:"disable"`
+	// Limits replace the default limits of the same methods and path, and add limits of other endpoints
+	Limits []RateLimit `yaml:"limits" toml:"limits"`
+}
+
+// RateLimit is a token bucket shared by the requests of an endpoint.
+// A bucket of Rate and Burst admits at most Burst + Rate*W requests in any window of W seconds.
+type RateLimit struct {
+	// Methods of the requests, e.g. ["POST", "PUT"], empty means all methods
+	Methods []string `yaml:"methods" toml:"methods"`
+	// Path of the requests, or a path prefix ending with "*", e.g. "/v1/trade/*"
+	Path string `yaml:"path" toml:"path"`
+	// Rate is the number of requests per second
+	Rate float64 `yaml:"rate" toml:"rate"`
+	// Burst is the max number of requests sent at once
+	Burst int `yaml:"burst" toml:"burst"`
+	// Bucket names a token bucket shared by the limits of the same Bucket, e.g. endpoints of the same quota,
+	// Rate and Burst of the first limit of the bucket are used. Empty means the limit has a bucket of its own.
+	Bucket string `yaml:"bucket" toml:"bucket"`
+}
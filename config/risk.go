@@ -0,0 +1,28 @@
+package config
+
+import (
+	"github.com/longportapp/openapi-go"
+)
+
+// RiskConfig is pre-trade risk controls enforced by TradeContext before SubmitOrder and ReplaceOrder.
+// Zero value of a field means the rule is disabled.
+type RiskConfig struct {
+	// MaxOrderNotional is the maximum price * quantity of an order, in the currency of the order
+	MaxOrderNotional float64 `env:"LONGPORT_RISK_MAX_ORDER_NOTIONAL" yaml:"max_order_notional" toml:"max_order_notional"`
+	// MarketMaxOrderNotional overrides MaxOrderNotional for a market, e.g. {"HK": 1000000}
+	MarketMaxOrderNotional map[openapi.Market]float64 `yaml:"market_max_order_notional" toml:"market_max_order_notional"`
+	// MaxOrderQuantity is the maximum quantity of an order
+	MaxOrderQuantity uint64 `env:"LONGPORT_RISK_MAX_ORDER_QUANTITY" yaml:"max_order_quantity" toml:"max_order_quantity"`
+	// SymbolMaxOrderQuantity overrides MaxOrderQuantity for a symbol, e.g. {"700.HK": 10000}
+	SymbolMaxOrderQuantity map[string]uint64 `yaml:"symbol_max_order_quantity" toml:"symbol_max_order_quantity"`
+	// AllowedSymbols only allows orders of these symbols
+	AllowedSymbols []string `yaml:"allowed_symbols" toml:"allowed_symbols"`
+	// AllowedMarkets only allows orders of these markets
+	AllowedMarkets []openapi.Market `yaml:"allowed_markets" toml:"allowed_markets"`
+	// MaxOrdersPerMinute limits the count of orders submitted in any 60 seconds
+	MaxOrdersPerMinute int `env:"LONGPORT_RISK_MAX_ORDERS_PER_MINUTE" yaml:"max_orders_per_minute" toml:"max_orders_per_minute"`
+	// PriceCollarPercent is the maximum deviation in percent of the order price from the last done price, e.g. 5 means 5%
+	PriceCollarPercent float64 `env:"LONGPORT_RISK_PRICE_COLLAR_PERCENT" yaml:"price_collar_percent" toml:"price_collar_percent"`
+	// KillSwitch blocks all new orders from startup
+	KillSwitch bool `env:"LONGPORT_RISK_KILL_SWITCH" yaml:"kill_switch" toml:"kill_switch"`
+}
@@ -16,13 +16,6 @@ import (
 	"github.com/longportapp/openapi-go/log"
 )
 
-type apiResponse struct {
-	Code    int
-	Message string
-	Data    json.RawMessage
-	TraceID string
-}
-
 type otpResponse struct {
 	Otp string
 }
@@ -31,6 +24,7 @@ type otpResponse struct {
 type Client struct {
 	opts       *Options
 	httpClient *nhttp.Client
+	handler    Handler
 }
 
 // RequestOptions use to set additional information for the request
@@ -38,6 +32,7 @@ type RequestOptions struct {
 	// Request Header
 	Header nhttp.Header
 	body   interface{}
+	retry  *bool
 }
 
 // RequestOption use to set addition info to request
@@ -59,6 +54,14 @@ func WithBody(v interface{}) RequestOption {
 	}
 }
 
+// WithRetry to enable or disable retries of the request by the RetryPolicy of the client.
+// GET requests are retried by default, enable it for other methods only if the request is idempotent.
+func WithRetry(enable bool) RequestOption {
+	return func(o *RequestOptions) {
+		o.retry = &enable
+	}
+}
+
 // Get sends Get request with queryParams
 func (c *Client) Get(ctx context.Context, path string, queryParams url.Values, resp interface{}, ropts ...RequestOption) error {
 	return c.Call(ctx, "GET", path, queryParams, nil, resp, ropts...)
@@ -99,13 +102,14 @@ func (c *Client) GetOTPV2(ctx context.Context, ropts ...RequestOption) (string,
 	return res.Otp, nil
 }
 
-// Call will send request with signature to http server
+// Call will send request with signature to http server.
+// Requests wait for the client-side rate limits of WithRateLimitConfig within ctx, see DefaultRateLimits.
+// Failed requests are retried by the RetryPolicy of WithRetryPolicy, see RetryPolicy.
 func (c *Client) Call(ctx context.Context, method, path string, queryParams interface{}, body interface{}, resp interface{}, ropts ...RequestOption) (err error) {
 	var (
-		br       io.Reader
 		bb       []byte
-		httpResp *nhttp.Response
-		rb       []byte
+		rawQuery string
+		apiResp  *Response
 	)
 
 	ro := &RequestOptions{}
@@ -122,14 +126,77 @@ func (c *Client) Call(ctx context.Context, method, path string, queryParams inte
 		if err != nil {
 			return err
 		}
-		br = bytes.NewBuffer(bb)
 	}
 
-	req, err := nhttp.NewRequestWithContext(ctx, method, c.opts.URL+path, br)
+	// set query params
+	if queryParams != nil {
+		vals, ok := queryParams.(url.Values)
+		if !ok {
+			if vals, err = query.Values(queryParams); err != nil {
+				return
+			}
+		}
+		rawQuery = vals.Encode()
+	}
+
+	policy := c.opts.RetryPolicy
+	retry := method == nhttp.MethodGet
+	if ro.retry != nil {
+		retry = *ro.retry
+	}
+	maxAttempts := 1
+	if retry && policy != nil && policy.MaxAttempts > 1 {
+		maxAttempts = policy.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err = c.opts.RateLimiter.Wait(ctx, method, path); err != nil {
+			return err
+		}
+		apiResp, err = c.do(ctx, method, path, rawQuery, bb, ro, attempt)
+		if attempt >= maxAttempts || !retryable(ctx, apiResp, err) {
+			break
+		}
+		delay := policy.delay(attempt, apiResp)
+		if err != nil {
+			log.Warnf("http call method:%v path:%v attempt %d error:%v, retry in %v", method, path, attempt, err, delay)
+		} else {
+			log.Warnf("http call method:%v path:%v attempt %d status:%d, retry in %v", method, path, attempt, apiResp.HttpStatus, delay)
+		}
+		if !sleep(ctx, delay) {
+			break
+		}
+	}
 	if err != nil {
 		return err
 	}
 
+	if apiResp.HttpStatus != nhttp.StatusOK || apiResp.Code != 0 {
+		return NewError(apiResp.HttpStatus, apiResp)
+	}
+
+	if resp == nil {
+		return
+	}
+
+	if err = jsonUnmarshal(bytes.NewReader(apiResp.Data), resp); err != nil {
+		return err
+	}
+	return nil
+}
+
+// do sends one attempt of Call through the middlewares, the request is signed with a fresh timestamp for every attempt
+func (c *Client) do(ctx context.Context, method, path, rawQuery string, bb []byte, ro *RequestOptions, attempt int) (*Response, error) {
+	var br io.Reader
+	if bb != nil {
+		br = bytes.NewReader(bb)
+	}
+
+	req, err := nhttp.NewRequestWithContext(ctx, method, c.opts.URL+path, br)
+	if err != nil {
+		return nil, err
+	}
+
 	// set headers
 	req.Header.Add("accept-language", string(c.opts.Language))
 	req.Header.Add("x-api-key", c.opts.AppKey)
@@ -142,60 +209,52 @@ func (c *Client) Call(ctx context.Context, method, path string, queryParams inte
 	if len(bb) != 0 {
 		req.Header.Add("content-type", "application/json; charset=utf-8")
 	}
-
-	// set query params
-	if queryParams != nil {
-		vals, ok := queryParams.(url.Values)
-		if !ok {
-			if vals, err = query.Values(queryParams); err != nil {
-				return
-			}
-		}
-		req.URL.RawQuery = vals.Encode()
-	}
+	req.URL.RawQuery = rawQuery
 	// set signature
 	signature(req, c.opts.AppSecret, bb)
-
-	log.Debugf("http call method:%v url:%v body:%v", req.Method, req.URL, string(bb))
 	req.Close = true
-	httpResp, err = c.httpClient.Do(req)
+
+	resp, err := c.handler(ctx, &Request{Request: req, Payload: bb, Attempt: attempt, secret: c.opts.AppSecret})
+	if err == nil && resp == nil {
+		return nil, errors.New("http middleware returned no response")
+	}
+	return resp, err
+}
+
+// send is the innermost Handler, it sends the request by the *http.Client of the client
+func (c *Client) send(ctx context.Context, req *Request) (*Response, error) {
+	log.Debugf("http call method:%v url:%v body:%v", req.Method, req.URL, string(req.Payload))
+	if req.Payload != nil {
+		// middlewares may have read the body
+		req.Body = io.NopCloser(bytes.NewReader(req.Payload))
+	}
+	httpResp, err := c.httpClient.Do(req.Request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Debugf("http call response headers:%v", httpResp.Header)
 	defer httpResp.Body.Close()
 
-	if rb, err = io.ReadAll(httpResp.Body); err != nil {
-		return err
+	rb, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
 	}
 	log.Debugf("http call response body:%s", rb)
 
-	apiResp := &apiResponse{}
-
-	if v := httpResp.Header.Get("x-trace-id"); v != "" {
-		apiResp.TraceID = v
-	}
+	apiResp := &Response{HttpStatus: httpResp.StatusCode, Header: httpResp.Header}
 
 	if isJSON(httpResp.Header.Get("content-type")) {
 		if err = jsonUnmarshal(bytes.NewReader(rb), apiResp); err != nil {
-			return err
+			return apiResp, err
 		}
 	} else {
 		apiResp.Message = string(rb)
 	}
 
-	if httpResp.StatusCode != nhttp.StatusOK || apiResp.Code != 0 {
-		return NewError(httpResp.StatusCode, apiResp)
-	}
-
-	if resp == nil {
-		return
-	}
-
-	if err = jsonUnmarshal(bytes.NewReader(apiResp.Data), resp); err != nil {
-		return err
+	if v := httpResp.Header.Get("x-trace-id"); v != "" {
+		apiResp.TraceID = v
 	}
-	return nil
+	return apiResp, nil
 }
 
 func isJSON(ct string) bool {
@@ -225,6 +284,7 @@ func New(opt ...Option) (*Client, error) {
 		opts:       opts,
 		httpClient: cli,
 	}
+	client.handler = client.chain()
 	return client, nil
 }
 
@@ -238,5 +298,6 @@ func NewFromCfg(c *config.Config) (*Client, error) {
 		WithClient(c.Client),
 		WithURL(c.HttpURL),
 		WithLanguage(c.Language),
+		WithRateLimitConfig(&c.RateLimit),
 	)
 }
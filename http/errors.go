@@ -1,9 +1,72 @@
 package http
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	nhttp "net/http"
+	"sync"
+
+	protocol "github.com/longportapp/openapi-protocol/go"
+)
+
+// Sentinel errors of known error codes, use errors.Is to check an error of Client or the quote and trade context.
+// ErrInvalidToken also matches HTTP status 401 and the websocket status Unauthenticated,
+// ErrRateLimited also matches HTTP status 429. Use RegisterErrorCode to classify other codes.
+//
+// Example:
+//
+//	_, err := tctx.SubmitOrder(ctx, order)
+//	if errors.Is(err, http.ErrMarketClosed) {
+//	  // submit it at the next session
+//	}
+var (
+	ErrInvalidToken            = errors.New("invalid or expired access token")
+	ErrRateLimited             = errors.New("request rate limited")
+	ErrMarketClosed            = errors.New("market closed")
+	ErrInsufficientBuyingPower = errors.New("insufficient buying power")
 )
 
+var (
+	errorCodesMu sync.RWMutex
+	errorCodes   = defaultErrorCodes()
+)
+
+// defaultErrorCodes returns the catalog of known error codes of the HTTP and websocket APIs
+func defaultErrorCodes() map[int]error {
+	return map[int]error{
+		401003: ErrInvalidToken,            // invalid token
+		401004: ErrInvalidToken,            // token expired
+		429001: ErrRateLimited,             // too many requests of the app
+		429002: ErrRateLimited,             // api request is limited
+		301606: ErrRateLimited,             // websocket request rate limited
+		603302: ErrMarketClosed,            // market closed, the order can not be submitted
+		603303: ErrInsufficientBuyingPower, // insufficient buying power
+	}
+}
+
+// RegisterErrorCode to classify errors of code as target, e.g. a code missing in the catalog of sentinel errors,
+// target may be one of the sentinel errors or an error of the caller
+func RegisterErrorCode(code int, target error) {
+	errorCodesMu.Lock()
+	errorCodes[code] = target
+	errorCodesMu.Unlock()
+}
+
+// resetErrorCodes restores the catalog of error codes, undoing RegisterErrorCode
+func resetErrorCodes() {
+	errorCodesMu.Lock()
+	errorCodes = defaultErrorCodes()
+	errorCodesMu.Unlock()
+}
+
+func codeIs(code int, target error) bool {
+	errorCodesMu.RLock()
+	defer errorCodesMu.RUnlock()
+	e, ok := errorCodes[code]
+	return ok && e == target
+}
+
 type ApiError struct {
 	HttpStatus int
 	Code       int
@@ -15,7 +78,20 @@ func (ae *ApiError) Error() string {
 	return fmt.Sprintf("longbridge openapi error, httpStatus:%d code:%d message:%s trace:%s", ae.HttpStatus, ae.Code, ae.Message, ae.TraceID)
 }
 
-func NewError(httpStatus int, resp *apiResponse) error {
+// Is reports whether the error is classified as target by its code or HTTP status
+func (ae *ApiError) Is(target error) bool {
+	switch {
+	case codeIs(ae.Code, target):
+		return true
+	case target == ErrInvalidToken:
+		return ae.HttpStatus == nhttp.StatusUnauthorized
+	case target == ErrRateLimited:
+		return ae.HttpStatus == nhttp.StatusTooManyRequests
+	}
+	return false
+}
+
+func NewError(httpStatus int, resp *Response) error {
 	return &ApiError{
 		HttpStatus: httpStatus,
 		Code:       resp.Code,
@@ -23,3 +99,85 @@ func NewError(httpStatus int, resp *apiResponse) error {
 		TraceID:    resp.TraceID,
 	}
 }
+
+// protocolError classifies a *protocol.LBError of the websocket APIs like ApiError,
+// errors.As still finds the *protocol.LBError
+type protocolError struct {
+	err error
+	lb  *protocol.LBError
+}
+
+func (e *protocolError) Error() string {
+	return e.err.Error()
+}
+
+func (e *protocolError) Unwrap() error {
+	return e.err
+}
+
+func (e *protocolError) Is(target error) bool {
+	switch {
+	case codeIs(int(e.lb.Code), target):
+		return true
+	case target == ErrInvalidToken:
+		return e.lb.Status == protocol.StatusUnauthenticated
+	}
+	return false
+}
+
+// WrapProtocolError returns err classified by the sentinel errors if it is a *protocol.LBError
+// of the quote and trade websocket APIs, other errors are returned as is
+func WrapProtocolError(err error) error {
+	var lb *protocol.LBError
+	if err == nil || !errors.As(err, &lb) {
+		return err
+	}
+	var pe *protocolError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &protocolError{err: err, lb: lb}
+}
+
+// IsRetryable reports whether the request of err may succeed if it is sent again:
+// rate limited, a server error or a timeout
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr.HttpStatus >= nhttp.StatusInternalServerError
+	}
+	var lb *protocol.LBError
+	if errors.As(err, &lb) {
+		switch lb.Status {
+		case protocol.StatusServerTimeout, protocol.StatusClientTimeout, protocol.StatusServerInternalError:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsAuth reports whether err is caused by the credentials: an invalid or expired token, or no permission
+func IsAuth(err error) bool {
+	if errors.Is(err, ErrInvalidToken) {
+		return true
+	}
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr.HttpStatus == nhttp.StatusForbidden
+	}
+	var lb *protocol.LBError
+	return errors.As(err, &lb) && (lb.Status == protocol.StatusUnauthenticated || lb.Status == protocol.StatusPermissionDenied)
+}
+
+// IsRateLimit reports whether err is caused by the rate limits of the server
+func IsRateLimit(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+
+	protocol "github.com/longportapp/openapi-protocol/go"
+)
+
+func TestApiErrorIs(t *testing.T) {
+	t.Cleanup(resetErrorCodes)
+	err := pkgerrors.Wrap(&ApiError{HttpStatus: 400, Code: 603303}, "submit order")
+	assertEqual(t, true, errors.Is(err, ErrInsufficientBuyingPower))
+	assertEqual(t, true, errors.Is(&ApiError{HttpStatus: 400, Code: 603302}, ErrMarketClosed))
+	assertEqual(t, false, errors.Is(err, ErrMarketClosed))
+	assertEqual(t, false, IsRetryable(err))
+
+	err = &ApiError{HttpStatus: 429}
+	assertEqual(t, true, IsRateLimit(err))
+	assertEqual(t, true, IsRetryable(err))
+	assertEqual(t, true, IsAuth(&ApiError{HttpStatus: 401}))
+	assertEqual(t, true, IsRetryable(&ApiError{HttpStatus: 502}))
+
+	custom := errors.New("custom")
+	RegisterErrorCode(999999, custom)
+	assertEqual(t, true, errors.Is(&ApiError{HttpStatus: 400, Code: 999999}, custom))
+	// codes of the catalog can be reclassified
+	RegisterErrorCode(603302, custom)
+	assertEqual(t, false, errors.Is(&ApiError{HttpStatus: 400, Code: 603302}, ErrMarketClosed))
+}
+
+func TestResetErrorCodes(t *testing.T) {
+	RegisterErrorCode(999999, ErrMarketClosed)
+	RegisterErrorCode(603302, ErrRateLimited)
+	resetErrorCodes()
+	assertEqual(t, false, errors.Is(&ApiError{HttpStatus: 400, Code: 999999}, ErrMarketClosed))
+	assertEqual(t, true, errors.Is(&ApiError{HttpStatus: 400, Code: 603302}, ErrMarketClosed))
+}
+
+func TestProtocolErrorIs(t *testing.T) {
+	err := WrapProtocolError(pkgerrors.Wrap(protocol.NewError(protocol.StatusBadRequest, 301606, "rate limit"), "subscribe"))
+	assertEqual(t, true, IsRateLimit(err))
+	assertEqual(t, true, IsRetryable(err))
+	var lb *protocol.LBError
+	assertEqual(t, true, errors.As(err, &lb))
+	assertEqual(t, uint64(301606), lb.Code)
+
+	err = WrapProtocolError(protocol.NewError(protocol.StatusUnauthenticated, 1, "unauthenticated"))
+	assertEqual(t, true, errors.Is(err, ErrInvalidToken))
+	assertEqual(t, true, IsAuth(err))
+	assertEqual(t, false, IsRetryable(err))
+
+	other := errors.New("other")
+	assertEqual(t, other, WrapProtocolError(other))
+}
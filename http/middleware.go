@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	nhttp "net/http"
+)
+
+// Request is a signed request of Client.Call passed to middlewares
+type Request struct {
+	*nhttp.Request
+	// Payload is the JSON body of the request, nil if there is no body
+	Payload []byte
+	// Attempt is the number of the attempt starting from 1, see RetryPolicy
+	Attempt int
+	secret  string
+}
+
+// Sign signs the request again with a fresh timestamp, call it after changing the signed headers,
+// which are authorization, x-api-key and x-timestamp
+func (r *Request) Sign() error {
+	r.Header.Del(headerTimestamp)
+	r.Header.Del("x-api-signature")
+	return signature(r.Request, r.secret, r.Payload)
+}
+
+// Response is the decoded response of a request
+type Response struct {
+	// HttpStatus and Header are of the HTTP response
+	HttpStatus int          `json:"-"`
+	Header     nhttp.Header `json:"-"`
+	Code       int
+	Message    string
+	Data       json.RawMessage
+	TraceID    string
+}
+
+// Handler sends a request and returns its response. The returned error is for sending the request
+// or decoding the response, a response of non-zero Code or HttpStatus other than 200 is not an error of Handler.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware wraps a Handler, e.g. to add headers, log requests and responses, or mirror requests
+//
+// Example:
+//
+//	audit := func(next http.Handler) http.Handler {
+//	  return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+//	    req.Header.Set("x-request-id", uuid.NewString())
+//	    resp, err := next(ctx, req)
+//	    if err == nil {
+//	      log.Printf("%s %s code:%d trace:%s", req.Method, req.URL.Path, resp.Code, resp.TraceID)
+//	    }
+//	    return resp, err
+//	  }
+//	}
+//	cli, err := http.New(http.WithMiddleware(audit))
+type Middleware func(next Handler) Handler
+
+// chain returns the handler of the middlewares of the client, the first middleware is the outermost
+func (c *Client) chain() Handler {
+	h := Handler(c.send)
+	for i := len(c.opts.Middlewares) - 1; i >= 0; i-- {
+		h = c.opts.Middlewares[i](h)
+	}
+	return h
+}
+
+// Wrap returns a copy of the client with the middlewares added after those of the client,
+// the copy shares the rate limits of the client
+func (c *Client) Wrap(m ...Middleware) *Client {
+	opts := *c.opts
+	opts.Middlewares = append(append([]Middleware(nil), c.opts.Middlewares...), m...)
+	cp := &Client{opts: &opts, httpClient: c.httpClient}
+	cp.handler = cp.chain()
+	return cp
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	nhttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	srv := httptest.NewServer(nhttp.HandlerFunc(func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.Header().Set("x-trace-id", "trace-1")
+		if r.Header.Get("authorization") != "token" {
+			fmt.Fprint(w, `{"code":401003,"message":"invalid token"}`)
+			return
+		}
+		fmt.Fprint(w, `{"code":0,"data":{"otp":"ok"}}`)
+	}))
+	defer srv.Close()
+
+	var (
+		calls []string
+		resps []*Response
+	)
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, req *Request) (*Response, error) {
+				calls = append(calls, name)
+				assertEqual(t, true, req.Header.Get("x-api-signature") != "")
+				assertEqual(t, 1, req.Attempt)
+				resp, err := next(ctx, req)
+				if err == nil {
+					resps = append(resps, resp)
+				}
+				return resp, err
+			}
+		}
+	}
+	cli, err := New(WithURL(srv.URL), WithAppKey("key"), WithAppSecret("secret"), WithAccessToken("token"),
+		WithMiddleware(record("a"), record("b")))
+	assertEqual(t, nil, err)
+
+	otp, err := cli.Wrap(record("c")).GetOTP(context.Background())
+	assertEqual(t, nil, err)
+	assertEqual(t, "ok", otp)
+	assertEqual(t, "a,b,c", fmt.Sprintf("%s,%s,%s", calls[0], calls[1], calls[2]))
+	assertEqual(t, "trace-1", resps[0].TraceID)
+	assertEqual(t, 0, resps[0].Code)
+	// the wrapped copy does not change the client
+	calls = nil
+	_, err = cli.GetOTP(context.Background())
+	assertEqual(t, nil, err)
+	assertEqual(t, 2, len(calls))
+
+	// a middleware changing signed headers signs the request again
+	resign := func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			req.Header.Set("authorization", "other")
+			if err := req.Sign(); err != nil {
+				return nil, err
+			}
+			return next(ctx, req)
+		}
+	}
+	resps = nil
+	_, err = cli.Wrap(resign, record("d")).GetOTP(context.Background())
+	assertEqual(t, 401003, err.(*ApiError).Code)
+	assertEqual(t, 401003, resps[len(resps)-1].Code)
+}
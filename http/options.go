@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/config"
 )
 
 // DefaultHttpUrl
@@ -22,6 +23,9 @@ type Options struct {
 	Timeout     time.Duration
 	Client      *http.Client
 	Language    openapi.Language
+	RetryPolicy *RetryPolicy
+	RateLimiter *RateLimiter
+	Middlewares []Middleware
 }
 
 // Option for http client
@@ -90,10 +94,44 @@ func WithLanguage(language openapi.Language) Option {
 	}
 }
 
+// WithRetryPolicy to set the retry policy of requests, default is DefaultRetryPolicy.
+// Set MaxAttempts of the policy to 1 to disable retries.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(opts *Options) {
+		if policy != nil {
+			opts.RetryPolicy = policy
+		}
+	}
+}
+
+// WithRateLimitConfig to override the client-side rate limits of requests, default is DefaultRateLimits
+func WithRateLimitConfig(cfg *config.RateLimitConfig) Option {
+	return func(opts *Options) {
+		if cfg == nil {
+			return
+		}
+		if cfg.Disable {
+			opts.RateLimiter = nil
+			return
+		}
+		opts.RateLimiter = NewRateLimiter(mergeRateLimits(DefaultRateLimits(), cfg.Limits))
+	}
+}
+
+// WithMiddleware to add middlewares of requests, the first middleware is the outermost.
+// Middlewares see every attempt of a request after it is signed, and its decoded response.
+func WithMiddleware(m ...Middleware) Option {
+	return func(opts *Options) {
+		opts.Middlewares = append(opts.Middlewares, m...)
+	}
+}
+
 func newOptions(opt ...Option) *Options {
 	opts := Options{
-		Timeout: DefaultTimeout,
-		URL:     DefaultHttpUrl,
+		Timeout:     DefaultTimeout,
+		URL:         DefaultHttpUrl,
+		RetryPolicy: DefaultRetryPolicy(),
+		RateLimiter: NewRateLimiter(DefaultRateLimits()),
 	}
 	for _, o := range opt {
 		o(&opts)
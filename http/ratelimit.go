@@ -0,0 +1,208 @@
+package http
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/longportapp/openapi-go/config"
+)
+
+// DefaultRateLimits returns the client-side rate limits of REST requests, which follow the documented quotas:
+// trade and asset requests share a bucket admitting at most 30 requests in any 30 seconds (burst 15, then one per 2 seconds),
+// and quote requests are limited to at most 10 in any second (burst 5, then 5 per second).
+// Requests of other endpoints are not limited.
+func DefaultRateLimits() []config.RateLimit {
+	return []config.RateLimit{
+		{Path: "/v1/trade/*", Rate: 0.5, Burst: 15, Bucket: "trade"},
+		{Path: "/v1/asset/*", Rate: 0.5, Burst: 15, Bucket: "trade"},
+		{Path: "/v1/quote/*", Rate: 5, Burst: 5},
+	}
+}
+
+// mergeRateLimits replaces the limits of the same methods and path in base by overrides, and appends the others
+func mergeRateLimits(base, overrides []config.RateLimit) []config.RateLimit {
+	merged := append([]config.RateLimit(nil), base...)
+	for _, o := range overrides {
+		replaced := false
+		for i, b := range merged {
+			if b.Path == o.Path && methodsKey(b.Methods) == methodsKey(o.Methods) {
+				merged[i] = o
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+func methodsKey(methods []string) string {
+	keys := make([]string, len(methods))
+	for i, m := range methods {
+		keys[i] = strings.ToUpper(m)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// RateLimiter delays REST requests by token buckets keyed by method and path pattern.
+// A request takes a token of the most specific matching limit: an exact path before a path prefix,
+// a longer prefix before a shorter one, and a limit with methods before one of all methods.
+type RateLimiter struct {
+	rules []*rateRule
+}
+
+type rateRule struct {
+	methods map[string]bool
+	path    string
+	prefix  bool
+	bucket  *tokenBucket
+}
+
+// NewRateLimiter returns a RateLimiter of limits, limits with non-positive Rate are ignored
+func NewRateLimiter(limits []config.RateLimit) *RateLimiter {
+	l := &RateLimiter{}
+	buckets := make(map[string]*tokenBucket)
+	for _, limit := range limits {
+		if limit.Rate <= 0 {
+			continue
+		}
+		bucket := buckets[limit.Bucket]
+		if bucket == nil {
+			bucket = newTokenBucket(limit.Rate, limit.Burst)
+			if limit.Bucket != "" {
+				buckets[limit.Bucket] = bucket
+			}
+		}
+		rule := &rateRule{path: limit.Path, bucket: bucket}
+		if strings.HasSuffix(rule.path, "*") {
+			rule.path, rule.prefix = strings.TrimSuffix(rule.path, "*"), true
+		}
+		if len(limit.Methods) > 0 {
+			rule.methods = make(map[string]bool, len(limit.Methods))
+			for _, m := range limit.Methods {
+				rule.methods[strings.ToUpper(m)] = true
+			}
+		}
+		l.rules = append(l.rules, rule)
+	}
+	return l
+}
+
+func (r *rateRule) specificity(method, path string) int {
+	if r.methods != nil && !r.methods[method] {
+		return -1
+	}
+	score := 0
+	if r.methods != nil {
+		score = 1
+	}
+	switch {
+	case !r.prefix && path == r.path:
+		return score + 1<<20
+	case r.prefix && strings.HasPrefix(path, r.path):
+		return score + 2*len(r.path)
+	}
+	return -1
+}
+
+func (l *RateLimiter) match(method, path string) *rateRule {
+	method = strings.ToUpper(method)
+	var (
+		best  *rateRule
+		score = -1
+	)
+	for _, r := range l.rules {
+		if s := r.specificity(method, path); s > score {
+			best, score = r, s
+		}
+	}
+	return best
+}
+
+// Wait blocks until the request of method and path may be sent. It returns an error without waiting
+// if ctx would be done before then, and ctx.Err() if ctx is done while waiting.
+func (l *RateLimiter) Wait(ctx context.Context, method, path string) error {
+	if l == nil {
+		return nil
+	}
+	if idx := strings.IndexByte(path, '?'); idx >= 0 {
+		path = path[:idx]
+	}
+	rule := l.match(method, path)
+	if rule == nil {
+		return nil
+	}
+	return rule.bucket.wait(ctx)
+}
+
+// tokenBucket refills rate tokens per second up to burst, tokens may be negative for reserved waits
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token and returns the delay until it is available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	delay := b.reserve(now)
+	if delay <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+		b.cancel()
+		return errors.Errorf("rate limit wait %v exceeds context deadline", delay)
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
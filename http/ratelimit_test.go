@@ -0,0 +1,84 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/longportapp/openapi-go/config"
+)
+
+func TestRateLimiterMatch(t *testing.T) {
+	l := NewRateLimiter(DefaultRateLimits())
+	assertEqual(t, "/v1/trade/", l.match("post", "/v1/trade/order").path)
+	assertEqual(t, "/v1/trade/", l.match("GET", "/v1/trade/execution/today").path)
+	assertEqual(t, "/v1/quote/", l.match("GET", "/v1/quote/history/candlestick").path)
+	assertEqual(t, true, l.match("GET", "/v1/socket/token") == nil)
+	// trade and asset requests share the quota
+	assertEqual(t, true, l.match("POST", "/v1/trade/order").bucket == l.match("GET", "/v1/asset/account").bucket)
+
+	merged := mergeRateLimits(DefaultRateLimits(), []config.RateLimit{
+		{Path: "/v1/quote/*", Rate: 2, Burst: 2},
+		{Methods: []string{"delete", "post", "put"}, Path: "/v1/trade/order", Rate: 2, Burst: 5},
+	})
+	assertEqual(t, len(DefaultRateLimits())+1, len(merged))
+	assertEqual(t, float64(2), merged[2].Rate)
+	l = NewRateLimiter(merged)
+	assertEqual(t, "/v1/trade/order", l.match("POST", "/v1/trade/order").path)
+	assertEqual(t, "/v1/trade/", l.match("GET", "/v1/trade/order").path)
+}
+
+// maxInWindow returns the max number of admissions in any window of d
+func maxInWindow(admissions []time.Time, d time.Duration) int {
+	max, start := 0, 0
+	for end := range admissions {
+		for admissions[end].Sub(admissions[start]) >= d {
+			start++
+		}
+		if n := end - start + 1; n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+func TestDefaultRateLimitsQuota(t *testing.T) {
+	l := NewRateLimiter(DefaultRateLimits())
+	trade := l.match("POST", "/v1/trade/order").bucket
+	quote := l.match("GET", "/v1/quote/trades").bucket
+
+	// requests are sent as soon as they are admitted, over 5 minutes
+	simulate := func(b *tokenBucket, d time.Duration) []time.Time {
+		var admissions []time.Time
+		now := b.last
+		for end := now.Add(d); now.Before(end); {
+			now = now.Add(b.reserve(now))
+			admissions = append(admissions, now)
+		}
+		return admissions
+	}
+	admissions := simulate(trade, 5*time.Minute)
+	// no 30 seconds admit more than 30 trade requests, a burst of 15 then one per 2 seconds
+	assertEqual(t, true, maxInWindow(admissions, 30*time.Second) <= 30)
+	assertEqual(t, 15+150, len(admissions))
+	// no second admits more than 10 quote requests
+	assertEqual(t, true, maxInWindow(simulate(quote, time.Minute), time.Second) <= 10)
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter([]config.RateLimit{{Path: "/v1/trade/*", Rate: 50, Burst: 2}})
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assertEqual(t, nil, l.Wait(ctx, "GET", "/v1/trade/order/today"))
+	}
+	// 2 at once, then one per 20ms
+	assertEqual(t, true, time.Since(start) >= 35*time.Millisecond)
+
+	// the wait does not fit in the deadline
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	assertEqual(t, true, l.Wait(ctx, "GET", "/v1/trade/order/today") != nil)
+	// other endpoints are not limited
+	assertEqual(t, nil, l.Wait(ctx, "GET", "/v1/socket/token"))
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	nhttp "net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the default max number of attempts of a request, including the first one
+	DefaultMaxAttempts = 3
+	// DefaultRetryBaseDelay is the default delay before the first retry, it doubles for every retry
+	DefaultRetryBaseDelay = 200 * time.Millisecond
+	// DefaultRetryMaxDelay is the default max delay between two attempts
+	DefaultRetryMaxDelay = 5 * time.Second
+)
+
+// RetryPolicy of Client.Call. Requests failed by transport errors, HTTP status 429 or 5xx are retried,
+// after an exponential backoff with jitter, or the delay of the Retry-After header if it is set.
+// GET requests are retried by default, other methods are retried only with WithRetry(true),
+// because they may have been applied by the server, e.g. submitting an order.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of attempts including the first one, 1 means no retry
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles for every retry
+	BaseDelay time.Duration
+	// MaxDelay is the max delay between two attempts, including the delay of Retry-After
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by Client if WithRetryPolicy is not set
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultRetryBaseDelay,
+		MaxDelay:    DefaultRetryMaxDelay,
+	}
+}
+
+// backoff returns the delay before the retry of attempt, attempt starts from 1,
+// it is a random duration in [d/2, d] where d is BaseDelay * 2^(attempt-1) capped by MaxDelay
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << uint(attempt-1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// delay returns the delay before the retry of attempt, Retry-After of the response takes precedence
+func (p *RetryPolicy) delay(attempt int, resp *Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxDelay > 0 && d > p.MaxDelay {
+				d = p.MaxDelay
+			}
+			return d
+		}
+	}
+	return p.backoff(attempt)
+}
+
+// retryAfter parses Retry-After in seconds or HTTP date
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := nhttp.ParseTime(v); err == nil {
+		d := time.Until(at)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// retryable reports whether the attempt failed by a transport error, HTTP status 429 or 5xx
+func retryable(ctx context.Context, resp *Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.HttpStatus == nhttp.StatusTooManyRequests || resp.HttpStatus >= nhttp.StatusInternalServerError
+}
+
+// sleep waits for d, it returns false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
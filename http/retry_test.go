@@ -0,0 +1,110 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	nhttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type attemptServer struct {
+	mu         sync.Mutex
+	statuses   []int // status of each attempt, 200 after the list
+	timestamps []string
+	retryAfter string
+}
+
+func (s *attemptServer) ServeHTTP(w nhttp.ResponseWriter, r *nhttp.Request) {
+	s.mu.Lock()
+	n := len(s.timestamps)
+	s.timestamps = append(s.timestamps, r.Header.Get(headerTimestamp))
+	s.mu.Unlock()
+	status := nhttp.StatusOK
+	if n < len(s.statuses) {
+		status = s.statuses[n]
+	}
+	if status != nhttp.StatusOK && s.retryAfter != "" {
+		w.Header().Set("Retry-After", s.retryAfter)
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	if status == nhttp.StatusOK {
+		fmt.Fprint(w, `{"code":0,"data":{"otp":"ok"}}`)
+	} else {
+		fmt.Fprint(w, `{"code":1,"message":"busy"}`)
+	}
+}
+
+func (s *attemptServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.timestamps)
+}
+
+func newRetryClient(t *testing.T, srv *httptest.Server) *Client {
+	cli, err := New(WithURL(srv.URL), WithAppKey("key"), WithAppSecret("secret"), WithAccessToken("token"),
+		WithRetryPolicy(&RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli
+}
+
+func TestRetryGet(t *testing.T) {
+	s := &attemptServer{statuses: []int{nhttp.StatusServiceUnavailable, nhttp.StatusTooManyRequests}}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	otp, err := newRetryClient(t, srv).GetOTP(context.Background())
+	assertEqual(t, nil, err)
+	assertEqual(t, "ok", otp)
+	assertEqual(t, 3, s.attempts())
+	// every attempt is signed again
+	assertEqual(t, true, s.timestamps[0] < s.timestamps[1] && s.timestamps[1] < s.timestamps[2])
+}
+
+func TestRetryExhausted(t *testing.T) {
+	s := &attemptServer{statuses: []int{500, 500, 500, 500}, retryAfter: "0"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	_, err := newRetryClient(t, srv).GetOTP(context.Background())
+	var apiErr *ApiError
+	assertEqual(t, true, errors.As(err, &apiErr))
+	assertEqual(t, 500, apiErr.HttpStatus)
+	assertEqual(t, 3, s.attempts())
+}
+
+func TestRetryPost(t *testing.T) {
+	s := &attemptServer{statuses: []int{502, 502}, retryAfter: "0"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	cli := newRetryClient(t, srv)
+
+	// not retried by default
+	err := cli.Post(context.Background(), "/v1/trade/order", map[string]string{"symbol": "700.HK"}, nil)
+	assertEqual(t, true, err != nil)
+	assertEqual(t, 1, s.attempts())
+
+	err = cli.Post(context.Background(), "/v1/trade/order", map[string]string{"symbol": "700.HK"}, nil, WithRetry(true))
+	assertEqual(t, nil, err)
+	assertEqual(t, 3, s.attempts())
+}
+
+func TestRetryAfter(t *testing.T) {
+	d, ok := retryAfter("2")
+	assertEqual(t, true, ok)
+	assertEqual(t, 2*time.Second, d)
+	_, ok = retryAfter("soon")
+	assertEqual(t, false, ok)
+
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt := 1; attempt <= 4; attempt++ {
+		d := p.backoff(attempt)
+		assertEqual(t, true, d >= 50*time.Millisecond && d <= 300*time.Millisecond)
+	}
+}
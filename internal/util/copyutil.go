@@ -61,36 +61,76 @@ var opt = copier.Option{
 					return nil, errors.New("convert int64 to time.Time, but src type not matching")
 				}
 
-				if value < 0 {
-					return nil, errors.New("convert int64 to time.Time, but src value is less than 0")
-				}
-
-				// Check if it's seconds (most common case)
-				if value <= 4102444800 { // ~2106-02-07
-					return time.Unix(value, 0), nil
-				}
-
-				// Check if it's milliseconds
-				if value <= 4102444800000 { // ~2286-11-20
-					return time.Unix(0, value*int64(time.Millisecond)), nil
-				}
-
-				// Check if it's microseconds
-				if value <= 4102444800000000 { // ~2262-04-11
-					return time.Unix(0, value*int64(time.Microsecond)), nil
-				}
+				return unixToTime(value)
+			},
+		},
+		{
+			SrcType: copier.String,
+			DstType: time.Time{},
+			Fn: func(src interface{}) (interface{}, error) {
+				value, ok := src.(string)
 
-				// Check if it's nanoseconds
-				if value <= 4102444800000000000 { // ~2262-04-11
-					return time.Unix(0, value), nil
+				if !ok {
+					return nil, errors.New("convert string to time.Time, but src type not matching")
 				}
 
-				return nil, errors.New("convert int64 to time.Time, but src value is not valid")
+				return parseTime(value), nil
 			},
 		},
 	},
 }
 
+// parseTime parses a unix timestamp, a date or a RFC3339 time leniently, it returns zero time for "", "0"
+// and values in other formats, so one odd value does not fail the whole Copy, the callers keep the original values in Raw
+func parseTime(value string) time.Time {
+	if value == "" || value == "0" {
+		return time.Time{}
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		t, err := unixToTime(ts)
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	}
+	// dates, e.g. expire_date of orders
+	for _, layout := range []string{DateLayout, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// unixToTime converts a unix timestamp in seconds, milliseconds, microseconds or nanoseconds to time.Time
+func unixToTime(value int64) (time.Time, error) {
+	if value < 0 {
+		return time.Time{}, errors.New("convert unix timestamp to time.Time, but value is less than 0")
+	}
+
+	// Check if it's seconds (most common case)
+	if value <= 4102444800 { // ~2106-02-07
+		return time.Unix(value, 0), nil
+	}
+
+	// Check if it's milliseconds
+	if value <= 4102444800000 { // ~2286-11-20
+		return time.Unix(0, value*int64(time.Millisecond)), nil
+	}
+
+	// Check if it's microseconds
+	if value <= 4102444800000000 { // ~2262-04-11
+		return time.Unix(0, value*int64(time.Microsecond)), nil
+	}
+
+	// Check if it's nanoseconds
+	if value <= 4102444800000000000 { // ~2262-04-11
+		return time.Unix(0, value), nil
+	}
+
+	return time.Time{}, errors.New("convert unix timestamp to time.Time, but value is not valid")
+}
+
 func Copy(toValue interface{}, fromValue interface{}) error {
 	return copier.CopyWithOption(toValue, fromValue, opt)
 }
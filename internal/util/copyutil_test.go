@@ -2,6 +2,7 @@ package util_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/longbridgeapp/assert"
 	"github.com/longportapp/openapi-go/internal/util"
@@ -51,3 +52,15 @@ type To struct {
 	Num    int64
 	Num1   int64
 }
+
+func TestCopyTime(t *testing.T) {
+	from := []*struct{ A, B, C, D, E string }{{A: "1704160821", B: "2024-01-05", C: "0", D: "-1", E: "yesterday"}}
+	var to []*struct{ A, B, C, D, E time.Time }
+	assert.NoError(t, util.Copy(&to, from))
+	assert.Equal(t, time.Unix(1704160821, 0), to[0].A)
+	assert.Equal(t, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), to[0].B)
+	// odd values are zero instead of failing the copy
+	assert.True(t, to[0].C.IsZero())
+	assert.True(t, to[0].D.IsZero())
+	assert.True(t, to[0].E.IsZero())
+}
@@ -0,0 +1,426 @@
+package portfolio
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// MarginSource is the part of trade.TradeContext used by MarginCalculator
+type MarginSource interface {
+	AccountBalance(ctx context.Context, params *trade.GetAccountBalance) ([]*trade.AccountBalance, error)
+	StockPositions(ctx context.Context, symbols []string) ([]*trade.StockPositionChannel, error)
+	MarginRatio(ctx context.Context, symbol string) (trade.MarginRatio, error)
+}
+
+// WhatIfOrder is a hypothetical order filled at Price
+type WhatIfOrder struct {
+	Symbol   string
+	Side     trade.OrderSide
+	Quantity uint64
+	// Price of the fill, zero means the market price
+	Price decimal.Decimal
+	// Currency of the symbol, empty means the currency of the position or the market of the symbol
+	Currency trade.Currency
+}
+
+// MarginPosition is the margin requirement of a stock position
+type MarginPosition struct {
+	Symbol   string
+	Currency trade.Currency
+	Quantity decimal.Decimal // negative for short positions
+	Price    decimal.Decimal // market price in Currency, cost price if there is no market price
+	// MarketValue is signed and in the base currency
+	MarketValue       decimal.Decimal
+	ImFactor          decimal.Decimal
+	MmFactor          decimal.Decimal
+	FmFactor          decimal.Decimal
+	InitMargin        decimal.Decimal // in the base currency
+	MaintenanceMargin decimal.Decimal // in the base currency
+}
+
+// MarginSnapshot is the margin state of an account in the base currency.
+// Margins of a position are its absolute market value multiplied by the factors of trade.MarginRatio,
+// a symbol without a factor is not marginable and requires its full market value.
+type MarginSnapshot struct {
+	Base              trade.Currency
+	Cash              decimal.Decimal // cash of all currencies, negative when financing
+	MarketValue       decimal.Decimal // signed sum of positions
+	NetAssets         decimal.Decimal // Cash + MarketValue
+	InitMargin        decimal.Decimal
+	MaintenanceMargin decimal.Decimal
+	// ExcessLiquidity is NetAssets - MaintenanceMargin, a margin call is triggered when it is negative
+	ExcessLiquidity decimal.Decimal
+	// ExcessEquity is NetAssets - InitMargin, the equity available to open new positions
+	ExcessEquity decimal.Decimal
+	// MarginCallDistance is the fall of the prices of all positions, as a fraction, which makes ExcessLiquidity zero.
+	// It is zero if ExcessLiquidity is already negative, and nil if falling prices can not trigger a margin call.
+	MarginCallDistance *decimal.Decimal
+	// RemainingFinance is the remaining finance amount of AccountBalance
+	RemainingFinance decimal.Decimal
+	Positions        []*MarginPosition // sorted by symbol
+	// Reported is the AccountBalance in the base currency, to compare with the computed margins
+	Reported *trade.AccountBalance
+}
+
+// MarginImpact is the change of margins by hypothetical orders
+type MarginImpact struct {
+	Before *MarginSnapshot
+	After  *MarginSnapshot
+	// InitMarginChange, MaintenanceMarginChange and ExcessLiquidityChange are After minus Before
+	InitMarginChange        decimal.Decimal
+	MaintenanceMarginChange decimal.Decimal
+	ExcessLiquidityChange   decimal.Decimal
+	// MarginCall is true if ExcessLiquidity of After is negative
+	MarginCall bool
+}
+
+// MarginCalculator projects margins of the current positions and hypothetical orders.
+// Positions are priced and converted into the base currency by a Valuer.
+type MarginCalculator struct {
+	src    MarginSource
+	valuer *Valuer
+}
+
+// NewMarginCalculator returns MarginCalculator
+//
+// Example:
+//
+//	valuer := portfolio.NewValuer(rates, portfolio.WithQuotePrices(qctx))
+//	calc := portfolio.NewMarginCalculator(tctx, valuer)
+//	impact, err := calc.WhatIf(context.Background(), trade.CurrencyHKD, &portfolio.WhatIfOrder{
+//	  Symbol: "700.HK", Side: trade.OrderSideBuy, Quantity: 1000,
+//	})
+//	fmt.Println(impact.After.ExcessLiquidity, impact.After.MarginCallDistance)
+func NewMarginCalculator(src MarginSource, valuer *Valuer) *MarginCalculator {
+	return &MarginCalculator{src: src, valuer: valuer}
+}
+
+// marginState is the account state before computing margins
+type marginState struct {
+	base       trade.Currency
+	balance    *trade.AccountBalance
+	cash       decimal.Decimal // in base
+	quantities map[string]decimal.Decimal
+	currencies map[string]trade.Currency
+	costs      map[string]decimal.Decimal
+	fills      map[string]decimal.Decimal // price of hypothetical fills, used without market price
+}
+
+func (m *MarginCalculator) load(ctx context.Context, base trade.Currency) (*marginState, error) {
+	balances, err := m.src.AccountBalance(ctx, &trade.GetAccountBalance{Currency: base})
+	if err != nil {
+		return nil, err
+	}
+	if len(balances) == 0 {
+		return nil, errors.New("no account balance")
+	}
+	st := &marginState{
+		base:       base,
+		balance:    balances[0],
+		quantities: make(map[string]decimal.Decimal),
+		currencies: make(map[string]trade.Currency),
+		costs:      make(map[string]decimal.Decimal),
+		fills:      make(map[string]decimal.Decimal),
+	}
+	for _, b := range balances {
+		if strings.EqualFold(b.Currency, string(base)) {
+			st.balance = b
+			break
+		}
+	}
+	if st.cash, err = m.cash(ctx, st.balance, base); err != nil {
+		return nil, err
+	}
+
+	channels, err := m.src.StockPositions(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range channels {
+		for _, p := range ch.Positions {
+			st.quantities[p.Symbol] = st.quantities[p.Symbol].Add(p.Quantity)
+			st.currencies[p.Symbol] = trade.Currency(strings.ToUpper(p.Currency))
+			st.costs[p.Symbol] = decOrZero(p.CostPrice)
+		}
+	}
+	return st, nil
+}
+
+// cash returns the cash of all currencies of the balance in base currency, cash of a currency is AvailableCash,
+// FrozenCash and SettlingCash of its CashInfo. TotalCash is used if the balance has no CashInfos.
+func (m *MarginCalculator) cash(ctx context.Context, balance *trade.AccountBalance, base trade.Currency) (decimal.Decimal, error) {
+	if len(balance.CashInfos) == 0 {
+		rate, err := m.valuer.rates.Rate(ctx, trade.Currency(strings.ToUpper(balance.Currency)), base)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return decOrZero(balance.TotalCash).Mul(rate), nil
+	}
+	cash := decimal.Zero
+	for _, ci := range balance.CashInfos {
+		amount := decOrZero(ci.AvailableCash).Add(decOrZero(ci.FrozenCash)).Add(decOrZero(ci.SettlingCash))
+		if amount.IsZero() {
+			continue
+		}
+		rate, err := m.valuer.rates.Rate(ctx, trade.Currency(strings.ToUpper(ci.Currency)), base)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		cash = cash.Add(amount.Mul(rate))
+	}
+	return cash, nil
+}
+
+// Snapshot returns the margins of the current positions in base currency
+func (m *MarginCalculator) Snapshot(ctx context.Context, base trade.Currency) (*MarginSnapshot, error) {
+	st, err := m.load(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	return m.compute(ctx, st, nil)
+}
+
+// WhatIf returns the margins before and after the hypothetical orders are filled, fees are not included
+func (m *MarginCalculator) WhatIf(ctx context.Context, base trade.Currency, orders ...*WhatIfOrder) (*MarginImpact, error) {
+	st, err := m.load(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	ratios := make(map[string]trade.MarginRatio)
+	before, err := m.compute(ctx, st, ratios)
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []string
+	for _, o := range orders {
+		if o.Price.IsZero() {
+			symbols = append(symbols, o.Symbol)
+		}
+	}
+	prices, err := m.valuer.stockPrices(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		currency := o.Currency
+		if currency == "" {
+			currency = st.currencies[o.Symbol]
+		}
+		if currency == "" {
+			currency = marketCurrency(trade.SymbolMarket(o.Symbol))
+		}
+		if currency == "" {
+			return nil, errors.Errorf("unknown currency of %s", o.Symbol)
+		}
+		price := o.Price
+		if price.IsZero() {
+			price = prices[o.Symbol]
+		}
+		if price.IsZero() {
+			price = st.costs[o.Symbol]
+		}
+		if !price.IsPositive() {
+			return nil, errors.Errorf("no price of %s", o.Symbol)
+		}
+		rate, err := m.valuer.rates.Rate(ctx, currency, base)
+		if err != nil {
+			return nil, err
+		}
+		qty := decimal.NewFromInt(int64(o.Quantity))
+		amount := qty.Mul(price).Mul(rate)
+		if o.Side == trade.OrderSideSell {
+			qty = qty.Neg()
+			amount = amount.Neg()
+		}
+		st.cash = st.cash.Sub(amount)
+		st.quantities[o.Symbol] = st.quantities[o.Symbol].Add(qty)
+		st.currencies[o.Symbol] = currency
+		st.fills[o.Symbol] = price
+	}
+
+	after, err := m.compute(ctx, st, ratios)
+	if err != nil {
+		return nil, err
+	}
+	return &MarginImpact{
+		Before:                  before,
+		After:                   after,
+		InitMarginChange:        after.InitMargin.Sub(before.InitMargin),
+		MaintenanceMarginChange: after.MaintenanceMargin.Sub(before.MaintenanceMargin),
+		ExcessLiquidityChange:   after.ExcessLiquidity.Sub(before.ExcessLiquidity),
+		MarginCall:              after.ExcessLiquidity.IsNegative(),
+	}, nil
+}
+
+// MaxPurchaseQuantity estimates the max quantity of symbol to buy at price, comparable to
+// trade.TradeContext.EstimateMaxPurchaseQuantity. CashMaxQty is bounded by the cash,
+// MarginMaxQty by ExcessEquity and the remaining finance amount. Zero price means the market price.
+// Fees and lot sizes are not included.
+func (m *MarginCalculator) MaxPurchaseQuantity(ctx context.Context, base trade.Currency, symbol string, price decimal.Decimal) (resp trade.EstimateMaxPurchaseQuantityResponse, err error) {
+	st, err := m.load(ctx, base)
+	if err != nil {
+		return
+	}
+	ratios := make(map[string]trade.MarginRatio)
+	snap, err := m.compute(ctx, st, ratios)
+	if err != nil {
+		return
+	}
+	if price.IsZero() {
+		prices, err := m.valuer.stockPrices(ctx, []string{symbol})
+		if err != nil {
+			return resp, err
+		}
+		price = prices[symbol]
+	}
+	if !price.IsPositive() {
+		return resp, errors.Errorf("no price of %s", symbol)
+	}
+	currency := st.currencies[symbol]
+	if currency == "" {
+		currency = marketCurrency(trade.SymbolMarket(symbol))
+	}
+	rate, err := m.valuer.rates.Rate(ctx, currency, base)
+	if err != nil {
+		return
+	}
+	unit := price.Mul(rate)
+	ratio, err := m.ratio(ctx, symbol, ratios)
+	if err != nil {
+		return
+	}
+	im, _, _ := marginFactors(ratio)
+
+	if snap.Cash.IsPositive() {
+		resp.CashMaxQty = snap.Cash.Div(unit).Floor().IntPart()
+	}
+	margin := snap.ExcessEquity.Div(unit.Mul(im))
+	if funding := snap.Cash.Add(snap.RemainingFinance).Div(unit); funding.LessThan(margin) {
+		margin = funding
+	}
+	if margin.IsPositive() {
+		resp.MarginMaxQty = margin.Floor().IntPart()
+	}
+	return resp, nil
+}
+
+func (m *MarginCalculator) ratio(ctx context.Context, symbol string, cache map[string]trade.MarginRatio) (trade.MarginRatio, error) {
+	if r, ok := cache[symbol]; ok {
+		return r, nil
+	}
+	r, err := m.src.MarginRatio(ctx, symbol)
+	if err != nil {
+		return r, errors.Wrapf(err, "margin ratio of %s", symbol)
+	}
+	cache[symbol] = r
+	return r, nil
+}
+
+// marginFactors returns the factors of a margin ratio, missing factors mean the symbol is not marginable
+func marginFactors(r trade.MarginRatio) (im, mm, fm decimal.Decimal) {
+	one := decimal.NewFromInt(1)
+	im, mm, fm = one, one, one
+	if r.ImFactor != nil && r.ImFactor.IsPositive() {
+		im = *r.ImFactor
+	}
+	if r.MmFactor != nil && r.MmFactor.IsPositive() {
+		mm = *r.MmFactor
+	} else {
+		mm = im
+	}
+	if r.FmFactor != nil && r.FmFactor.IsPositive() {
+		fm = *r.FmFactor
+	} else {
+		fm = mm
+	}
+	return
+}
+
+func (m *MarginCalculator) compute(ctx context.Context, st *marginState, ratios map[string]trade.MarginRatio) (*MarginSnapshot, error) {
+	if ratios == nil {
+		ratios = make(map[string]trade.MarginRatio)
+	}
+	snap := &MarginSnapshot{
+		Base:             st.base,
+		Cash:             st.cash,
+		RemainingFinance: decOrZero(st.balance.RemainingFinanceAmount),
+		Reported:         st.balance,
+	}
+	var symbols []string
+	for s, qty := range st.quantities {
+		if !qty.IsZero() {
+			symbols = append(symbols, s)
+		}
+	}
+	sort.Strings(symbols)
+	prices, err := m.valuer.stockPrices(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range symbols {
+		price, ok := prices[s]
+		if !ok {
+			if price = st.costs[s]; !price.IsPositive() {
+				price = st.fills[s]
+			}
+		}
+		rate, err := m.valuer.rates.Rate(ctx, st.currencies[s], st.base)
+		if err != nil {
+			return nil, err
+		}
+		ratio, err := m.ratio(ctx, s, ratios)
+		if err != nil {
+			return nil, err
+		}
+		p := &MarginPosition{
+			Symbol:   s,
+			Currency: st.currencies[s],
+			Quantity: st.quantities[s],
+			Price:    price,
+		}
+		p.ImFactor, p.MmFactor, p.FmFactor = marginFactors(ratio)
+		p.MarketValue = p.Quantity.Mul(price).Mul(rate)
+		p.InitMargin = p.MarketValue.Abs().Mul(p.ImFactor)
+		p.MaintenanceMargin = p.MarketValue.Abs().Mul(p.MmFactor)
+		snap.Positions = append(snap.Positions, p)
+		snap.MarketValue = snap.MarketValue.Add(p.MarketValue)
+		snap.InitMargin = snap.InitMargin.Add(p.InitMargin)
+		snap.MaintenanceMargin = snap.MaintenanceMargin.Add(p.MaintenanceMargin)
+	}
+	snap.NetAssets = snap.Cash.Add(snap.MarketValue)
+	snap.ExcessLiquidity = snap.NetAssets.Sub(snap.MaintenanceMargin)
+	snap.ExcessEquity = snap.NetAssets.Sub(snap.InitMargin)
+
+	// all prices fall by x: NetAssets - x * MarketValue = (1 - x) * MaintenanceMargin
+	switch denom := snap.MarketValue.Sub(snap.MaintenanceMargin); {
+	case snap.ExcessLiquidity.IsNegative():
+		d := decimal.Zero
+		snap.MarginCallDistance = &d
+	case denom.IsPositive():
+		if d := snap.ExcessLiquidity.Div(denom); d.LessThanOrEqual(decimal.NewFromInt(1)) {
+			snap.MarginCallDistance = &d
+		}
+	}
+	return snap, nil
+}
+
+// marketCurrency returns the trading currency of stocks in the market
+func marketCurrency(market openapi.Market) trade.Currency {
+	switch market {
+	case openapi.MarketHK:
+		return trade.CurrencyHKD
+	case openapi.MarketUS:
+		return trade.CurrencyUSD
+	case openapi.MarketCN:
+		return trade.CurrencyCNH
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package portfolio_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/portfolio"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+type fakeMargin struct{ fakeSource }
+
+func (fakeMargin) AccountBalance(ctx context.Context, params *trade.GetAccountBalance) ([]*trade.AccountBalance, error) {
+	return []*trade.AccountBalance{{Currency: "HKD", TotalCash: dec("-20000"), RemainingFinanceAmount: dec("50000")}}, nil
+}
+
+func (fakeMargin) MarginRatio(ctx context.Context, symbol string) (trade.MarginRatio, error) {
+	if symbol == "700.HK" {
+		return trade.MarginRatio{ImFactor: dec("0.3"), MmFactor: dec("0.25"), FmFactor: dec("0.2")}, nil
+	}
+	return trade.MarginRatio{ImFactor: dec("0.5"), MmFactor: dec("0.4")}, nil
+}
+
+func TestMarginCalculator(t *testing.T) {
+	ctx := context.Background()
+	rates := portfolio.NewStaticRates(map[portfolio.CurrencyPair]decimal.Decimal{
+		{From: trade.CurrencyUSD, To: trade.CurrencyHKD}: decimal.NewFromInt(8),
+	})
+	calc := portfolio.NewMarginCalculator(fakeMargin{}, portfolio.NewValuer(rates))
+
+	// positions at cost prices: 200 * 300 + 10 * 150 * 8
+	snap, err := calc.Snapshot(ctx, trade.CurrencyHKD)
+	assert.NoError(t, err)
+	assert.Equal(t, "72000", snap.MarketValue.String())
+	assert.Equal(t, "52000", snap.NetAssets.String())
+	assert.Equal(t, "24000", snap.InitMargin.String())
+	assert.Equal(t, "19800", snap.MaintenanceMargin.String())
+	assert.Equal(t, "32200", snap.ExcessLiquidity.String())
+	assert.Equal(t, "0.6169", snap.MarginCallDistance.Round(4).String())
+
+	impact, err := calc.WhatIf(ctx, trade.CurrencyHKD, &portfolio.WhatIfOrder{Symbol: "700.HK", Side: trade.OrderSideBuy, Quantity: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, "33000", impact.After.InitMargin.String())
+	assert.Equal(t, "-7500", impact.ExcessLiquidityChange.String())
+	assert.Equal(t, false, impact.MarginCall)
+
+	// bounded by the remaining finance amount, (50000 - 20000) / 300
+	max, err := calc.MaxPurchaseQuantity(ctx, trade.CurrencyHKD, "700.HK", decimal.NewFromInt(300))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), max.CashMaxQty)
+	assert.Equal(t, int64(100), max.MarginMaxQty)
+}
+
+// estimateFixture is an account with cash in several currencies and the response of
+// EstimateMaxPurchaseQuantity for it
+type estimateFixture struct {
+	fakeMargin
+	estimate trade.EstimateMaxPurchaseQuantityResponse
+}
+
+func (f estimateFixture) AccountBalance(ctx context.Context, params *trade.GetAccountBalance) ([]*trade.AccountBalance, error) {
+	return []*trade.AccountBalance{{
+		Currency: "HKD", TotalCash: dec("20000"), RemainingFinanceAmount: dec("100000"),
+		CashInfos: []*trade.CashInfo{
+			{Currency: "HKD", AvailableCash: dec("19000"), FrozenCash: dec("1000"), SettlingCash: dec("0")},
+			{Currency: "USD", AvailableCash: dec("1000"), FrozenCash: dec("0"), SettlingCash: dec("250")},
+		},
+	}}, nil
+}
+
+func (f estimateFixture) EstimateMaxPurchaseQuantity(ctx context.Context, params *trade.GetEstimateMaxPurchaseQuantity) (trade.EstimateMaxPurchaseQuantityResponse, error) {
+	return f.estimate, nil
+}
+
+func TestMaxPurchaseQuantityEstimate(t *testing.T) {
+	ctx := context.Background()
+	rates := portfolio.NewStaticRates(map[portfolio.CurrencyPair]decimal.Decimal{
+		{From: trade.CurrencyUSD, To: trade.CurrencyHKD}: decimal.NewFromInt(8),
+	})
+	// cash 20000 + 1250 USD, buy 700.HK at 300 with margin factor 0.3:
+	// cash 30000 / 300, margin bounded by the finance (30000 + 100000) / 300
+	fixture := estimateFixture{estimate: trade.EstimateMaxPurchaseQuantityResponse{CashMaxQty: 100, MarginMaxQty: 433}}
+	calc := portfolio.NewMarginCalculator(fixture, portfolio.NewValuer(rates))
+
+	snap, err := calc.Snapshot(ctx, trade.CurrencyHKD)
+	assert.NoError(t, err)
+	assert.Equal(t, "30000", snap.Cash.String())
+
+	max, err := calc.MaxPurchaseQuantity(ctx, trade.CurrencyHKD, "700.HK", decimal.NewFromInt(300))
+	assert.NoError(t, err)
+	estimate, err := fixture.EstimateMaxPurchaseQuantity(ctx, &trade.GetEstimateMaxPurchaseQuantity{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy, Price: decimal.NewFromInt(300),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, estimate.CashMaxQty, max.CashMaxQty)
+	assert.Equal(t, estimate.MarginMaxQty, max.MarginMaxQty)
+}
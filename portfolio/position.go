@@ -0,0 +1,394 @@
+// Package portfolio tracks positions and P&L of an account from trade and quote data
+package portfolio
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/quote"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// TradeSource is the part of trade.TradeContext used to seed and sync a PositionBook
+type TradeSource interface {
+	StockPositions(ctx context.Context, symbols []string) ([]*trade.StockPositionChannel, error)
+	TodayExecutions(ctx context.Context, params *trade.GetTodayExecutions) ([]*trade.Execution, error)
+	TodayOrders(ctx context.Context, params *trade.GetTodayOrders) ([]*trade.Order, error)
+}
+
+// Position is a position with P&L. Quantity is negative for short positions.
+// CostPrice is the average cost, fees are not included.
+type Position struct {
+	Symbol        string
+	Market        openapi.Market
+	Currency      string
+	Quantity      decimal.Decimal
+	CostPrice     decimal.Decimal
+	LastPrice     decimal.Decimal // zero before the first mark
+	MarketValue   decimal.Decimal
+	RealizedPnL   decimal.Decimal
+	UnrealizedPnL decimal.Decimal
+}
+
+func (p *Position) mark(price decimal.Decimal) {
+	p.LastPrice = price
+	p.revalue()
+}
+
+func (p *Position) revalue() {
+	if p.LastPrice.IsZero() {
+		p.MarketValue = decimal.Zero
+		p.UnrealizedPnL = decimal.Zero
+		return
+	}
+	p.MarketValue = p.LastPrice.Mul(p.Quantity)
+	p.UnrealizedPnL = p.LastPrice.Sub(p.CostPrice).Mul(p.Quantity)
+}
+
+// fill applies an execution of signed quantity, positive for buy and negative for sell
+func (p *Position) fill(qty, price decimal.Decimal) {
+	if p.Quantity.IsZero() || p.Quantity.Sign() == qty.Sign() {
+		// open or increase, cost price is the weighted average
+		total := p.Quantity.Add(qty)
+		p.CostPrice = p.CostPrice.Mul(p.Quantity).Add(price.Mul(qty)).Div(total)
+		p.Quantity = total
+	} else {
+		// reduce or reverse, realize P&L of the closed quantity
+		closed := decimal.Min(qty.Abs(), p.Quantity.Abs())
+		if p.Quantity.IsNegative() {
+			closed = closed.Neg()
+		}
+		p.RealizedPnL = p.RealizedPnL.Add(price.Sub(p.CostPrice).Mul(closed))
+		p.Quantity = p.Quantity.Add(qty)
+		switch {
+		case p.Quantity.IsZero():
+			p.CostPrice = decimal.Zero
+		case p.Quantity.Sign() == qty.Sign():
+			// reversed, the rest opens a new position at the execution price
+			p.CostPrice = price
+		}
+	}
+	p.revalue()
+}
+
+// CurrencySummary is P&L of all positions in a currency
+type CurrencySummary struct {
+	Currency      string
+	MarketValue   decimal.Decimal
+	RealizedPnL   decimal.Decimal
+	UnrealizedPnL decimal.Decimal
+}
+
+// orderFill is the executed quantity of an order applied to the book
+type orderFill struct {
+	symbol   string
+	side     trade.OrderSide
+	currency string
+	quantity decimal.Decimal
+	cost     decimal.Decimal // sum of executed price * quantity
+}
+
+// BookOption for PositionBook
+type BookOption func(*PositionBook)
+
+// OnPositionChange to set callback which will be called with a copy of the position when it changes.
+// The callback must not call methods of the PositionBook.
+func OnPositionChange(fn func(Position)) BookOption {
+	return func(b *PositionBook) {
+		b.onChange = append(b.onChange, fn)
+	}
+}
+
+// PositionBook tracks stock positions intraday. It is seeded from StockPositions, applies fills
+// from trade pushes or TodayExecutions and marks to market from quote pushes.
+//
+// Example:
+//
+//	book := portfolio.NewPositionBook(portfolio.OnPositionChange(func(p portfolio.Position) {
+//		log.Printf("%s qty:%s upnl:%s", p.Symbol, p.Quantity, p.UnrealizedPnL)
+//	}))
+//	err = book.Seed(ctx, tctx)
+//	remove := tctx.AddTradeHandler(book.ApplyPush)
+//	qctx.OnQuote(book.ApplyQuote)
+//	_, err = qctx.Subscribe(ctx, book.Symbols(), []quote.SubType{quote.SubTypeQuote}, true)
+type PositionBook struct {
+	mu        sync.Mutex
+	positions map[string]*Position
+	orders    map[string]*orderFill
+	onChange  []func(Position)
+}
+
+// NewPositionBook returns an empty PositionBook
+func NewPositionBook(opt ...BookOption) *PositionBook {
+	b := &PositionBook{
+		positions: make(map[string]*Position),
+		orders:    make(map[string]*orderFill),
+	}
+	for _, o := range opt {
+		o(b)
+	}
+	return b
+}
+
+// Seed replaces the positions with StockPositions of all account channels, then applies today's executions
+// on top of them because StockPositions is not updated intraday. The following pushes and Sync only apply new fills.
+// Realized P&L starts from zero for the positions opened before today.
+func (b *PositionBook) Seed(ctx context.Context, src TradeSource) error {
+	channels, err := src.StockPositions(ctx, nil)
+	if err != nil {
+		return err
+	}
+	orders, err := b.loadFills(ctx, src)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	old := b.positions
+	b.positions = make(map[string]*Position)
+	b.orders = make(map[string]*orderFill)
+	for _, ch := range channels {
+		for _, sp := range ch.Positions {
+			qty := sp.Quantity
+			cost := decimal.Zero
+			if sp.CostPrice != nil {
+				cost = *sp.CostPrice
+			}
+			p := b.position(sp.Symbol, sp.Currency)
+			p.Market = sp.Market
+			// merge channels of the same symbol by weighted cost
+			if total := p.Quantity.Add(qty); !total.IsZero() {
+				p.CostPrice = p.CostPrice.Mul(p.Quantity).Add(cost.Mul(qty)).Div(total)
+			}
+			p.Quantity = p.Quantity.Add(qty)
+		}
+	}
+	ids := make([]string, 0, len(orders))
+	for orderId := range orders {
+		ids = append(ids, orderId)
+	}
+	sort.Strings(ids)
+	for _, orderId := range ids {
+		b.applyFill(orderId, orders[orderId])
+	}
+	changed := make([]Position, 0, len(b.positions))
+	for symbol, p := range b.positions {
+		if o, ok := old[symbol]; ok {
+			p.LastPrice = o.LastPrice
+		}
+		p.revalue()
+		changed = append(changed, *p)
+	}
+	b.mu.Unlock()
+	b.notify(changed...)
+	return nil
+}
+
+// Sync applies fills of TodayExecutions which are not applied yet, e.g. missed pushes after reconnecting
+func (b *PositionBook) Sync(ctx context.Context, src TradeSource) error {
+	orders, err := b.loadFills(ctx, src)
+	if err != nil {
+		return err
+	}
+	var changed []Position
+	b.mu.Lock()
+	for orderId, f := range orders {
+		if p := b.applyFill(orderId, f); p != nil {
+			changed = append(changed, *p)
+		}
+	}
+	b.mu.Unlock()
+	b.notify(changed...)
+	return nil
+}
+
+// loadFills sums today's executions by order, the side of an order comes from TodayOrders,
+// it returns an error if an order of the executions is not in TodayOrders
+func (b *PositionBook) loadFills(ctx context.Context, src TradeSource) (map[string]*orderFill, error) {
+	executions, err := src.TodayExecutions(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	fills := make(map[string]*orderFill)
+	if len(executions) == 0 {
+		return fills, nil
+	}
+	orders, err := src.TodayOrders(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]*trade.Order, len(orders))
+	for _, o := range orders {
+		byId[o.OrderId] = o
+	}
+	var missing []string
+	for _, e := range executions {
+		o, ok := byId[e.OrderId]
+		if !ok {
+			missing = append(missing, e.OrderId)
+			continue
+		}
+		if e.Price == nil {
+			continue
+		}
+		qty := e.Quantity
+		f, ok := fills[e.OrderId]
+		if !ok {
+			f = &orderFill{symbol: e.Symbol, side: o.Side, currency: o.Currency}
+			fills[e.OrderId] = f
+		}
+		f.quantity = f.quantity.Add(qty)
+		f.cost = f.cost.Add(e.Price.Mul(qty))
+	}
+	if len(missing) > 0 {
+		return nil, errors.Errorf("orders %s of today's executions are not in TodayOrders", strings.Join(missing, ","))
+	}
+	return fills, nil
+}
+
+// applyFill applies the new executed quantity of an order by its cumulative fill, must be called with lock
+func (b *PositionBook) applyFill(orderId string, f *orderFill) *Position {
+	prev, ok := b.orders[orderId]
+	if !ok {
+		prev = &orderFill{}
+	}
+	delta := f.quantity.Sub(prev.quantity)
+	if !delta.IsPositive() {
+		return nil
+	}
+	price := f.cost.Sub(prev.cost).Div(delta)
+	b.orders[orderId] = f
+	if f.side == trade.OrderSideSell {
+		delta = delta.Neg()
+	}
+	p := b.position(f.symbol, f.currency)
+	p.fill(delta, price)
+	return p
+}
+
+// position returns the position of symbol, creates it if not exists, must be called with lock
+func (b *PositionBook) position(symbol, currency string) *Position {
+	p, ok := b.positions[symbol]
+	if !ok {
+		p = &Position{Symbol: symbol, Currency: currency, Market: trade.SymbolMarket(symbol)}
+		b.positions[symbol] = p
+	}
+	if p.Currency == "" {
+		p.Currency = currency
+	}
+	return p
+}
+
+// ApplyPush applies fills of an order changed push, use it as the handler of TradeContext.AddTradeHandler
+func (b *PositionBook) ApplyPush(event *trade.PushEvent) {
+	d := event.Data
+	if d == nil || d.ExecutedQuantity == nil || d.ExecutedPrice == nil || !d.ExecutedQuantity.IsPositive() {
+		return
+	}
+	b.mu.Lock()
+	p := b.applyFill(d.OrderId, &orderFill{
+		symbol:   d.Symbol,
+		side:     d.Side,
+		currency: d.Currency,
+		quantity: *d.ExecutedQuantity,
+		cost:     d.ExecutedPrice.Mul(*d.ExecutedQuantity),
+	})
+	var changed []Position
+	if p != nil {
+		changed = append(changed, *p)
+	}
+	b.mu.Unlock()
+	b.notify(changed...)
+}
+
+// ApplyQuote marks the position to the last done price of a quote push, use it as the handler of QuoteContext.OnQuote
+func (b *PositionBook) ApplyQuote(q *quote.PushQuote) {
+	if q == nil || q.LastDone == nil {
+		return
+	}
+	b.Mark(q.Symbol, *q.LastDone)
+}
+
+// Mark marks the position of symbol to price
+func (b *PositionBook) Mark(symbol string, price decimal.Decimal) {
+	if !price.IsPositive() {
+		return
+	}
+	b.mu.Lock()
+	p, ok := b.positions[symbol]
+	if !ok || p.LastPrice.Equal(price) {
+		b.mu.Unlock()
+		return
+	}
+	p.mark(price)
+	changed := *p
+	b.mu.Unlock()
+	b.notify(changed)
+}
+
+// Position returns a copy of the position of symbol
+func (b *PositionBook) Position(symbol string) (Position, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.positions[symbol]
+	if !ok {
+		return Position{}, false
+	}
+	return *p, true
+}
+
+// Positions returns copies of all positions sorted by symbol, closed positions with realized P&L are included
+func (b *PositionBook) Positions() []Position {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := make([]Position, 0, len(b.positions))
+	for _, p := range b.positions {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Symbol < list[j].Symbol })
+	return list
+}
+
+// Symbols returns symbols of all positions
+func (b *PositionBook) Symbols() []string {
+	positions := b.Positions()
+	symbols := make([]string, 0, len(positions))
+	for _, p := range positions {
+		symbols = append(symbols, p.Symbol)
+	}
+	return symbols
+}
+
+// ByCurrency returns P&L summaries grouped by currency sorted by currency
+func (b *PositionBook) ByCurrency() []CurrencySummary {
+	sums := make(map[string]*CurrencySummary)
+	for _, p := range b.Positions() {
+		s, ok := sums[p.Currency]
+		if !ok {
+			s = &CurrencySummary{Currency: p.Currency}
+			sums[p.Currency] = s
+		}
+		s.MarketValue = s.MarketValue.Add(p.MarketValue)
+		s.RealizedPnL = s.RealizedPnL.Add(p.RealizedPnL)
+		s.UnrealizedPnL = s.UnrealizedPnL.Add(p.UnrealizedPnL)
+	}
+	list := make([]CurrencySummary, 0, len(sums))
+	for _, s := range sums {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Currency < list[j].Currency })
+	return list
+}
+
+func (b *PositionBook) notify(changed ...Position) {
+	for _, p := range changed {
+		for _, fn := range b.onChange {
+			fn(p)
+		}
+	}
+}
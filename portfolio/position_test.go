@@ -0,0 +1,88 @@
+package portfolio_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/portfolio"
+	"github.com/longportapp/openapi-go/quote"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+type fakeSource struct{}
+
+func dec(s string) *decimal.Decimal {
+	d := decimal.RequireFromString(s)
+	return &d
+}
+
+func (fakeSource) StockPositions(ctx context.Context, symbols []string) ([]*trade.StockPositionChannel, error) {
+	return []*trade.StockPositionChannel{{
+		AccountChannel: "lb",
+		Positions: []*trade.StockPosition{
+			{Symbol: "700.HK", Quantity: *dec("200"), Currency: "HKD", CostPrice: dec("300")},
+			{Symbol: "AAPL.US", Quantity: *dec("10"), Currency: "USD", CostPrice: dec("150")},
+		},
+	}}, nil
+}
+
+func (fakeSource) TodayExecutions(ctx context.Context, params *trade.GetTodayExecutions) ([]*trade.Execution, error) {
+	return []*trade.Execution{
+		{OrderId: "1", TradeId: "t1", Symbol: "700.HK", Quantity: *dec("100"), Price: dec("300")},
+	}, nil
+}
+
+func (fakeSource) TodayOrders(ctx context.Context, params *trade.GetTodayOrders) ([]*trade.Order, error) {
+	return []*trade.Order{{OrderId: "1", Symbol: "700.HK", Side: trade.OrderSideBuy, Currency: "HKD"}}, nil
+}
+
+func TestPositionBook(t *testing.T) {
+	var changes int
+	book := portfolio.NewPositionBook(portfolio.OnPositionChange(func(portfolio.Position) { changes++ }))
+	assert.NoError(t, book.Seed(context.Background(), fakeSource{}))
+	assert.Equal(t, []string{"700.HK", "AAPL.US"}, book.Symbols())
+
+	// today's buy is applied on top of StockPositions, Sync does not apply it again
+	p, _ := book.Position("700.HK")
+	assert.Equal(t, "300", p.Quantity.String())
+	assert.Equal(t, "300", p.CostPrice.String())
+	assert.NoError(t, book.Sync(context.Background(), fakeSource{}))
+	p, _ = book.Position("700.HK")
+	assert.Equal(t, "300", p.Quantity.String())
+
+	// partial fill then full fill of a sell order
+	book.ApplyPush(&trade.PushEvent{Data: &trade.PushOrderChanged{OrderId: "2", Symbol: "700.HK", Side: trade.OrderSideSell,
+		Currency: "HKD", ExecutedQuantity: dec("100"), ExecutedPrice: dec("310")}})
+	book.ApplyPush(&trade.PushEvent{Data: &trade.PushOrderChanged{OrderId: "2", Symbol: "700.HK", Side: trade.OrderSideSell,
+		Currency: "HKD", ExecutedQuantity: dec("100"), ExecutedPrice: dec("310")}})
+	book.ApplyQuote(&quote.PushQuote{Symbol: "700.HK", LastDone: dec("320")})
+	book.Mark("AAPL.US", decimal.NewFromInt(140))
+
+	p, _ = book.Position("700.HK")
+	assert.Equal(t, "200", p.Quantity.String())
+	assert.Equal(t, "1000", p.RealizedPnL.String())
+	assert.Equal(t, "4000", p.UnrealizedPnL.String())
+	assert.Equal(t, "64000", p.MarketValue.String())
+
+	sums := book.ByCurrency()
+	assert.Equal(t, 2, len(sums))
+	assert.Equal(t, "HKD", sums[0].Currency)
+	assert.Equal(t, "-100", sums[1].UnrealizedPnL.String())
+	assert.Equal(t, 5, changes)
+}
+
+// missingOrderSource has an execution of an order not in TodayOrders
+type missingOrderSource struct{ fakeSource }
+
+func (missingOrderSource) TodayOrders(ctx context.Context, params *trade.GetTodayOrders) ([]*trade.Order, error) {
+	return nil, nil
+}
+
+func TestPositionBookMissingOrder(t *testing.T) {
+	book := portfolio.NewPositionBook()
+	assert.Error(t, book.Seed(context.Background(), missingOrderSource{}))
+	assert.Error(t, book.Sync(context.Background(), missingOrderSource{}))
+}
@@ -0,0 +1,331 @@
+package portfolio
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/quote"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// ErrNoRate is returned by a RateSource when there is no rate of the currency pair
+var ErrNoRate = errors.New("no fx rate")
+
+// CurrencyPair is a pair of currencies, the rate is the price of one From in To
+type CurrencyPair struct {
+	From trade.Currency
+	To   trade.Currency
+}
+
+func (p CurrencyPair) String() string {
+	return string(p.From) + "/" + string(p.To)
+}
+
+func (p CurrencyPair) inverse() CurrencyPair {
+	return CurrencyPair{From: p.To, To: p.From}
+}
+
+// RateSource provides FX rates
+type RateSource interface {
+	// Rate returns the price of one from in to
+	Rate(ctx context.Context, from, to trade.Currency) (decimal.Decimal, error)
+}
+
+func noRate(from, to trade.Currency) error {
+	return errors.Wrapf(ErrNoRate, "%s/%s", from, to)
+}
+
+// StaticRates is a RateSource of manually set rates, inverse rates are derived
+type StaticRates struct {
+	mu    sync.RWMutex
+	rates map[CurrencyPair]decimal.Decimal
+}
+
+// NewStaticRates returns StaticRates with rates
+//
+// Example:
+//
+//	rates := portfolio.NewStaticRates(map[portfolio.CurrencyPair]decimal.Decimal{
+//		{From: trade.CurrencyUSD, To: trade.CurrencyHKD}: decimal.NewFromFloat(7.8),
+//		{From: trade.CurrencyCNH, To: trade.CurrencyHKD}: decimal.NewFromFloat(1.08),
+//	})
+func NewStaticRates(rates map[CurrencyPair]decimal.Decimal) *StaticRates {
+	s := &StaticRates{rates: make(map[CurrencyPair]decimal.Decimal, len(rates))}
+	for p, r := range rates {
+		s.rates[p] = r
+	}
+	return s
+}
+
+// Set sets the rate of the currency pair
+func (s *StaticRates) Set(from, to trade.Currency, rate decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rates[CurrencyPair{From: from, To: to}] = rate
+}
+
+// Rate implements RateSource
+func (s *StaticRates) Rate(ctx context.Context, from, to trade.Currency) (decimal.Decimal, error) {
+	if strings.EqualFold(string(from), string(to)) {
+		return decimal.NewFromInt(1), nil
+	}
+	pair := CurrencyPair{From: from, To: to}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, ok := s.rates[pair]; ok && r.IsPositive() {
+		return r, nil
+	}
+	if r, ok := s.rates[pair.inverse()]; ok && r.IsPositive() {
+		return decimal.NewFromInt(1).Div(r), nil
+	}
+	return decimal.Zero, noRate(from, to)
+}
+
+// QuoteSource is the part of quote.QuoteContext used by QuoteRates and Valuer
+type QuoteSource interface {
+	Quote(ctx context.Context, symbols []string) ([]*quote.SecurityQuote, error)
+}
+
+// QuoteRates is a RateSource which uses the last done price of FX symbols, inverse rates are derived
+type QuoteRates struct {
+	qs      QuoteSource
+	symbols map[CurrencyPair]string
+}
+
+// NewQuoteRates returns QuoteRates with symbols of currency pairs, the symbols must be available in your quote package
+func NewQuoteRates(qs QuoteSource, symbols map[CurrencyPair]string) *QuoteRates {
+	return &QuoteRates{qs: qs, symbols: symbols}
+}
+
+// Rate implements RateSource
+func (s *QuoteRates) Rate(ctx context.Context, from, to trade.Currency) (decimal.Decimal, error) {
+	if strings.EqualFold(string(from), string(to)) {
+		return decimal.NewFromInt(1), nil
+	}
+	pair := CurrencyPair{From: from, To: to}
+	symbol, inverse := s.symbols[pair], false
+	if symbol == "" {
+		symbol, inverse = s.symbols[pair.inverse()], true
+	}
+	if symbol == "" {
+		return decimal.Zero, noRate(from, to)
+	}
+	quotes, err := s.qs.Quote(ctx, []string{symbol})
+	if err != nil {
+		return decimal.Zero, errors.Wrapf(err, "quote fx symbol %s", symbol)
+	}
+	for _, q := range quotes {
+		if q.LastDone != nil && q.LastDone.IsPositive() {
+			if inverse {
+				return decimal.NewFromInt(1).Div(*q.LastDone), nil
+			}
+			return *q.LastDone, nil
+		}
+	}
+	return decimal.Zero, errors.Wrapf(ErrNoRate, "fx symbol %s has no last done price", symbol)
+}
+
+// AccountSource is the part of trade.TradeContext used by Valuer
+type AccountSource interface {
+	AccountBalance(ctx context.Context, params *trade.GetAccountBalance) ([]*trade.AccountBalance, error)
+	StockPositions(ctx context.Context, symbols []string) ([]*trade.StockPositionChannel, error)
+	FundPositions(ctx context.Context, symbols []string) ([]*trade.FundPositionChannel, error)
+}
+
+// CurrencyValuation is the value of assets in a currency
+type CurrencyValuation struct {
+	Currency trade.Currency
+	Rate     decimal.Decimal // price of one Currency in the base currency
+	Cash     decimal.Decimal
+	Settling decimal.Decimal // cash of trades which are not settled yet
+	Stocks   decimal.Decimal
+	Funds    decimal.Decimal
+}
+
+// Valuation is the value of a portfolio in the base currency
+type Valuation struct {
+	Base       trade.Currency
+	Cash       decimal.Decimal
+	Settling   decimal.Decimal // cash of trades which are not settled yet
+	Stocks     decimal.Decimal
+	Funds      decimal.Decimal
+	Total      decimal.Decimal      // Cash + Settling + Stocks + Funds
+	Currencies []*CurrencyValuation // in their own currency, sorted by currency
+}
+
+// ValuerOption for Valuer
+type ValuerOption func(*Valuer)
+
+// WithQuotePrices to value stock positions by last done prices, otherwise by cost prices
+func WithQuotePrices(qs QuoteSource) ValuerOption {
+	return func(v *Valuer) {
+		v.prices = qs
+	}
+}
+
+// WithPositionBook to value stock positions by a PositionBook, positions without marks fall back to cost prices
+func WithPositionBook(book *PositionBook) ValuerOption {
+	return func(v *Valuer) {
+		v.book = book
+	}
+}
+
+// Valuer converts cash, stock positions and fund positions into a base currency
+type Valuer struct {
+	rates  RateSource
+	prices QuoteSource
+	book   *PositionBook
+}
+
+// NewValuer returns a Valuer with FX rate source
+//
+// Example:
+//
+//	valuer := portfolio.NewValuer(rates, portfolio.WithQuotePrices(qctx))
+//	v, err := valuer.Value(context.Background(), tctx, trade.CurrencyHKD)
+//	fmt.Println(v.Total)
+func NewValuer(rates RateSource, opt ...ValuerOption) *Valuer {
+	v := &Valuer{rates: rates}
+	for _, o := range opt {
+		o(v)
+	}
+	return v
+}
+
+// Value returns the valuation in base currency. Cash of a currency is AvailableCash plus FrozenCash,
+// SettlingCash is reported as Settling, both are included in Total.
+func (v *Valuer) Value(ctx context.Context, src AccountSource, base trade.Currency) (*Valuation, error) {
+	items := make(map[trade.Currency]*CurrencyValuation)
+	item := func(currency string) *CurrencyValuation {
+		c := trade.Currency(strings.ToUpper(currency))
+		it, ok := items[c]
+		if !ok {
+			it = &CurrencyValuation{Currency: c}
+			items[c] = it
+		}
+		return it
+	}
+
+	balances, err := src.AccountBalance(ctx, &trade.GetAccountBalance{Currency: base})
+	if err != nil {
+		return nil, err
+	}
+	// every balance lists cash of all currencies, count each currency once
+	seen := make(map[string]bool)
+	for _, b := range balances {
+		for _, ci := range b.CashInfos {
+			if seen[ci.Currency] {
+				continue
+			}
+			seen[ci.Currency] = true
+			it := item(ci.Currency)
+			it.Cash = it.Cash.Add(decOrZero(ci.AvailableCash)).Add(decOrZero(ci.FrozenCash))
+			it.Settling = it.Settling.Add(decOrZero(ci.SettlingCash))
+		}
+	}
+
+	channels, err := src.StockPositions(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var symbols []string
+	for _, ch := range channels {
+		for _, p := range ch.Positions {
+			symbols = append(symbols, p.Symbol)
+		}
+	}
+	prices, err := v.stockPrices(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range channels {
+		for _, p := range ch.Positions {
+			qty := p.Quantity
+			price, ok := prices[p.Symbol]
+			if !ok {
+				price = decOrZero(p.CostPrice)
+			}
+			it := item(p.Currency)
+			it.Stocks = it.Stocks.Add(qty.Mul(price))
+		}
+	}
+
+	funds, err := src.FundPositions(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range funds {
+		for _, p := range ch.Positions {
+			it := item(p.Currency)
+			it.Funds = it.Funds.Add(decOrZero(p.HoldingUnits).Mul(decOrZero(p.CurrentNetAssetValue)))
+		}
+	}
+
+	val := &Valuation{Base: base}
+	for c, it := range items {
+		if it.Cash.IsZero() && it.Settling.IsZero() && it.Stocks.IsZero() && it.Funds.IsZero() {
+			continue
+		}
+		rate, err := v.rates.Rate(ctx, c, base)
+		if err != nil {
+			return nil, err
+		}
+		it.Rate = rate
+		val.Cash = val.Cash.Add(it.Cash.Mul(rate))
+		val.Settling = val.Settling.Add(it.Settling.Mul(rate))
+		val.Stocks = val.Stocks.Add(it.Stocks.Mul(rate))
+		val.Funds = val.Funds.Add(it.Funds.Mul(rate))
+		val.Currencies = append(val.Currencies, it)
+	}
+	val.Total = val.Cash.Add(val.Settling).Add(val.Stocks).Add(val.Funds)
+	sort.Slice(val.Currencies, func(i, j int) bool { return val.Currencies[i].Currency < val.Currencies[j].Currency })
+	return val, nil
+}
+
+// stockPrices returns market prices of symbols, symbols without a price are not in the map
+func (v *Valuer) stockPrices(ctx context.Context, symbols []string) (map[string]decimal.Decimal, error) {
+	prices := make(map[string]decimal.Decimal, len(symbols))
+	if len(symbols) == 0 {
+		return prices, nil
+	}
+	if v.book != nil {
+		for _, s := range symbols {
+			if p, ok := v.book.Position(s); ok && p.LastPrice.IsPositive() {
+				prices[s] = p.LastPrice
+			}
+		}
+	}
+	if v.prices != nil {
+		var missing []string
+		for _, s := range symbols {
+			if _, ok := prices[s]; !ok {
+				missing = append(missing, s)
+			}
+		}
+		if len(missing) == 0 {
+			return prices, nil
+		}
+		quotes, err := v.prices.Quote(ctx, missing)
+		if err != nil {
+			return nil, errors.Wrap(err, "quote stock prices")
+		}
+		for _, q := range quotes {
+			if q.LastDone != nil && q.LastDone.IsPositive() {
+				prices[q.Symbol] = *q.LastDone
+			}
+		}
+	}
+	return prices, nil
+}
+
+func decOrZero(d *decimal.Decimal) decimal.Decimal {
+	if d == nil {
+		return decimal.Zero
+	}
+	return *d
+}
@@ -0,0 +1,52 @@
+package portfolio_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/portfolio"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+type fakeAccount struct{ fakeSource }
+
+func (fakeAccount) AccountBalance(ctx context.Context, params *trade.GetAccountBalance) ([]*trade.AccountBalance, error) {
+	cash := []*trade.CashInfo{
+		{Currency: "HKD", AvailableCash: dec("1000"), FrozenCash: dec("200")},
+		{Currency: "USD", AvailableCash: dec("100"), SettlingCash: dec("-10")},
+		{Currency: "CNH", SettlingCash: dec("50")},
+	}
+	return []*trade.AccountBalance{{Currency: "HKD", CashInfos: cash}, {Currency: "USD", CashInfos: cash}}, nil
+}
+
+func (fakeAccount) FundPositions(ctx context.Context, symbols []string) ([]*trade.FundPositionChannel, error) {
+	return []*trade.FundPositionChannel{{Positions: []*trade.FundPosition{
+		{Symbol: "HK0000447943", Currency: "USD", HoldingUnits: dec("10"), CurrentNetAssetValue: dec("2")},
+	}}}, nil
+}
+
+func TestValuer(t *testing.T) {
+	rates := portfolio.NewStaticRates(map[portfolio.CurrencyPair]decimal.Decimal{
+		{From: trade.CurrencyHKD, To: trade.CurrencyUSD}: decimal.RequireFromString("0.125"),
+		{From: trade.CurrencyCNH, To: trade.CurrencyHKD}: decimal.RequireFromString("1.1"),
+	})
+	v, err := portfolio.NewValuer(rates).Value(context.Background(), fakeAccount{}, trade.CurrencyHKD)
+	assert.NoError(t, err)
+	// cash 1200 + 100 USD, settling -10 USD + 50 CNH, stocks 200*300 + 10*150 USD, funds 20 USD
+	assert.Equal(t, "2000", v.Cash.String())
+	assert.Equal(t, "-25", v.Settling.String())
+	assert.Equal(t, "72000", v.Stocks.String())
+	assert.Equal(t, "160", v.Funds.String())
+	assert.Equal(t, "74135", v.Total.String())
+	assert.Equal(t, 3, len(v.Currencies))
+	assert.Equal(t, trade.CurrencyCNH, v.Currencies[0].Currency)
+	assert.Equal(t, "50", v.Currencies[0].Settling.String())
+	assert.Equal(t, "0", v.Currencies[0].Cash.String())
+
+	_, err = portfolio.NewValuer(rates).Value(context.Background(), fakeAccount{}, trade.Currency("JPY"))
+	assert.Equal(t, true, errors.Is(err, portfolio.ErrNoRate))
+}
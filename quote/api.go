@@ -0,0 +1,63 @@
+package quote
+
+import (
+	"context"
+	"time"
+
+	"github.com/longportapp/openapi-go"
+)
+
+// QuoteAPI is the interface of QuoteContext, depend on it instead of *QuoteContext to replace the client
+// with a fake in tests, see package quote/quotetest.
+type QuoteAPI interface {
+	Profile() *UserProfile
+
+	// push
+	OnQuote(f func(*PushQuote))
+	OnTrade(f func(*PushTrade))
+	OnDepth(f func(*PushDepth))
+	OnBrokers(f func(*PushBrokers))
+	Subscribe(ctx context.Context, symbols []string, subTypes []SubType, isFirstPush bool) (err error)
+	Unsubscribe(ctx context.Context, unSubAll bool, symbols []string, subTypes []SubType) (err error)
+	Subscriptions(ctx context.Context) (subscriptions map[string][]SubType, err error)
+
+	// pull
+	StaticInfo(ctx context.Context, symbols []string) (staticInfos []*StaticInfo, err error)
+	Quote(ctx context.Context, symbols []string) (quotes []*SecurityQuote, err error)
+	OptionQuote(ctx context.Context, symbols []string) (optionQuotes []*OptionQuote, err error)
+	WarrantQuote(ctx context.Context, symbols []string) (warrantQuotes []*WarrantQuote, err error)
+	Depth(ctx context.Context, symbol string) (securityDepth *SecurityDepth, err error)
+	Brokers(ctx context.Context, symbol string) (securityBrokers *SecurityBrokers, err error)
+	Participants(ctx context.Context) (infos []*ParticipantInfo, err error)
+	Trades(ctx context.Context, symbol string, count int32) (trades []*Trade, err error)
+	Intraday(ctx context.Context, symbol string) (lines []*IntradayLine, err error)
+	Candlesticks(ctx context.Context, symbol string, period Period, count int32, adjustType AdjustType) (sticks []*Candlestick, err error)
+	HistoryCandlesticksByOffset(ctx context.Context, symbol string, period Period, adjustType AdjustType, isForward bool, dateTime *time.Time, count int32) (sticks []*Candlestick, err error)
+	HistoryCandlesticksByDate(ctx context.Context, symbol string, period Period, adjustType AdjustType, startDate *time.Time, endDate *time.Time) (sticks []*Candlestick, err error)
+	OptionChainExpiryDateList(ctx context.Context, symbol string) (times []time.Time, err error)
+	OptionChainInfoByDate(ctx context.Context, symbol string, expiryDate *time.Time) (strikePriceInfos []*StrikePriceInfo, err error)
+	WarrantIssuers(ctx context.Context) (infos []*IssuerInfo, err error)
+	WarrantList(ctx context.Context, symbol string, config WarrantFilter, lang WarrantLanguage) (infos []*WarrantInfo, err error)
+	TradingSession(ctx context.Context) (sessions []*MarketTradingSession, err error)
+	TradingDays(ctx context.Context, market openapi.Market, begin *time.Time, end *time.Time) (days *MarketTradingDay, err error)
+	CapitalDistribution(ctx context.Context, symbol string) (capitalDib CapitalDistribution, err error)
+	CapitalFlow(ctx context.Context, symbol string) (capitalFlowLines []CapitalFlowLine, err error)
+	CalcIndex(ctx context.Context, symbols []string, indexes []CalcIndex) (calcIndexes []*SecurityCalcIndex, err error)
+
+	// local store of subscribed data
+	RealtimeQuote(ctx context.Context, symbols []string) ([]*Quote, error)
+	RealtimeDepth(ctx context.Context, symbol string) (*SecurityDepth, error)
+	RealtimeTrades(ctx context.Context, symbol string) ([]*Trade, error)
+	RealtimeBrokers(ctx context.Context, symbol string) (*SecurityBrokers, error)
+
+	// watchlist
+	CreateWatchlistGroup(ctx context.Context, name string, symbols []string) (gid int64, err error)
+	DeleteWatchlistGroup(ctx context.Context, id int64, purge bool) (err error)
+	UpdateWatchlistGroup(ctx context.Context, id int64, name string, symbols []string, mode WatchlistUpdateMode) (err error)
+	WatchedGroups(ctx context.Context) (groupList []*WatchedGroup, err error)
+	SecurityList(ctx context.Context, market openapi.Market, category SecurityListCategory) (list []*Security, err error)
+
+	Close() error
+}
+
+var _ QuoteAPI = (*QuoteContext)(nil)
@@ -527,14 +527,16 @@ func NewFormEnv() (*QuoteContext, error) {
 	return NewFromCfg(cfg)
 }
 
-// NewFromCfg return QuoteContext with config.Config
-func NewFromCfg(cfg *config.Config) (*QuoteContext, error) {
+// NewFromCfg return QuoteContext with config.Config.
+// Additional options are applied after the options from config, e.g. WithHttpMiddleware.
+func NewFromCfg(cfg *config.Config, opt ...Option) (*QuoteContext, error) {
 	httpClient, err := http.New(
 		http.WithAccessToken(cfg.AccessToken),
 		http.WithAppKey(cfg.AppKey),
 		http.WithAppSecret(cfg.AppSecret),
 		http.WithURL(cfg.HttpURL),
 		http.WithLanguage(cfg.Language),
+		http.WithRateLimitConfig(&cfg.RateLimit),
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "create http client error")
@@ -547,7 +549,7 @@ func NewFromCfg(cfg *config.Config) (*QuoteContext, error) {
 		longbridge.WithWriteQueueSize(cfg.WriteQueueSize),
 		longbridge.WithMinGzipSize(cfg.MinGzipSize),
 	)
-	return New(
+	opts := []Option{
 		WithQuoteURL(cfg.QuoteUrl),
 		WithHttpClient(httpClient),
 		WithLogLevel(cfg.LogLevel),
@@ -555,7 +557,8 @@ func NewFromCfg(cfg *config.Config) (*QuoteContext, error) {
 		WithLbOptions(lbOpts),
 		WithEnableOvernight(cfg.EnableOvernight),
 		WithLanguage(cfg.Language),
-	)
+	}
+	return New(append(opts, opt...)...)
 }
 
 // New return QuoteContext with option.
@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/http"
 	"github.com/longportapp/openapi-go/internal/util"
 	"github.com/longportapp/openapi-go/log"
 )
@@ -57,7 +58,7 @@ func newCore(opts *Options) (*core, error) {
 		client.WriteQueueSize(opts.lbOpts.WriteQueueSize),
 	)
 	if err != nil {
-		return nil, err
+		return nil, http.WrapProtocolError(err)
 	}
 
 	core := &core{
@@ -116,7 +117,7 @@ func (c *core) doSubscirbe(ctx context.Context, symbols []string, subTypes []Sub
 	if err != nil {
 		return
 	}
-	_, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_Subscribe), Body: req})
+	_, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_Subscribe), Body: req})
 	if err != nil {
 		return
 	}
@@ -137,7 +138,7 @@ func (c *core) Unsubscribe(ctx context.Context, unSubAll bool, symbols []string,
 	if err != nil {
 		return
 	}
-	_, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_Unsubscribe), Body: req})
+	_, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_Unsubscribe), Body: req})
 	if err != nil {
 		return
 	}
@@ -171,7 +172,7 @@ func (c *core) queryProfile(ctx context.Context, lang string) (quoteProfile *Use
 		Language: lang,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryUserQuoteProfile), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryUserQuoteProfile), Body: req})
 	if err != nil {
 		return
 	}
@@ -189,7 +190,7 @@ func (c *core) queryProfile(ctx context.Context, lang string) (quoteProfile *Use
 func (c *core) Subscriptions(ctx context.Context) (subscriptions map[string][]SubType, err error) {
 	req := &quotev1.SubscriptionRequest{}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_Subscription), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_Subscription), Body: req})
 	if err != nil {
 		return
 	}
@@ -215,7 +216,7 @@ func (c *core) StaticInfo(ctx context.Context, symbols []string) (staticInfos []
 		Symbol: symbols,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QuerySecurityStaticInfo), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QuerySecurityStaticInfo), Body: req})
 	if err != nil {
 		return
 	}
@@ -233,7 +234,7 @@ func (c *core) Quote(ctx context.Context, symbols []string) (quotes []*SecurityQ
 		Symbol: symbols,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QuerySecurityQuote), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QuerySecurityQuote), Body: req})
 	if err != nil {
 		return
 	}
@@ -251,7 +252,7 @@ func (c *core) OptionQuote(ctx context.Context, symbols []string) (optionQuotes
 		Symbol: symbols,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryOptionQuote), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryOptionQuote), Body: req})
 	if err != nil {
 		return
 	}
@@ -269,7 +270,7 @@ func (c *core) WarrantQuote(ctx context.Context, symbols []string) (warrantQuote
 		Symbol: symbols,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryWarrantQuote), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryWarrantQuote), Body: req})
 	if err != nil {
 		return
 	}
@@ -287,7 +288,7 @@ func (c *core) Depth(ctx context.Context, symbol string) (securityDepth *Securit
 		Symbol: symbol,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryDepth), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryDepth), Body: req})
 	if err != nil {
 		return
 	}
@@ -306,7 +307,7 @@ func (c *core) Brokers(ctx context.Context, symbol string) (securityBorkers *Sec
 		Symbol: symbol,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryBrokers), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryBrokers), Body: req})
 	if err != nil {
 		return
 	}
@@ -322,7 +323,7 @@ func (c *core) Brokers(ctx context.Context, symbol string) (securityBorkers *Sec
 
 func (c *core) Participants(ctx context.Context) (infos []*ParticipantInfo, err error) {
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryParticipantBrokerIds)})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryParticipantBrokerIds)})
 	if err != nil {
 		return
 	}
@@ -341,7 +342,7 @@ func (c *core) Trades(ctx context.Context, symbol string, count int32) (trades [
 		Count:  count,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryTrade), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryTrade), Body: req})
 	if err != nil {
 		return
 	}
@@ -359,7 +360,7 @@ func (c *core) Intraday(ctx context.Context, symbol string) (lines []*IntradayLi
 		Symbol: symbol,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryIntraday), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryIntraday), Body: req})
 	if err != nil {
 		return
 	}
@@ -380,7 +381,7 @@ func (c *core) Candlesticks(ctx context.Context, symbol string, period Period, c
 		AdjustType: quotev1.AdjustType(adjustType),
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryCandlestick), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryCandlestick), Body: req})
 	if err != nil {
 		return
 	}
@@ -429,7 +430,7 @@ func (c *core) HistoryCandlesticksByDate(ctx context.Context, symbol string, per
 
 func (c *core) historyCandlesticks(ctx context.Context, req *quotev1.SecurityHistoryCandlestickRequest) (sticks []*Candlestick, err error) {
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryHistoryCandlestick), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryHistoryCandlestick), Body: req})
 	if err != nil {
 		return
 	}
@@ -447,7 +448,7 @@ func (c *core) OptionChainExpiryDateList(ctx context.Context, symbol string) (ti
 		Symbol: symbol,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryOptionChainDate), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryOptionChainDate), Body: req})
 	if err != nil {
 		return
 	}
@@ -469,7 +470,7 @@ func (c *core) OptionChainInfoByDate(ctx context.Context, symbol string, expiryD
 		ExpiryDate: util.FormatDateSimple(expiryDate),
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryOptionChainDateStrikeInfo), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryOptionChainDateStrikeInfo), Body: req})
 	if err != nil {
 		return
 	}
@@ -484,7 +485,7 @@ func (c *core) OptionChainInfoByDate(ctx context.Context, symbol string, expiryD
 
 func (c *core) WarrantIssuers(ctx context.Context) (infos []*IssuerInfo, err error) {
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryWarrantIssuerInfo)})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryWarrantIssuerInfo)})
 	if err != nil {
 		return
 	}
@@ -509,7 +510,7 @@ func (c *core) WarrantList(ctx context.Context, symbol string, config WarrantFil
 		Language:     int32(lang),
 	}
 
-	res, err = c.client.Do(ctx, &client.Request{
+	res, err = c.do(ctx, &client.Request{
 		Cmd:  uint32(quotev1.Command_QueryWarrantFilterList),
 		Body: req,
 	})
@@ -527,7 +528,7 @@ func (c *core) WarrantList(ctx context.Context, symbol string, config WarrantFil
 
 func (c *core) TradingSession(ctx context.Context) (sessions []*MarketTradingSession, err error) {
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryMarketTradePeriod)})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryMarketTradePeriod)})
 	if err != nil {
 		return
 	}
@@ -552,7 +553,7 @@ func (c *core) TradingDays(ctx context.Context, market openapi.Market, begin *ti
 		EndDay: util.FormatDateSimple(end),
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryMarketTradeDay), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryMarketTradeDay), Body: req})
 	if err != nil {
 		return
 	}
@@ -581,7 +582,7 @@ func (c *core) CapitalDistribution(ctx context.Context, symbol string) (capitalD
 		Symbol: symbol,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryCapitalFlowDistribution), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryCapitalFlowDistribution), Body: req})
 	if err != nil {
 		return
 	}
@@ -599,7 +600,7 @@ func (c *core) CapitalFlow(ctx context.Context, symbol string) (capitalFlowLines
 		Symbol: symbol,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryCapitalFlowIntraday), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QueryCapitalFlowIntraday), Body: req})
 	if err != nil {
 		return
 	}
@@ -620,7 +621,7 @@ func (c *core) CalcIndex(ctx context.Context, symbols []string, indexes []CalcIn
 		CalcIndex: quoteCalcIndexes,
 	}
 	var res *protocol.Packet
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QuerySecurityCalcIndex), Body: req})
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(quotev1.Command_QuerySecurityCalcIndex), Body: req})
 	if err != nil {
 		return
 	}
@@ -666,6 +667,12 @@ func (c *core) RealtimeBrokers(ctx context.Context, symbol string) (securityBork
 	}, nil
 }
 
+// do sends the request, errors of the server are classified by the sentinel errors of http, see http.IsRetryable
+func (c *core) do(ctx context.Context, req *client.Request) (*protocol.Packet, error) {
+	res, err := c.client.Do(ctx, req)
+	return res, http.WrapProtocolError(err)
+}
+
 func (c *core) Close() error {
 	return c.client.Close(nil)
 }
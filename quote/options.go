@@ -22,6 +22,7 @@ type Options struct {
 	enableOvernight    bool
 	language           openapi.Language
 	reconnectCallbacks []func(resubFlag bool)
+	httpMiddlewares    []http.Middleware
 }
 
 // Option for quote context
@@ -95,6 +96,13 @@ func OnReconnect(fn func(successResub bool)) Option {
 	}
 }
 
+// WithHttpMiddleware to add middlewares of the http client of quote context, see http.Middleware
+func WithHttpMiddleware(m ...http.Middleware) Option {
+	return func(o *Options) {
+		o.httpMiddlewares = append(o.httpMiddlewares, m...)
+	}
+}
+
 func newOptions(opt ...Option) *Options {
 	opts := Options{
 		quoteURL: DefaultQuoteUrl,
@@ -105,5 +113,8 @@ func newOptions(opt ...Option) *Options {
 	for _, o := range opt {
 		o(&opts)
 	}
+	if opts.httpClient != nil && len(opts.httpMiddlewares) > 0 {
+		opts.httpClient = opts.httpClient.Wrap(opts.httpMiddlewares...)
+	}
 	return &opts
 }
@@ -0,0 +1,517 @@
+// Package quotetest provides a fake quote.QuoteAPI for tests
+package quotetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/quote"
+)
+
+// Fake is an in-memory quote.QuoteAPI.
+// Responses are scripted by the XxxFunc fields, a method returns zero values if its func is nil.
+// Set the func fields before the fake is used concurrently.
+// Errors injected by InjectError are returned before the func is called,
+// push events are delivered to the handlers synchronously by EmitQuote, EmitTrade, EmitDepth and EmitBrokers.
+//
+// Example:
+//
+//	fake := quotetest.New()
+//	price := decimal.NewFromInt(300)
+//	fake.QuoteFunc = func(ctx context.Context, symbols []string) ([]*quote.SecurityQuote, error) {
+//	  return []*quote.SecurityQuote{{Symbol: "700.HK", LastDone: &price}}, nil
+//	}
+//	fake.InjectError("Depth", errors.New("timeout"))
+//	var api quote.QuoteAPI = fake
+//	api.OnQuote(func(q *quote.PushQuote) {})
+//	fake.EmitQuote(&quote.PushQuote{Symbol: "700.HK"})
+type Fake struct {
+	UserProfile *quote.UserProfile
+
+	StaticInfoFunc                  func(context.Context, []string) ([]*quote.StaticInfo, error)
+	QuoteFunc                       func(context.Context, []string) ([]*quote.SecurityQuote, error)
+	OptionQuoteFunc                 func(context.Context, []string) ([]*quote.OptionQuote, error)
+	WarrantQuoteFunc                func(context.Context, []string) ([]*quote.WarrantQuote, error)
+	DepthFunc                       func(context.Context, string) (*quote.SecurityDepth, error)
+	BrokersFunc                     func(context.Context, string) (*quote.SecurityBrokers, error)
+	ParticipantsFunc                func(context.Context) ([]*quote.ParticipantInfo, error)
+	TradesFunc                      func(context.Context, string, int32) ([]*quote.Trade, error)
+	IntradayFunc                    func(context.Context, string) ([]*quote.IntradayLine, error)
+	CandlesticksFunc                func(context.Context, string, quote.Period, int32, quote.AdjustType) ([]*quote.Candlestick, error)
+	HistoryCandlesticksByOffsetFunc func(context.Context, string, quote.Period, quote.AdjustType, bool, *time.Time, int32) ([]*quote.Candlestick, error)
+	HistoryCandlesticksByDateFunc   func(context.Context, string, quote.Period, quote.AdjustType, *time.Time, *time.Time) ([]*quote.Candlestick, error)
+	OptionChainExpiryDateListFunc   func(context.Context, string) ([]time.Time, error)
+	OptionChainInfoByDateFunc       func(context.Context, string, *time.Time) ([]*quote.StrikePriceInfo, error)
+	WarrantIssuersFunc              func(context.Context) ([]*quote.IssuerInfo, error)
+	WarrantListFunc                 func(context.Context, string, quote.WarrantFilter, quote.WarrantLanguage) ([]*quote.WarrantInfo, error)
+	TradingSessionFunc              func(context.Context) ([]*quote.MarketTradingSession, error)
+	TradingDaysFunc                 func(context.Context, openapi.Market, *time.Time, *time.Time) (*quote.MarketTradingDay, error)
+	CapitalDistributionFunc         func(context.Context, string) (quote.CapitalDistribution, error)
+	CapitalFlowFunc                 func(context.Context, string) ([]quote.CapitalFlowLine, error)
+	CalcIndexFunc                   func(context.Context, []string, []quote.CalcIndex) ([]*quote.SecurityCalcIndex, error)
+	RealtimeQuoteFunc               func(context.Context, []string) ([]*quote.Quote, error)
+	RealtimeDepthFunc               func(context.Context, string) (*quote.SecurityDepth, error)
+	RealtimeTradesFunc              func(context.Context, string) ([]*quote.Trade, error)
+	RealtimeBrokersFunc             func(context.Context, string) (*quote.SecurityBrokers, error)
+	CreateWatchlistGroupFunc        func(context.Context, string, []string) (int64, error)
+	DeleteWatchlistGroupFunc        func(context.Context, int64, bool) error
+	UpdateWatchlistGroupFunc        func(context.Context, int64, string, []string, quote.WatchlistUpdateMode) error
+	WatchedGroupsFunc               func(context.Context) ([]*quote.WatchedGroup, error)
+	SecurityListFunc                func(context.Context, openapi.Market, quote.SecurityListCategory) ([]*quote.Security, error)
+
+	mu             sync.Mutex
+	errs           map[string]error
+	calls          map[string]int
+	subscriptions  map[string][]quote.SubType
+	quoteHandler   func(*quote.PushQuote)
+	tradeHandler   func(*quote.PushTrade)
+	depthHandler   func(*quote.PushDepth)
+	brokersHandler func(*quote.PushBrokers)
+	closed         bool
+}
+
+var _ quote.QuoteAPI = (*Fake)(nil)
+
+// New return Fake
+func New() *Fake {
+	return &Fake{
+		errs:          make(map[string]error),
+		calls:         make(map[string]int),
+		subscriptions: make(map[string][]quote.SubType),
+	}
+}
+
+// InjectError makes the method return err until InjectError is called with nil err, method is the name of
+// the quote.QuoteAPI method, e.g. "Quote"
+func (f *Fake) InjectError(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errs, method)
+		return
+	}
+	f.errs[method] = err
+}
+
+// Calls returns how many times the method has been called
+func (f *Fake) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// Closed reports whether Close has been called
+func (f *Fake) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *Fake) call(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[method]++
+	return f.errs[method]
+}
+
+// EmitQuote calls the handler set by OnQuote
+func (f *Fake) EmitQuote(q *quote.PushQuote) {
+	f.mu.Lock()
+	h := f.quoteHandler
+	f.mu.Unlock()
+	if h != nil {
+		h(q)
+	}
+}
+
+// EmitTrade calls the handler set by OnTrade
+func (f *Fake) EmitTrade(t *quote.PushTrade) {
+	f.mu.Lock()
+	h := f.tradeHandler
+	f.mu.Unlock()
+	if h != nil {
+		h(t)
+	}
+}
+
+// EmitDepth calls the handler set by OnDepth
+func (f *Fake) EmitDepth(d *quote.PushDepth) {
+	f.mu.Lock()
+	h := f.depthHandler
+	f.mu.Unlock()
+	if h != nil {
+		h(d)
+	}
+}
+
+// EmitBrokers calls the handler set by OnBrokers
+func (f *Fake) EmitBrokers(b *quote.PushBrokers) {
+	f.mu.Lock()
+	h := f.brokersHandler
+	f.mu.Unlock()
+	if h != nil {
+		h(b)
+	}
+}
+
+// Profile returns UserProfile
+func (f *Fake) Profile() *quote.UserProfile {
+	return f.UserProfile
+}
+
+// OnQuote set the handler called by EmitQuote
+func (f *Fake) OnQuote(h func(*quote.PushQuote)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.quoteHandler = h
+}
+
+// OnTrade set the handler called by EmitTrade
+func (f *Fake) OnTrade(h func(*quote.PushTrade)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tradeHandler = h
+}
+
+// OnDepth set the handler called by EmitDepth
+func (f *Fake) OnDepth(h func(*quote.PushDepth)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.depthHandler = h
+}
+
+// OnBrokers set the handler called by EmitBrokers
+func (f *Fake) OnBrokers(h func(*quote.PushBrokers)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.brokersHandler = h
+}
+
+// Subscribe records the subscriptions returned by Subscriptions
+func (f *Fake) Subscribe(ctx context.Context, symbols []string, subTypes []quote.SubType, isFirstPush bool) (err error) {
+	if err = f.call("Subscribe"); err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, symbol := range symbols {
+		f.subscriptions[symbol] = mergeSubTypes(f.subscriptions[symbol], subTypes, true)
+	}
+	return
+}
+
+// Unsubscribe removes the subscriptions returned by Subscriptions
+func (f *Fake) Unsubscribe(ctx context.Context, unSubAll bool, symbols []string, subTypes []quote.SubType) (err error) {
+	if err = f.call("Unsubscribe"); err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if unSubAll && len(symbols) == 0 {
+		f.subscriptions = make(map[string][]quote.SubType)
+		return
+	}
+	for _, symbol := range symbols {
+		if unSubAll {
+			delete(f.subscriptions, symbol)
+			continue
+		}
+		if types := mergeSubTypes(f.subscriptions[symbol], subTypes, false); len(types) > 0 {
+			f.subscriptions[symbol] = types
+		} else {
+			delete(f.subscriptions, symbol)
+		}
+	}
+	return
+}
+
+// Subscriptions returns the subscriptions recorded by Subscribe and Unsubscribe
+func (f *Fake) Subscriptions(ctx context.Context) (subscriptions map[string][]quote.SubType, err error) {
+	if err = f.call("Subscriptions"); err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subscriptions = make(map[string][]quote.SubType, len(f.subscriptions))
+	for symbol, types := range f.subscriptions {
+		subscriptions[symbol] = append([]quote.SubType(nil), types...)
+	}
+	return
+}
+
+func mergeSubTypes(current, types []quote.SubType, add bool) []quote.SubType {
+	set := make(map[quote.SubType]bool, len(current))
+	for _, t := range current {
+		set[t] = true
+	}
+	for _, t := range types {
+		set[t] = add
+	}
+	var list []quote.SubType
+	for _, t := range current {
+		if set[t] {
+			list = append(list, t)
+			delete(set, t)
+		}
+	}
+	for _, t := range types {
+		if set[t] {
+			list = append(list, t)
+			delete(set, t)
+		}
+	}
+	return list
+}
+
+// StaticInfo calls StaticInfoFunc, it returns zero values if StaticInfoFunc is nil
+func (f *Fake) StaticInfo(ctx context.Context, symbols []string) (staticInfos []*quote.StaticInfo, err error) {
+	if err = f.call("StaticInfo"); err != nil || f.StaticInfoFunc == nil {
+		return
+	}
+	return f.StaticInfoFunc(ctx, symbols)
+}
+
+// Quote calls QuoteFunc, it returns zero values if QuoteFunc is nil
+func (f *Fake) Quote(ctx context.Context, symbols []string) (quotes []*quote.SecurityQuote, err error) {
+	if err = f.call("Quote"); err != nil || f.QuoteFunc == nil {
+		return
+	}
+	return f.QuoteFunc(ctx, symbols)
+}
+
+// OptionQuote calls OptionQuoteFunc, it returns zero values if OptionQuoteFunc is nil
+func (f *Fake) OptionQuote(ctx context.Context, symbols []string) (optionQuotes []*quote.OptionQuote, err error) {
+	if err = f.call("OptionQuote"); err != nil || f.OptionQuoteFunc == nil {
+		return
+	}
+	return f.OptionQuoteFunc(ctx, symbols)
+}
+
+// WarrantQuote calls WarrantQuoteFunc, it returns zero values if WarrantQuoteFunc is nil
+func (f *Fake) WarrantQuote(ctx context.Context, symbols []string) (warrantQuotes []*quote.WarrantQuote, err error) {
+	if err = f.call("WarrantQuote"); err != nil || f.WarrantQuoteFunc == nil {
+		return
+	}
+	return f.WarrantQuoteFunc(ctx, symbols)
+}
+
+// Depth calls DepthFunc, it returns zero values if DepthFunc is nil
+func (f *Fake) Depth(ctx context.Context, symbol string) (securityDepth *quote.SecurityDepth, err error) {
+	if err = f.call("Depth"); err != nil || f.DepthFunc == nil {
+		return
+	}
+	return f.DepthFunc(ctx, symbol)
+}
+
+// Brokers calls BrokersFunc, it returns zero values if BrokersFunc is nil
+func (f *Fake) Brokers(ctx context.Context, symbol string) (securityBrokers *quote.SecurityBrokers, err error) {
+	if err = f.call("Brokers"); err != nil || f.BrokersFunc == nil {
+		return
+	}
+	return f.BrokersFunc(ctx, symbol)
+}
+
+// Participants calls ParticipantsFunc, it returns zero values if ParticipantsFunc is nil
+func (f *Fake) Participants(ctx context.Context) (infos []*quote.ParticipantInfo, err error) {
+	if err = f.call("Participants"); err != nil || f.ParticipantsFunc == nil {
+		return
+	}
+	return f.ParticipantsFunc(ctx)
+}
+
+// Trades calls TradesFunc, it returns zero values if TradesFunc is nil
+func (f *Fake) Trades(ctx context.Context, symbol string, count int32) (trades []*quote.Trade, err error) {
+	if err = f.call("Trades"); err != nil || f.TradesFunc == nil {
+		return
+	}
+	return f.TradesFunc(ctx, symbol, count)
+}
+
+// Intraday calls IntradayFunc, it returns zero values if IntradayFunc is nil
+func (f *Fake) Intraday(ctx context.Context, symbol string) (lines []*quote.IntradayLine, err error) {
+	if err = f.call("Intraday"); err != nil || f.IntradayFunc == nil {
+		return
+	}
+	return f.IntradayFunc(ctx, symbol)
+}
+
+// Candlesticks calls CandlesticksFunc, it returns zero values if CandlesticksFunc is nil
+func (f *Fake) Candlesticks(ctx context.Context, symbol string, period quote.Period, count int32, adjustType quote.AdjustType) (sticks []*quote.Candlestick, err error) {
+	if err = f.call("Candlesticks"); err != nil || f.CandlesticksFunc == nil {
+		return
+	}
+	return f.CandlesticksFunc(ctx, symbol, period, count, adjustType)
+}
+
+// HistoryCandlesticksByOffset calls HistoryCandlesticksByOffsetFunc, it returns zero values if HistoryCandlesticksByOffsetFunc is nil
+func (f *Fake) HistoryCandlesticksByOffset(ctx context.Context, symbol string, period quote.Period, adjustType quote.AdjustType, isForward bool, dateTime *time.Time, count int32) (sticks []*quote.Candlestick, err error) {
+	if err = f.call("HistoryCandlesticksByOffset"); err != nil || f.HistoryCandlesticksByOffsetFunc == nil {
+		return
+	}
+	return f.HistoryCandlesticksByOffsetFunc(ctx, symbol, period, adjustType, isForward, dateTime, count)
+}
+
+// HistoryCandlesticksByDate calls HistoryCandlesticksByDateFunc, it returns zero values if HistoryCandlesticksByDateFunc is nil
+func (f *Fake) HistoryCandlesticksByDate(ctx context.Context, symbol string, period quote.Period, adjustType quote.AdjustType, startDate *time.Time, endDate *time.Time) (sticks []*quote.Candlestick, err error) {
+	if err = f.call("HistoryCandlesticksByDate"); err != nil || f.HistoryCandlesticksByDateFunc == nil {
+		return
+	}
+	return f.HistoryCandlesticksByDateFunc(ctx, symbol, period, adjustType, startDate, endDate)
+}
+
+// OptionChainExpiryDateList calls OptionChainExpiryDateListFunc, it returns zero values if OptionChainExpiryDateListFunc is nil
+func (f *Fake) OptionChainExpiryDateList(ctx context.Context, symbol string) (times []time.Time, err error) {
+	if err = f.call("OptionChainExpiryDateList"); err != nil || f.OptionChainExpiryDateListFunc == nil {
+		return
+	}
+	return f.OptionChainExpiryDateListFunc(ctx, symbol)
+}
+
+// OptionChainInfoByDate calls OptionChainInfoByDateFunc, it returns zero values if OptionChainInfoByDateFunc is nil
+func (f *Fake) OptionChainInfoByDate(ctx context.Context, symbol string, expiryDate *time.Time) (strikePriceInfos []*quote.StrikePriceInfo, err error) {
+	if err = f.call("OptionChainInfoByDate"); err != nil || f.OptionChainInfoByDateFunc == nil {
+		return
+	}
+	return f.OptionChainInfoByDateFunc(ctx, symbol, expiryDate)
+}
+
+// WarrantIssuers calls WarrantIssuersFunc, it returns zero values if WarrantIssuersFunc is nil
+func (f *Fake) WarrantIssuers(ctx context.Context) (infos []*quote.IssuerInfo, err error) {
+	if err = f.call("WarrantIssuers"); err != nil || f.WarrantIssuersFunc == nil {
+		return
+	}
+	return f.WarrantIssuersFunc(ctx)
+}
+
+// WarrantList calls WarrantListFunc, it returns zero values if WarrantListFunc is nil
+func (f *Fake) WarrantList(ctx context.Context, symbol string, config quote.WarrantFilter, lang quote.WarrantLanguage) (infos []*quote.WarrantInfo, err error) {
+	if err = f.call("WarrantList"); err != nil || f.WarrantListFunc == nil {
+		return
+	}
+	return f.WarrantListFunc(ctx, symbol, config, lang)
+}
+
+// TradingSession calls TradingSessionFunc, it returns zero values if TradingSessionFunc is nil
+func (f *Fake) TradingSession(ctx context.Context) (sessions []*quote.MarketTradingSession, err error) {
+	if err = f.call("TradingSession"); err != nil || f.TradingSessionFunc == nil {
+		return
+	}
+	return f.TradingSessionFunc(ctx)
+}
+
+// TradingDays calls TradingDaysFunc, it returns zero values if TradingDaysFunc is nil
+func (f *Fake) TradingDays(ctx context.Context, market openapi.Market, begin *time.Time, end *time.Time) (days *quote.MarketTradingDay, err error) {
+	if err = f.call("TradingDays"); err != nil || f.TradingDaysFunc == nil {
+		return
+	}
+	return f.TradingDaysFunc(ctx, market, begin, end)
+}
+
+// CapitalDistribution calls CapitalDistributionFunc, it returns zero values if CapitalDistributionFunc is nil
+func (f *Fake) CapitalDistribution(ctx context.Context, symbol string) (capitalDib quote.CapitalDistribution, err error) {
+	if err = f.call("CapitalDistribution"); err != nil || f.CapitalDistributionFunc == nil {
+		return
+	}
+	return f.CapitalDistributionFunc(ctx, symbol)
+}
+
+// CapitalFlow calls CapitalFlowFunc, it returns zero values if CapitalFlowFunc is nil
+func (f *Fake) CapitalFlow(ctx context.Context, symbol string) (capitalFlowLines []quote.CapitalFlowLine, err error) {
+	if err = f.call("CapitalFlow"); err != nil || f.CapitalFlowFunc == nil {
+		return
+	}
+	return f.CapitalFlowFunc(ctx, symbol)
+}
+
+// CalcIndex calls CalcIndexFunc, it returns zero values if CalcIndexFunc is nil
+func (f *Fake) CalcIndex(ctx context.Context, symbols []string, indexes []quote.CalcIndex) (calcIndexes []*quote.SecurityCalcIndex, err error) {
+	if err = f.call("CalcIndex"); err != nil || f.CalcIndexFunc == nil {
+		return
+	}
+	return f.CalcIndexFunc(ctx, symbols, indexes)
+}
+
+// RealtimeQuote calls RealtimeQuoteFunc, it returns zero values if RealtimeQuoteFunc is nil
+func (f *Fake) RealtimeQuote(ctx context.Context, symbols []string) (quotes []*quote.Quote, err error) {
+	if err = f.call("RealtimeQuote"); err != nil || f.RealtimeQuoteFunc == nil {
+		return
+	}
+	return f.RealtimeQuoteFunc(ctx, symbols)
+}
+
+// RealtimeDepth calls RealtimeDepthFunc, it returns zero values if RealtimeDepthFunc is nil
+func (f *Fake) RealtimeDepth(ctx context.Context, symbol string) (depth *quote.SecurityDepth, err error) {
+	if err = f.call("RealtimeDepth"); err != nil || f.RealtimeDepthFunc == nil {
+		return
+	}
+	return f.RealtimeDepthFunc(ctx, symbol)
+}
+
+// RealtimeTrades calls RealtimeTradesFunc, it returns zero values if RealtimeTradesFunc is nil
+func (f *Fake) RealtimeTrades(ctx context.Context, symbol string) (trades []*quote.Trade, err error) {
+	if err = f.call("RealtimeTrades"); err != nil || f.RealtimeTradesFunc == nil {
+		return
+	}
+	return f.RealtimeTradesFunc(ctx, symbol)
+}
+
+// RealtimeBrokers calls RealtimeBrokersFunc, it returns zero values if RealtimeBrokersFunc is nil
+func (f *Fake) RealtimeBrokers(ctx context.Context, symbol string) (brokers *quote.SecurityBrokers, err error) {
+	if err = f.call("RealtimeBrokers"); err != nil || f.RealtimeBrokersFunc == nil {
+		return
+	}
+	return f.RealtimeBrokersFunc(ctx, symbol)
+}
+
+// CreateWatchlistGroup calls CreateWatchlistGroupFunc, it returns zero values if CreateWatchlistGroupFunc is nil
+func (f *Fake) CreateWatchlistGroup(ctx context.Context, name string, symbols []string) (gid int64, err error) {
+	if err = f.call("CreateWatchlistGroup"); err != nil || f.CreateWatchlistGroupFunc == nil {
+		return
+	}
+	return f.CreateWatchlistGroupFunc(ctx, name, symbols)
+}
+
+// DeleteWatchlistGroup calls DeleteWatchlistGroupFunc, it returns zero values if DeleteWatchlistGroupFunc is nil
+func (f *Fake) DeleteWatchlistGroup(ctx context.Context, id int64, purge bool) (err error) {
+	if err = f.call("DeleteWatchlistGroup"); err != nil || f.DeleteWatchlistGroupFunc == nil {
+		return
+	}
+	return f.DeleteWatchlistGroupFunc(ctx, id, purge)
+}
+
+// UpdateWatchlistGroup calls UpdateWatchlistGroupFunc, it returns zero values if UpdateWatchlistGroupFunc is nil
+func (f *Fake) UpdateWatchlistGroup(ctx context.Context, id int64, name string, symbols []string, mode quote.WatchlistUpdateMode) (err error) {
+	if err = f.call("UpdateWatchlistGroup"); err != nil || f.UpdateWatchlistGroupFunc == nil {
+		return
+	}
+	return f.UpdateWatchlistGroupFunc(ctx, id, name, symbols, mode)
+}
+
+// WatchedGroups calls WatchedGroupsFunc, it returns zero values if WatchedGroupsFunc is nil
+func (f *Fake) WatchedGroups(ctx context.Context) (groupList []*quote.WatchedGroup, err error) {
+	if err = f.call("WatchedGroups"); err != nil || f.WatchedGroupsFunc == nil {
+		return
+	}
+	return f.WatchedGroupsFunc(ctx)
+}
+
+// SecurityList calls SecurityListFunc, it returns zero values if SecurityListFunc is nil
+func (f *Fake) SecurityList(ctx context.Context, market openapi.Market, category quote.SecurityListCategory) (list []*quote.Security, err error) {
+	if err = f.call("SecurityList"); err != nil || f.SecurityListFunc == nil {
+		return
+	}
+	return f.SecurityListFunc(ctx, market, category)
+}
+
+// Close marks the fake closed
+func (f *Fake) Close() error {
+	if err := f.call("Close"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,39 @@
+package quotetest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/longportapp/openapi-go/quote"
+	"github.com/longportapp/openapi-go/quote/quotetest"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	fake := quotetest.New()
+	var api quote.QuoteAPI = fake
+
+	assert.NoError(t, api.Subscribe(ctx, []string{"700.HK", "AAPL.US"}, []quote.SubType{quote.SubTypeQuote, quote.SubTypeDepth}, true))
+	assert.NoError(t, api.Unsubscribe(ctx, false, []string{"700.HK"}, []quote.SubType{quote.SubTypeDepth}))
+	subs, err := api.Subscriptions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []quote.SubType{quote.SubTypeQuote}, subs["700.HK"])
+	assert.Equal(t, 2, len(subs["AAPL.US"]))
+
+	var pushed []string
+	api.OnQuote(func(q *quote.PushQuote) { pushed = append(pushed, q.Symbol) })
+	fake.EmitQuote(&quote.PushQuote{Symbol: "700.HK"})
+	assert.Equal(t, []string{"700.HK"}, pushed)
+
+	fake.DepthFunc = func(ctx context.Context, symbol string) (*quote.SecurityDepth, error) {
+		return &quote.SecurityDepth{Symbol: symbol}, nil
+	}
+	depth, err := api.Depth(ctx, "700.HK")
+	assert.NoError(t, err)
+	assert.Equal(t, "700.HK", depth.Symbol)
+	fake.InjectError("Depth", context.DeadlineExceeded)
+	_, err = api.Depth(ctx, "700.HK")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,520 @@
+// Package algo provides execution algorithms which slice a parent instruction into child orders of TradeContext
+package algo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/log"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// Trader is the part of trade.TradeContext used by algorithms
+type Trader interface {
+	SubmitOrder(ctx context.Context, params *trade.SubmitOrder) (orderId string, err error)
+	ReplaceOrder(ctx context.Context, params *trade.ReplaceOrder) (err error)
+	CancelOrder(ctx context.Context, orderId string) (err error)
+	OrderDetail(ctx context.Context, orderId string) (orderDetail trade.OrderDetail, err error)
+	AddTradeHandler(f func(*trade.PushEvent)) (remove func())
+}
+
+// State of an algorithm
+type State string
+
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StatePaused    State = "paused"
+	StateCompleted State = "completed"
+	StateCanceled  State = "canceled"
+	StateFailed    State = "failed"
+)
+
+// Finished reports whether the algorithm is stopped
+func (s State) Finished() bool {
+	return s == StateCompleted || s == StateCanceled || s == StateFailed
+}
+
+// Instruction is the parent order to execute
+type Instruction struct {
+	Symbol   string
+	Side     trade.OrderSide
+	Quantity uint64
+	StartAt  time.Time
+	EndAt    time.Time
+	// LimitPrice of child orders, zero means market orders
+	LimitPrice decimal.Decimal
+	// LotSize rounds child order quantity down to a multiple of it, default is 1.
+	// The last slice sends all the remaining quantity.
+	LotSize uint64
+	Remark  string
+}
+
+func (ins *Instruction) validate() error {
+	switch {
+	case ins.Symbol == "":
+		return errors.New("algo instruction symbol is required")
+	case ins.Side != trade.OrderSideBuy && ins.Side != trade.OrderSideSell:
+		return errors.New("algo instruction side must be Buy or Sell")
+	case ins.Quantity == 0:
+		return errors.New("algo instruction quantity is required")
+	case ins.LimitPrice.IsNegative():
+		return errors.New("algo instruction limit price must not be negative")
+	case !ins.EndAt.IsZero() && !ins.EndAt.After(ins.StartAt):
+		return errors.New("algo instruction end time must be after start time")
+	}
+	return nil
+}
+
+// validateScheduled validates ins of algorithms sending child orders by a schedule, which requires the end time
+func (ins *Instruction) validateScheduled() error {
+	if ins.EndAt.IsZero() {
+		return errors.New("algo instruction end time is required")
+	}
+	return ins.validate()
+}
+
+func (ins *Instruction) lot() uint64 {
+	if ins.LotSize == 0 {
+		return 1
+	}
+	return ins.LotSize
+}
+
+// Slice is a scheduled child order
+type Slice struct {
+	At         time.Time
+	Quantity   uint64 // scheduled quantity of the slice
+	Cumulative uint64 // scheduled quantity up to and including the slice
+}
+
+// Progress of an algorithm
+type Progress struct {
+	State      State
+	Quantity   uint64 // parent quantity
+	Executed   uint64
+	AvgPrice   decimal.Decimal
+	Slices     int
+	SlicesSent int
+	// OrderId of the working child order
+	OrderId string
+	Err     error
+}
+
+// Options for algorithms
+type Options struct {
+	pollInterval time.Duration
+	orderType    trade.OrderType
+	timeInForce  trade.TimeType
+	onProgress   []func(Progress)
+	lookbackDays int
+	rand         func() float64
+}
+
+// Option for algorithms
+type Option func(*Options)
+
+// WithPollInterval to set interval of OrderDetail polling when waiting for a child order to finish
+func WithPollInterval(d time.Duration) Option {
+	return func(o *Options) {
+		if d > 0 {
+			o.pollInterval = d
+		}
+	}
+}
+
+// WithOrderType to set order type of child orders, default is LO with limit price or MO without
+func WithOrderType(t trade.OrderType) Option {
+	return func(o *Options) {
+		if t != "" {
+			o.orderType = t
+		}
+	}
+}
+
+// WithTimeInForce to set time in force of child orders, default is Day
+func WithTimeInForce(t trade.TimeType) Option {
+	return func(o *Options) {
+		if t != "" {
+			o.timeInForce = t
+		}
+	}
+}
+
+// OnProgress to set callback which will be called when the progress changes.
+// The callback must not call methods of the algorithm.
+func OnProgress(fn func(Progress)) Option {
+	return func(o *Options) {
+		o.onProgress = append(o.onProgress, fn)
+	}
+}
+
+func newOptions(opt ...Option) *Options {
+	opts := Options{
+		pollInterval: time.Second,
+		timeInForce:  trade.TimeTypeDay,
+		lookbackDays: 5,
+	}
+	for _, o := range opt {
+		o(&opts)
+	}
+	return &opts
+}
+
+// child is a child order sent by an algorithm
+type child struct {
+	orderId  string
+	quantity uint64
+	executed uint64
+	avgPrice decimal.Decimal
+	status   trade.OrderStatus
+	// canceling is set when the algorithm cancels the order
+	canceling bool
+}
+
+func (c *child) update(status trade.OrderStatus, executed uint64, avgPrice *decimal.Decimal) {
+	if status != "" && c.status.CanTransitionTo(status) {
+		c.status = status
+	}
+	// executed quantity never decreases, ignore stale updates
+	if executed >= c.executed {
+		c.executed = executed
+		if avgPrice != nil && !avgPrice.IsZero() {
+			c.avgPrice = *avgPrice
+		}
+	}
+}
+
+// executor runs child orders for an algorithm and tracks their fills
+type executor struct {
+	tr     Trader
+	ins    Instruction
+	opts   *Options
+	mu     sync.Mutex
+	state  State
+	err    error
+	done   chan struct{}
+	resume chan struct{}
+	signal chan struct{} // signaled when pushes update a child order
+	cancel context.CancelFunc
+	remove func()
+
+	children   map[string]*child
+	active     *child
+	slices     int
+	slicesSent int
+}
+
+func newExecutor(tr Trader, ins Instruction, opts *Options) *executor {
+	if opts.orderType == "" {
+		opts.orderType = trade.OrderTypeMO
+		if ins.LimitPrice.IsPositive() {
+			opts.orderType = trade.OrderTypeLO
+		}
+	}
+	e := &executor{
+		tr:       tr,
+		ins:      ins,
+		opts:     opts,
+		state:    StatePending,
+		done:     make(chan struct{}),
+		children: make(map[string]*child),
+		signal:   make(chan struct{}, 1),
+	}
+	return e
+}
+
+func (e *executor) onPush(event *trade.PushEvent) {
+	if event.Data == nil || event.TransitionErr != nil {
+		return
+	}
+	e.mu.Lock()
+	c, ok := e.children[event.Data.OrderId]
+	if ok {
+		var executed uint64
+		if event.Data.ExecutedQuantity != nil {
+			executed = uint64(event.Data.ExecutedQuantity.IntPart())
+		}
+		c.update(event.Data.Status, executed, event.Data.ExecutedPrice)
+	}
+	e.mu.Unlock()
+	if ok {
+		select {
+		case e.signal <- struct{}{}:
+		default:
+		}
+		e.notify()
+	}
+}
+
+// start runs fn in a goroutine with push tracking, the algorithm is finished when fn returns
+func (e *executor) start(ctx context.Context, fn func(ctx context.Context) error) error {
+	e.mu.Lock()
+	if e.state != StatePending {
+		e.mu.Unlock()
+		return errors.New("algo is already started")
+	}
+	e.state = StateRunning
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.mu.Unlock()
+	e.remove = e.tr.AddTradeHandler(e.onPush)
+
+	go func() {
+		defer close(e.done)
+		defer e.remove()
+		err := fn(ctx)
+		// stop the working child order whatever happened
+		cctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if rerr := e.retire(cctx); rerr != nil && err == nil {
+			err = rerr
+		}
+		cancel()
+		e.mu.Lock()
+		switch {
+		case e.state == StateCanceled:
+		case err != nil:
+			e.state = StateFailed
+			e.err = err
+		default:
+			e.state = StateCompleted
+		}
+		e.mu.Unlock()
+		e.notify()
+	}()
+	e.notify()
+	return nil
+}
+
+// submit sends a child order of quantity
+func (e *executor) submit(ctx context.Context, quantity uint64) error {
+	order := &trade.SubmitOrder{
+		Symbol:            e.ins.Symbol,
+		OrderType:         e.opts.orderType,
+		Side:              e.ins.Side,
+		SubmittedQuantity: quantity,
+		SubmittedPrice:    e.ins.LimitPrice,
+		TimeInForce:       e.opts.timeInForce,
+		Remark:            e.ins.Remark,
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// paused or canceled meanwhile
+	if e.state != StateRunning {
+		return nil
+	}
+	id, err := e.tr.SubmitOrder(ctx, order)
+	if err != nil {
+		return err
+	}
+	c := &child{orderId: id, quantity: quantity, status: trade.OrderWaitToNew}
+	e.children[id] = c
+	e.active = c
+	e.slicesSent++
+	return nil
+}
+
+// retire cancels the working child order and waits until it is terminal, so the executed quantity is final
+func (e *executor) retire(ctx context.Context) error {
+	return e.settle(ctx, true)
+}
+
+// settle waits until the working child order is terminal, the order is canceled if cancel is true
+func (e *executor) settle(ctx context.Context, cancel bool) error {
+	e.mu.Lock()
+	c := e.active
+	e.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	canceled := !cancel
+	for {
+		detail, err := e.tr.OrderDetail(ctx, c.orderId)
+		if err != nil {
+			return errors.Wrapf(err, "query child order %s", c.orderId)
+		}
+		e.mu.Lock()
+		c.update(detail.Status, uint64(detail.ExecutedQuantity.IntPart()), detail.ExecutedPrice)
+		terminal := c.status.IsTerminal() || detail.Status.IsTerminal()
+		if terminal {
+			// OrderDetail is authoritative for finished orders
+			c.status = detail.Status
+			c.executed = uint64(detail.ExecutedQuantity.IntPart())
+			if e.active == c {
+				e.active = nil
+			}
+		}
+		e.mu.Unlock()
+		if terminal {
+			e.notify()
+			return nil
+		}
+		if !canceled && !detail.Status.IsPendingCancel() {
+			e.mu.Lock()
+			c.canceling = true
+			e.mu.Unlock()
+			if err := e.tr.CancelOrder(ctx, c.orderId); err != nil {
+				log.Warnf("algo cancel child order %s error:%v", c.orderId, err)
+			}
+			canceled = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.opts.pollInterval):
+		}
+	}
+}
+
+// wait blocks until t while honoring pause and cancel
+func (e *executor) wait(ctx context.Context, t time.Time) error {
+	if d := time.Until(t); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	for {
+		e.mu.Lock()
+		resume := e.resume
+		e.mu.Unlock()
+		if resume == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resume:
+		}
+	}
+}
+
+// activeDone reports whether there is no working child order
+func (e *executor) activeDone() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.active == nil || e.active.status.IsTerminal()
+}
+
+func (e *executor) executed() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var total uint64
+	for _, c := range e.children {
+		total += c.executed
+	}
+	return total
+}
+
+// Pause stops sending child orders and cancels the working child order
+func (e *executor) Pause(ctx context.Context) error {
+	e.mu.Lock()
+	if e.state != StateRunning {
+		e.mu.Unlock()
+		return errors.Errorf("algo can not pause in state %s", e.state)
+	}
+	e.state = StatePaused
+	e.resume = make(chan struct{})
+	e.mu.Unlock()
+	e.notify()
+	return e.retire(ctx)
+}
+
+// Resume continues a paused algorithm, the quantity not executed during pause is caught up by next child orders
+func (e *executor) Resume() error {
+	e.mu.Lock()
+	if e.state != StatePaused {
+		e.mu.Unlock()
+		return errors.Errorf("algo can not resume in state %s", e.state)
+	}
+	e.state = StateRunning
+	close(e.resume)
+	e.resume = nil
+	e.mu.Unlock()
+	e.notify()
+	return nil
+}
+
+// Cancel stops the algorithm, cancels the working child order and waits until the algorithm is finished
+func (e *executor) Cancel(ctx context.Context) error {
+	e.mu.Lock()
+	if e.state.Finished() {
+		e.mu.Unlock()
+		return nil
+	}
+	started := e.state != StatePending
+	e.state = StateCanceled
+	e.mu.Unlock()
+	if !started {
+		close(e.done)
+		e.notify()
+		return nil
+	}
+	e.cancel()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-e.done:
+		return nil
+	}
+}
+
+// Done returns a channel which is closed when the algorithm is finished
+func (e *executor) Done() <-chan struct{} {
+	return e.done
+}
+
+// Progress returns current progress
+func (e *executor) Progress() Progress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p := Progress{
+		State:      e.state,
+		Quantity:   e.ins.Quantity,
+		Slices:     e.slices,
+		SlicesSent: e.slicesSent,
+		Err:        e.err,
+	}
+	cost := decimal.Zero
+	for _, c := range e.children {
+		p.Executed += c.executed
+		cost = cost.Add(c.avgPrice.Mul(decimal.NewFromInt(int64(c.executed))))
+	}
+	if p.Executed > 0 {
+		p.AvgPrice = cost.Div(decimal.NewFromInt(int64(p.Executed)))
+	}
+	if e.active != nil {
+		p.OrderId = e.active.orderId
+	}
+	return p
+}
+
+func (e *executor) notify() {
+	if len(e.opts.onProgress) == 0 {
+		return
+	}
+	p := e.Progress()
+	for _, fn := range e.opts.onProgress {
+		fn(p)
+	}
+}
+
+// stopped hides the context error of Cancel
+func (e *executor) stopped(err error) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state == StateCanceled {
+		return nil
+	}
+	return err
+}
+
+func roundLot(qty, lot uint64) uint64 {
+	return qty / lot * lot
+}
@@ -0,0 +1,373 @@
+package algo
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/quote"
+	"github.com/longportapp/openapi-go/trade"
+	"github.com/longportapp/openapi-go/trade/tradetest"
+)
+
+// broker keeps child orders of a tradetest.Fake, fills are pushed by fill like the server does
+type broker struct {
+	*tradetest.Fake
+	mu       sync.Mutex
+	seq      int
+	orders   map[string]*brokerOrder
+	replaces []trade.ReplaceOrder
+}
+
+type brokerOrder struct {
+	req      trade.SubmitOrder
+	status   trade.OrderStatus
+	executed uint64
+}
+
+func newBroker() *broker {
+	b := &broker{Fake: tradetest.New(), orders: map[string]*brokerOrder{}}
+	b.SubmitOrderFunc = func(ctx context.Context, params *trade.SubmitOrder) (string, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.seq++
+		id := strconv.Itoa(b.seq)
+		b.orders[id] = &brokerOrder{req: *params, status: trade.OrderNewStatus}
+		return id, nil
+	}
+	b.ReplaceOrderFunc = func(ctx context.Context, params *trade.ReplaceOrder) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.replaces = append(b.replaces, *params)
+		b.orders[params.OrderId].req.SubmittedQuantity = params.Quantity
+		return nil
+	}
+	b.CancelOrderFunc = func(ctx context.Context, orderId string) error {
+		b.mu.Lock()
+		o := b.orders[orderId]
+		if !o.status.IsOpen() {
+			b.mu.Unlock()
+			return nil
+		}
+		o.status = trade.OrderCanceledStatus
+		executed := decimal.NewFromInt(int64(o.executed))
+		b.mu.Unlock()
+		b.EmitOrderChanged(&trade.PushOrderChanged{OrderId: orderId, Status: trade.OrderCanceledStatus, ExecutedQuantity: &executed})
+		return nil
+	}
+	b.OrderDetailFunc = func(ctx context.Context, orderId string) (trade.OrderDetail, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		o := b.orders[orderId]
+		price := decimal.NewFromInt(10)
+		return trade.OrderDetail{
+			OrderId:          orderId,
+			Status:           o.status,
+			Quantity:         decimal.NewFromInt(int64(o.req.SubmittedQuantity)),
+			ExecutedQuantity: decimal.NewFromInt(int64(o.executed)),
+			ExecutedPrice:    &price,
+		}, nil
+	}
+	return b
+}
+
+// fill sets the cumulative executed quantity of the order at price 10 and pushes it
+func (b *broker) fill(orderId string, executed uint64) {
+	b.mu.Lock()
+	o := b.orders[orderId]
+	o.executed = executed
+	o.status = trade.OrderPartialFilledStatus
+	if executed >= o.req.SubmittedQuantity {
+		o.status = trade.OrderFilledStatus
+	}
+	status := o.status
+	b.mu.Unlock()
+	qty, price := decimal.NewFromInt(int64(executed)), decimal.NewFromInt(10)
+	b.EmitOrderChanged(&trade.PushOrderChanged{OrderId: orderId, Status: status, ExecutedQuantity: &qty, ExecutedPrice: &price})
+}
+
+// autoFill fills every new order in full until the returned function is called
+func (b *broker) autoFill() (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			b.mu.Lock()
+			var ids []string
+			for id, o := range b.orders {
+				if o.status == trade.OrderNewStatus {
+					ids = append(ids, id)
+				}
+			}
+			b.mu.Unlock()
+			for _, id := range ids {
+				b.fill(id, b.order(id).req.SubmittedQuantity)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (b *broker) order(id string) brokerOrder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if o, ok := b.orders[id]; ok {
+		return *o
+	}
+	return brokerOrder{}
+}
+
+func (b *broker) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.orders)
+}
+
+func (b *broker) replaced() []trade.ReplaceOrder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]trade.ReplaceOrder(nil), b.replaces...)
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("algo is not finished in time")
+	}
+}
+
+func TestBuildSchedule(t *testing.T) {
+	start := time.Now()
+	ins := &Instruction{Quantity: 1050, StartAt: start, EndAt: start.Add(time.Hour), LotSize: 100}
+	s := buildSchedule(ins, []float64{1, 1, 2})
+	assert.Equal(t, 3, len(s))
+	assert.Equal(t, uint64(200), s[0].Cumulative)
+	assert.Equal(t, uint64(500), s[1].Cumulative)
+	assert.Equal(t, uint64(550), s[2].Quantity)
+	assert.Equal(t, uint64(1050), s[2].Cumulative)
+	assert.Equal(t, start.Add(40*time.Minute), s[2].At)
+}
+
+func TestInstructionValidate(t *testing.T) {
+	start := time.Now()
+	ins := Instruction{Symbol: "700.HK", Side: trade.OrderSideBuy, Quantity: 100, StartAt: start}
+	// end time is optional for iceberg
+	assert.NoError(t, ins.validate())
+	assert.Error(t, ins.validateScheduled())
+
+	ins.EndAt = start
+	assert.Error(t, ins.validate())
+	_, err := NewTWAP(newBroker(), ins, 4)
+	assert.Error(t, err)
+	_, err = NewIceberg(newBroker(), ins, 10, IcebergOptions{})
+	assert.Error(t, err)
+
+	ins.EndAt = start.Add(time.Hour)
+	assert.NoError(t, ins.validateScheduled())
+}
+
+func TestTWAP(t *testing.T) {
+	b := newBroker()
+	defer b.autoFill()()
+	start := time.Now()
+	twap, err := NewTWAP(b, Instruction{
+		Symbol:   "700.HK",
+		Side:     trade.OrderSideBuy,
+		Quantity: 1000,
+		StartAt:  start,
+		EndAt:    start.Add(30 * time.Millisecond),
+		LotSize:  100,
+	}, 3, WithPollInterval(time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, twap.Start(context.Background()))
+	waitDone(t, twap.Done())
+	p := twap.Progress()
+	assert.Equal(t, StateCompleted, p.State)
+	assert.Equal(t, uint64(1000), p.Executed)
+	assert.Equal(t, 3, p.SlicesSent)
+	assert.Equal(t, "10", p.AvgPrice.String())
+	assert.Equal(t, uint64(300), b.order("1").req.SubmittedQuantity)
+}
+
+func TestTWAPPushFill(t *testing.T) {
+	b := newBroker()
+	start := time.Now()
+	// the only child order works until the end of the window unless a push reports it filled
+	twap, err := NewTWAP(b, Instruction{
+		Symbol: "700.HK", Side: trade.OrderSideBuy, Quantity: 1000, StartAt: start, EndAt: start.Add(time.Hour),
+	}, 1, WithPollInterval(time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, twap.Start(context.Background()))
+	eventually(t, func() bool { return b.count() == 1 })
+
+	b.fill("1", 400)
+	eventually(t, func() bool { return twap.Progress().Executed == 400 })
+	assert.Equal(t, StateRunning, twap.Progress().State)
+	b.fill("1", 1000)
+	waitDone(t, twap.Done())
+	p := twap.Progress()
+	assert.Equal(t, StateCompleted, p.State)
+	assert.Equal(t, uint64(1000), p.Executed)
+	assert.Equal(t, 0, b.Calls("CancelOrder"))
+}
+
+// candlesticks returns minute volumes of 100 at 01:30 and 300 at 02:30 UTC on weekdays
+type candlesticks struct {
+	mu   sync.Mutex
+	days []time.Time
+	none bool
+}
+
+func (c *candlesticks) HistoryCandlesticksByDate(ctx context.Context, symbol string, period quote.Period, adjustType quote.AdjustType, startDate *time.Time, endDate *time.Time) ([]*quote.Candlestick, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	day := *startDate
+	c.days = append(c.days, day)
+	if c.none || day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return nil, nil
+	}
+	return []*quote.Candlestick{
+		{Timestamp: day.Add(90 * time.Minute).Unix(), Volume: 100},
+		{Timestamp: day.Add(150 * time.Minute).Unix(), Volume: 300},
+	}, nil
+}
+
+func TestVWAP(t *testing.T) {
+	// Monday
+	start := time.Date(2024, 1, 8, 1, 30, 0, 0, time.UTC)
+	ins := Instruction{Symbol: "700.HK", Side: trade.OrderSideBuy, Quantity: 1000, StartAt: start, EndAt: start.Add(2 * time.Hour), LotSize: 100}
+	cs := &candlesticks{}
+	vwap, err := NewVWAP(context.Background(), newBroker(), cs, ins, 2, WithLookbackDays(2))
+	assert.NoError(t, err)
+	// the weekend is skipped
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC),
+	}, cs.days)
+	// weights are 1:3 by the volume of the same time of day, rounded down to lot size
+	s := vwap.Schedule()
+	assert.Equal(t, 2, len(s))
+	assert.Equal(t, start, s[0].At)
+	assert.Equal(t, uint64(200), s[0].Cumulative)
+	assert.Equal(t, start.Add(time.Hour), s[1].At)
+	assert.Equal(t, uint64(800), s[1].Quantity)
+
+	// no history volume falls back to TWAP, lookback is bounded
+	cs = &candlesticks{none: true}
+	vwap, err = NewVWAP(context.Background(), newBroker(), cs, ins, 2, WithLookbackDays(2))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(cs.days))
+	assert.Equal(t, uint64(500), vwap.Schedule()[0].Cumulative)
+}
+
+func TestIceberg(t *testing.T) {
+	b := newBroker()
+	defer b.autoFill()()
+	iceberg, err := NewIceberg(b, Instruction{
+		Symbol:     "700.HK",
+		Side:       trade.OrderSideSell,
+		Quantity:   1050,
+		LimitPrice: decimal.NewFromInt(10),
+		LotSize:    100,
+	}, 300, IcebergOptions{Variance: 0.5, RefreshInterval: time.Hour}, WithPollInterval(time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, iceberg.Start(context.Background()))
+	waitDone(t, iceberg.Done())
+	p := iceberg.Progress()
+	assert.Equal(t, StateCompleted, p.State)
+	assert.Equal(t, uint64(1050), p.Executed)
+	for i := 1; i <= b.count(); i++ {
+		assert.True(t, b.order(strconv.Itoa(i)).req.SubmittedQuantity <= 450)
+	}
+}
+
+func newTestIceberg(t *testing.T, b *broker, replace bool) *Iceberg {
+	// refreshing is disabled, the iceberg only follows pushes
+	iceberg, err := NewIceberg(b, Instruction{
+		Symbol: "700.HK", Side: trade.OrderSideSell, Quantity: 600, LimitPrice: decimal.NewFromInt(10), LotSize: 100,
+	}, 200, IcebergOptions{Replace: replace, RefreshInterval: time.Hour}, WithPollInterval(time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, iceberg.Start(context.Background()))
+	eventually(t, func() bool { return b.count() == 1 })
+	return iceberg
+}
+
+func TestIcebergReplace(t *testing.T) {
+	b := newBroker()
+	iceberg := newTestIceberg(t, b, true)
+
+	// a partial fill raises the child order back to the display quantity
+	b.fill("1", 100)
+	eventually(t, func() bool { return len(b.replaced()) == 1 })
+	r := b.replaced()[0]
+	assert.Equal(t, "1", r.OrderId)
+	assert.Equal(t, uint64(300), r.Quantity)
+	assert.Equal(t, "10", r.Price.String())
+
+	b.fill("1", 300)
+	eventually(t, func() bool { return b.count() == 2 })
+	assert.Equal(t, uint64(200), b.order("2").req.SubmittedQuantity)
+	b.fill("2", 200)
+	eventually(t, func() bool { return b.count() == 3 })
+	assert.Equal(t, uint64(100), b.order("3").req.SubmittedQuantity)
+	b.fill("3", 100)
+	waitDone(t, iceberg.Done())
+	p := iceberg.Progress()
+	assert.Equal(t, StateCompleted, p.State)
+	assert.Equal(t, uint64(600), p.Executed)
+	assert.Equal(t, 1, len(b.replaced()))
+}
+
+func TestPauseResumeCancel(t *testing.T) {
+	b := newBroker()
+	iceberg := newTestIceberg(t, b, false)
+	b.fill("1", 100)
+	eventually(t, func() bool { return iceberg.Progress().Executed == 100 })
+
+	// pause cancels the working child order
+	assert.NoError(t, iceberg.Pause(context.Background()))
+	assert.Equal(t, StatePaused, iceberg.Progress().State)
+	assert.Equal(t, trade.OrderCanceledStatus, b.order("1").status)
+	assert.Error(t, iceberg.Pause(context.Background()))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, b.count())
+
+	// resume shows the next display quantity
+	assert.NoError(t, iceberg.Resume())
+	assert.Error(t, iceberg.Resume())
+	eventually(t, func() bool { return b.count() == 2 })
+	assert.Equal(t, uint64(200), b.order("2").req.SubmittedQuantity)
+
+	assert.NoError(t, iceberg.Cancel(context.Background()))
+	waitDone(t, iceberg.Done())
+	p := iceberg.Progress()
+	assert.Equal(t, StateCanceled, p.State)
+	assert.Equal(t, uint64(100), p.Executed)
+	assert.Nil(t, p.Err)
+	assert.Equal(t, trade.OrderCanceledStatus, b.order("2").status)
+	assert.Equal(t, 2, b.count())
+}
@@ -0,0 +1,218 @@
+package algo
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/longportapp/openapi-go/log"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// IcebergOptions for iceberg orders
+type IcebergOptions struct {
+	// Variance randomizes the display quantity in [display*(1-Variance), display*(1+Variance)], e.g. 0.2
+	Variance float64
+	// Replace replenishes the working child order by ReplaceOrder after partial fills,
+	// otherwise a new child order is submitted after the working one is filled
+	Replace bool
+	// RefreshInterval is the interval of OrderDetail polling in case pushes are missed, default is 5 seconds
+	RefreshInterval time.Duration
+}
+
+// Iceberg is an algorithm showing only a display quantity of the instruction in the book.
+// Instruction.EndAt is optional, the working child order is canceled at the end time if set.
+type Iceberg struct {
+	*executor
+	display uint64
+	iopts   IcebergOptions
+	// the child quantity has been replenished up to the executed quantity
+	replenished uint64
+}
+
+// NewIceberg returns an iceberg algorithm with display quantity, the instruction must have a limit price.
+//
+// Example:
+//
+//	iceberg, err := algo.NewIceberg(tctx, algo.Instruction{
+//		Symbol:     "700.HK",
+//		Side:       trade.OrderSideSell,
+//		Quantity:   100000,
+//		LimitPrice: decimal.NewFromFloat(320),
+//		LotSize:    100,
+//	}, 2000, algo.IcebergOptions{Variance: 0.3})
+//	err = iceberg.Start(context.Background())
+func NewIceberg(tr Trader, ins Instruction, display uint64, iopts IcebergOptions, opt ...Option) (*Iceberg, error) {
+	if err := ins.validate(); err != nil {
+		return nil, err
+	}
+	if !ins.LimitPrice.IsPositive() {
+		return nil, errors.New("iceberg requires a limit price")
+	}
+	if display < ins.lot() {
+		return nil, errors.New("iceberg display quantity must be at least one lot")
+	}
+	if iopts.Variance < 0 || iopts.Variance >= 1 {
+		return nil, errors.New("iceberg variance must be in [0, 1)")
+	}
+	if iopts.RefreshInterval <= 0 {
+		iopts.RefreshInterval = 5 * time.Second
+	}
+	opts := newOptions(opt...)
+	if opts.rand == nil {
+		opts.rand = rand.Float64
+	}
+	return &Iceberg{
+		executor: newExecutor(tr, ins, opts),
+		display:  display,
+		iopts:    iopts,
+	}, nil
+}
+
+// Start runs the algorithm in background, cancel ctx to stop it like Cancel
+func (ib *Iceberg) Start(ctx context.Context) error {
+	return ib.start(ctx, ib.run)
+}
+
+// nextDisplay returns a randomized display quantity rounded to lot size, at most remaining
+func (ib *Iceberg) nextDisplay(remaining uint64) uint64 {
+	lot := ib.ins.lot()
+	qty := ib.display
+	if ib.iopts.Variance > 0 {
+		f := 1 + ib.iopts.Variance*(2*ib.opts.rand()-1)
+		qty = roundLot(uint64(float64(ib.display)*f), lot)
+		if qty < lot {
+			qty = lot
+		}
+	}
+	if qty > remaining {
+		qty = remaining
+	}
+	return qty
+}
+
+func (ib *Iceberg) run(ctx context.Context) error {
+	if err := ib.wait(ctx, ib.ins.StartAt); err != nil {
+		return ib.stopped(err)
+	}
+	var end <-chan time.Time
+	if !ib.ins.EndAt.IsZero() {
+		timer := time.NewTimer(time.Until(ib.ins.EndAt))
+		defer timer.Stop()
+		end = timer.C
+	}
+	ticker := time.NewTicker(ib.iopts.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		// blocks while paused
+		if err := ib.wait(ctx, time.Time{}); err != nil {
+			return ib.stopped(err)
+		}
+		if err := ib.step(ctx); err != nil {
+			return ib.stopped(err)
+		}
+		if ib.executed() >= ib.ins.Quantity && ib.activeDone() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ib.stopped(ctx.Err())
+		case <-end:
+			return nil
+		case <-ib.signal:
+		case <-ticker.C:
+			if err := ib.refresh(ctx); err != nil {
+				log.Warnf("iceberg refresh child order error:%v", err)
+			}
+		}
+	}
+}
+
+// step settles a finished child order and shows the next display quantity
+func (ib *Iceberg) step(ctx context.Context) error {
+	ib.mu.Lock()
+	c := ib.active
+	ib.mu.Unlock()
+
+	if c != nil {
+		ib.mu.Lock()
+		status, executed, quantity, canceling := c.status, c.executed, c.quantity, c.canceling
+		ib.mu.Unlock()
+		switch {
+		case status.IsTerminal():
+			// confirm the final executed quantity before the next child order
+			if err := ib.settle(ctx, false); err != nil {
+				return err
+			}
+			// canceled by Pause, the next child order is submitted after Resume
+			if canceling {
+				return nil
+			}
+			ib.mu.Lock()
+			status = c.status
+			ib.mu.Unlock()
+			if status != trade.OrderFilledStatus {
+				return errors.Errorf("iceberg child order %s is %s", c.orderId, status)
+			}
+		case ib.iopts.Replace && executed > ib.replenished && executed < quantity:
+			return ib.replenish(ctx, c, executed)
+		default:
+			return nil
+		}
+	}
+
+	executed := ib.executed()
+	if executed >= ib.ins.Quantity {
+		return nil
+	}
+	qty := ib.nextDisplay(ib.ins.Quantity - executed)
+	ib.replenished = 0
+	return ib.submit(ctx, qty)
+}
+
+// replenish raises the quantity of the partially filled child order back to a display quantity
+func (ib *Iceberg) replenish(ctx context.Context, c *child, childExecuted uint64) error {
+	// other children are terminal, so the remaining quantity of the parent bounds the new child quantity
+	remaining := ib.ins.Quantity - ib.executed()
+	qty := childExecuted + ib.nextDisplay(remaining)
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+	if qty == c.quantity {
+		ib.replenished = childExecuted
+		return nil
+	}
+	err := ib.tr.ReplaceOrder(ctx, &trade.ReplaceOrder{
+		OrderId:  c.orderId,
+		Quantity: qty,
+		Price:    ib.ins.LimitPrice,
+	})
+	if err != nil {
+		// the child order may be filled meanwhile, the next step submits a new one
+		log.Warnf("iceberg replace child order %s error:%v", c.orderId, err)
+		return nil
+	}
+	c.quantity = qty
+	ib.replenished = childExecuted
+	return nil
+}
+
+// refresh polls the working child order in case pushes are missed
+func (ib *Iceberg) refresh(ctx context.Context) error {
+	ib.mu.Lock()
+	c := ib.active
+	ib.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	detail, err := ib.tr.OrderDetail(ctx, c.orderId)
+	if err != nil {
+		return err
+	}
+	ib.mu.Lock()
+	c.update(detail.Status, uint64(detail.ExecutedQuantity.IntPart()), detail.ExecutedPrice)
+	ib.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,141 @@
+package algo
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Scheduled is an algorithm sending child orders by a schedule, like TWAP and VWAP.
+// The quantity of each child order catches up to the cumulative quantity of the schedule,
+// so quantity not executed by previous child orders or during pause rolls into the next one.
+type Scheduled struct {
+	*executor
+	schedule []Slice
+}
+
+// NewTWAP returns an algorithm which splits the instruction into slices of equal quantity
+// sent evenly in the time window.
+//
+// Example:
+//
+//	twap, err := algo.NewTWAP(tctx, algo.Instruction{
+//		Symbol:     "700.HK",
+//		Side:       trade.OrderSideBuy,
+//		Quantity:   10000,
+//		StartAt:    time.Now(),
+//		EndAt:      time.Now().Add(time.Hour),
+//		LimitPrice: decimal.NewFromFloat(300),
+//		LotSize:    100,
+//	}, 12)
+//	err = twap.Start(context.Background())
+//	<-twap.Done()
+//	progress := twap.Progress()
+func NewTWAP(tr Trader, ins Instruction, slices int, opt ...Option) (*Scheduled, error) {
+	if slices <= 0 {
+		return nil, errors.New("twap slices must be positive")
+	}
+	weights := make([]float64, slices)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return newScheduled(tr, ins, weights, newOptions(opt...))
+}
+
+func newScheduled(tr Trader, ins Instruction, weights []float64, opts *Options) (*Scheduled, error) {
+	if err := ins.validateScheduled(); err != nil {
+		return nil, err
+	}
+	s := &Scheduled{
+		executor: newExecutor(tr, ins, opts),
+		schedule: buildSchedule(&ins, weights),
+	}
+	s.slices = len(s.schedule)
+	return s, nil
+}
+
+// buildSchedule splits the time window of ins evenly into len(weights) slices,
+// the quantity of each slice is in proportion to its weight and rounded to lot size
+func buildSchedule(ins *Instruction, weights []float64) []Slice {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = float64(len(weights))
+	}
+	step := ins.EndAt.Sub(ins.StartAt) / time.Duration(len(weights))
+	lot := ins.lot()
+	schedule := make([]Slice, 0, len(weights))
+	var acc float64
+	var prev uint64
+	for i, w := range weights {
+		acc += w
+		cum := ins.Quantity
+		if i < len(weights)-1 {
+			cum = roundLot(uint64(float64(ins.Quantity)*acc/total), lot)
+		}
+		schedule = append(schedule, Slice{
+			At:         ins.StartAt.Add(step * time.Duration(i)),
+			Quantity:   cum - prev,
+			Cumulative: cum,
+		})
+		prev = cum
+	}
+	return schedule
+}
+
+// Schedule returns the slices of the algorithm
+func (s *Scheduled) Schedule() []Slice {
+	return append([]Slice(nil), s.schedule...)
+}
+
+// Start runs the algorithm in background, cancel ctx to stop it like Cancel
+func (s *Scheduled) Start(ctx context.Context) error {
+	return s.start(ctx, s.run)
+}
+
+func (s *Scheduled) run(ctx context.Context) error {
+	lot := s.ins.lot()
+	for i, slice := range s.schedule {
+		if err := s.wait(ctx, slice.At); err != nil {
+			return s.stopped(err)
+		}
+		// retire the previous child order so the executed quantity is final before sizing the next one
+		if err := s.retire(ctx); err != nil {
+			return s.stopped(err)
+		}
+		executed := s.executed()
+		if executed >= slice.Cumulative {
+			continue
+		}
+		qty := slice.Cumulative - executed
+		if i < len(s.schedule)-1 {
+			qty = roundLot(qty, lot)
+		}
+		if qty == 0 {
+			continue
+		}
+		if err := s.submit(ctx, qty); err != nil {
+			return s.stopped(err)
+		}
+	}
+	// let the last child order work until the end of the time window
+	for !s.activeDone() && time.Now().Before(s.ins.EndAt) {
+		if err := s.wait(ctx, minTime(time.Now().Add(s.opts.pollInterval), s.ins.EndAt)); err != nil {
+			return s.stopped(err)
+		}
+	}
+	return nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
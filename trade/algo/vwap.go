@@ -0,0 +1,99 @@
+package algo
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/longportapp/openapi-go/quote"
+)
+
+// CandlestickSource provides history minute candlesticks to build the volume profile of VWAP.
+// *quote.QuoteContext implements it.
+type CandlestickSource interface {
+	HistoryCandlesticksByDate(ctx context.Context, symbol string, period quote.Period, adjustType quote.AdjustType, startDate *time.Time, endDate *time.Time) ([]*quote.Candlestick, error)
+}
+
+// WithLookbackDays to set count of trading days used to build the volume profile of VWAP, default is 5
+func WithLookbackDays(n int) Option {
+	return func(o *Options) {
+		if n > 0 {
+			o.lookbackDays = n
+		}
+	}
+}
+
+// NewVWAP returns an algorithm which splits the instruction into slices in the time window,
+// the quantity of each slice follows the historical volume of the same time of day.
+// If there is no history volume in the time window, it falls back to TWAP.
+//
+// Example:
+//
+//	vwap, err := algo.NewVWAP(context.Background(), tctx, qctx, algo.Instruction{
+//		Symbol:   "AAPL.US",
+//		Side:     trade.OrderSideSell,
+//		Quantity: 500,
+//		StartAt:  start,
+//		EndAt:    start.Add(2 * time.Hour),
+//	}, 24, algo.WithLookbackDays(10))
+//	err = vwap.Start(context.Background())
+func NewVWAP(ctx context.Context, tr Trader, cs CandlestickSource, ins Instruction, slices int, opt ...Option) (*Scheduled, error) {
+	if slices <= 0 {
+		return nil, errors.New("vwap slices must be positive")
+	}
+	if err := ins.validateScheduled(); err != nil {
+		return nil, err
+	}
+	opts := newOptions(opt...)
+	profile, err := volumeProfile(ctx, cs, ins.Symbol, ins.StartAt, opts.lookbackDays)
+	if err != nil {
+		return nil, err
+	}
+	step := ins.EndAt.Sub(ins.StartAt) / time.Duration(slices)
+	weights := make([]float64, slices)
+	for i := range weights {
+		from := ins.StartAt.Add(step * time.Duration(i))
+		weights[i] = profile.volume(from, from.Add(step))
+	}
+	return newScheduled(tr, ins, weights, opts)
+}
+
+// profile is the total volume of each minute of day in UTC
+type profile [24 * 60]float64
+
+func minuteOfDay(t time.Time) int {
+	t = t.UTC()
+	return t.Hour()*60 + t.Minute()
+}
+
+// volume returns the volume of the time of day in [from, to)
+func (p *profile) volume(from, to time.Time) float64 {
+	var v float64
+	for t := from.Truncate(time.Minute); t.Before(to); t = t.Add(time.Minute) {
+		v += p[minuteOfDay(t)]
+	}
+	return v
+}
+
+// volumeProfile collects minute candlesticks of the last days trading days before start
+func volumeProfile(ctx context.Context, cs CandlestickSource, symbol string, start time.Time, days int) (*profile, error) {
+	var p profile
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	// skip weekends and holidays, but do not look back forever
+	for found, tries := 0, 0; found < days && tries < days*3; tries++ {
+		day = day.AddDate(0, 0, -1)
+		sticks, err := cs.HistoryCandlesticksByDate(ctx, symbol, quote.PeriodOneMinute, quote.AdjustTypeNo, &day, &day)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load candlesticks of %s at %s", symbol, day.Format("2006-01-02"))
+		}
+		if len(sticks) == 0 {
+			continue
+		}
+		found++
+		for _, s := range sticks {
+			p[minuteOfDay(time.Unix(s.Timestamp, 0))] += float64(s.Volume)
+		}
+	}
+	return &p, nil
+}
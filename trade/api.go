@@ -0,0 +1,51 @@
+package trade
+
+import (
+	"context"
+)
+
+// TradeAPI is the interface of TradeContext, depend on it instead of *TradeContext to replace the client
+// with a fake in tests, see package trade/tradetest.
+type TradeAPI interface {
+	// push
+	OnTrade(f func(*PushEvent))
+	AddTradeHandler(f func(*PushEvent)) (remove func())
+	TrackedOrderStatus(orderId string) OrderStatus
+	Subscribe(ctx context.Context, topics []Topic) (subRes *SubResponse, err error)
+	Unsubscribe(ctx context.Context, topics []Topic) (unsubRes *UnsubResponse, err error)
+
+	// orders
+	HistoryExecutions(ctx context.Context, params *GetHistoryExecutions) (trades []*Execution, err error)
+	TodayExecutions(ctx context.Context, params *GetTodayExecutions) (trades []*Execution, err error)
+	HistoryOrders(ctx context.Context, params *GetHistoryOrders) (orders []*Order, hasMore bool, err error)
+	TodayOrders(ctx context.Context, params *GetTodayOrders) (orders []*Order, err error)
+	ReplaceOrder(ctx context.Context, params *ReplaceOrder) (err error)
+	SubmitOrder(ctx context.Context, params *SubmitOrder) (orderId string, err error)
+	WithdrawOrder(ctx context.Context, orderId string) (err error)
+	CancelOrder(ctx context.Context, orderId string) (err error)
+	OrderDetail(ctx context.Context, orderId string) (orderDetail OrderDetail, err error)
+	SubmitAndWait(ctx context.Context, params *SubmitOrder, until WaitCondition, opt ...WaitOption) (result *SubmitAndWaitResult, err error)
+	CancelAll(ctx context.Context, filter *OrderFilter, opt ...BulkOption) (report *BulkReport, err error)
+	ReplaceAll(ctx context.Context, filter *OrderFilter, mutate func(o *Order) *ReplaceOrder, opt ...BulkOption) (report *BulkReport, err error)
+	HistoryOrdersIter(ctx context.Context, params *GetHistoryOrders, opt ...IterOption) *OrderIterator
+	HistoryExecutionsIter(ctx context.Context, params *GetHistoryExecutions, opt ...IterOption) *ExecutionIterator
+
+	// assets
+	AccountBalance(ctx context.Context, params *GetAccountBalance) (accounts []*AccountBalance, err error)
+	CashFlow(ctx context.Context, params *GetCashFlow) (cashflows []*CashFlow, err error)
+	CashFlowIter(ctx context.Context, params *GetCashFlow, opt ...IterOption) *CashFlowIterator
+	FundPositions(ctx context.Context, symbols []string) (fundPositionChannels []*FundPositionChannel, err error)
+	StockPositions(ctx context.Context, symbols []string) (stockPositionChannels []*StockPositionChannel, err error)
+	MarginRatio(ctx context.Context, symbol string) (marginRatio MarginRatio, err error)
+	EstimateMaxPurchaseQuantity(ctx context.Context, params *GetEstimateMaxPurchaseQuantity) (empqr EstimateMaxPurchaseQuantityResponse, err error)
+	EstimateCharges(ctx context.Context, params *SubmitOrder) (detail OrderChargeDetail, err error)
+
+	// risk controls
+	KillSwitch(ctx context.Context, cancelOpen bool) (err error)
+	ResumeTrading()
+	KillSwitchActive() bool
+
+	Close() error
+}
+
+var _ TradeAPI = (*TradeContext)(nil)
@@ -0,0 +1,790 @@
+package trade
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/longportapp/openapi-go/log"
+)
+
+type (
+	BracketKind  string
+	BracketState string
+)
+
+const (
+	// BracketKindBracket submits the children legs after the parent order is filled
+	BracketKindBracket BracketKind = "bracket"
+	// BracketKindOCO submits both legs at once, one cancels the other when it is filled
+	BracketKindOCO BracketKind = "oco"
+
+	BracketStatePending  BracketState = "pending"  // parent order is working, no child is submitted
+	BracketStateActive   BracketState = "active"   // children legs are working
+	BracketStateDone     BracketState = "done"     // all orders are terminal
+	BracketStateCanceled BracketState = "canceled" // canceled by user, or the parent is terminal without any fill
+)
+
+// BracketLeg is an order of a bracket
+type BracketLeg struct {
+	// Order is the order to submit. Quantity of children legs in a bracket is sized by the parent fills.
+	Order            SubmitOrder
+	OrderId          string
+	Status           OrderStatus
+	Quantity         uint64 // current quantity of the submitted order
+	ExecutedQuantity uint64
+	// Retries is how many times the leg was resubmitted after its order was rejected
+	Retries int
+	// Error is the last error of the leg, e.g. its order is rejected or can not be submitted.
+	// It is cleared when the leg is submitted again.
+	Error string
+}
+
+func (l *BracketLeg) submitted() bool {
+	return l.OrderId != ""
+}
+
+func (l *BracketLeg) open() bool {
+	return l.submitted() && !l.Status.IsTerminal()
+}
+
+// Bracket is a group of linked orders managed by BracketManager
+type Bracket struct {
+	Id   string
+	Kind BracketKind
+	// Parent is the entry order, it is nil for OCO
+	Parent *BracketLeg
+	// Children are the exit legs, e.g. take profit and stop loss
+	Children  []*BracketLeg
+	State     BracketState
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Finished reports whether the bracket will not change any more
+func (b *Bracket) Finished() bool {
+	return b.State == BracketStateDone || b.State == BracketStateCanceled
+}
+
+func (b *Bracket) legs() []*BracketLeg {
+	if b.Parent == nil {
+		return b.Children
+	}
+	return append([]*BracketLeg{b.Parent}, b.Children...)
+}
+
+// exposure is the quantity the children legs should cover
+func (b *Bracket) exposure() uint64 {
+	if b.Parent != nil {
+		return b.Parent.ExecutedQuantity
+	}
+	if len(b.Children) > 0 {
+		return b.Children[0].Order.SubmittedQuantity
+	}
+	return 0
+}
+
+func (b *Bracket) clone() *Bracket {
+	nb := *b
+	if b.Parent != nil {
+		p := *b.Parent
+		nb.Parent = &p
+	}
+	nb.Children = make([]*BracketLeg, 0, len(b.Children))
+	for _, c := range b.Children {
+		cc := *c
+		nb.Children = append(nb.Children, &cc)
+	}
+	return &nb
+}
+
+// BracketStore persists brackets so they can be resumed after restart.
+// Save is called with a copy of the bracket after every change, Delete is called when the bracket is finished.
+type BracketStore interface {
+	Save(b *Bracket) error
+	Delete(id string) error
+	Load() ([]*Bracket, error)
+}
+
+// MemoryBracketStore is the default BracketStore, it keeps brackets in memory only
+type MemoryBracketStore struct {
+	mu       sync.Mutex
+	brackets map[string]*Bracket
+}
+
+// NewMemoryBracketStore return MemoryBracketStore
+func NewMemoryBracketStore() *MemoryBracketStore {
+	return &MemoryBracketStore{brackets: make(map[string]*Bracket)}
+}
+
+func (s *MemoryBracketStore) Save(b *Bracket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.brackets[b.Id] = b.clone()
+	return nil
+}
+
+func (s *MemoryBracketStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.brackets, id)
+	return nil
+}
+
+func (s *MemoryBracketStore) Load() ([]*Bracket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Bracket, 0, len(s.brackets))
+	for _, b := range s.brackets {
+		list = append(list, b.clone())
+	}
+	return list, nil
+}
+
+// BracketOptions for BracketManager
+type BracketOptions struct {
+	store        BracketStore
+	syncInterval time.Duration
+	legRetries   int
+	onUpdate     []func(*Bracket)
+}
+
+// BracketOption for BracketManager
+type BracketOption func(*BracketOptions)
+
+// WithBracketStore to set persistence of brackets, default is MemoryBracketStore
+func WithBracketStore(store BracketStore) BracketOption {
+	return func(o *BracketOptions) {
+		if store != nil {
+			o.store = store
+		}
+	}
+}
+
+// WithBracketSyncInterval to reconcile open brackets with OrderDetail periodically,
+// it recovers fills of lost push events. Default is disabled.
+func WithBracketSyncInterval(d time.Duration) BracketOption {
+	return func(o *BracketOptions) {
+		if d > 0 {
+			o.syncInterval = d
+		}
+	}
+}
+
+// WithBracketLegRetries to set how many times a rejected child leg is resubmitted, default is 3.
+// A leg rejected more times keeps its Error and the bracket stays active, the position it should cover is
+// reported by OnBracketUpdate and left to the caller, e.g. to Cancel the bracket and close it manually.
+func WithBracketLegRetries(n int) BracketOption {
+	return func(o *BracketOptions) {
+		if n >= 0 {
+			o.legRetries = n
+		}
+	}
+}
+
+// OnBracketUpdate to set callback which will be called with a copy of the bracket after every change.
+// The callback must not call methods of BracketManager.
+func OnBracketUpdate(fn func(*Bracket)) BracketOption {
+	return func(o *BracketOptions) {
+		o.onUpdate = append(o.onUpdate, fn)
+	}
+}
+
+// BracketManager manages client-side bracket and OCO orders.
+// Subscribe the `private` topic of TradeContext to receive the order push events it depends on.
+//
+// For a bracket, the children legs are submitted when the parent is filled, their quantity follows the parent
+// executed quantity. When a child leg executes, the sibling legs are resized to the remaining quantity or canceled,
+// and the remaining quantity of the parent is canceled. A rejected child leg is resubmitted, see WithBracketLegRetries.
+//
+// Requests to TradeAPI are sent without holding the lock of the manager, push events of a bracket with requests
+// in flight are applied when they return.
+//
+// Example:
+//
+//	bm, err := trade.NewBracketManager(context.Background(), tctx)
+//	b, err := bm.SubmitBracket(context.Background(), &trade.SubmitOrder{
+//	  Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy,
+//	  SubmittedQuantity: 200, SubmittedPrice: decimal.NewFromInt(300), TimeInForce: trade.TimeTypeDay,
+//	}, &trade.SubmitOrder{
+//	  Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideSell,
+//	  SubmittedPrice: decimal.NewFromInt(330), TimeInForce: trade.TimeTypeGTC,
+//	}, &trade.SubmitOrder{
+//	  Symbol: "700.HK", OrderType: trade.OrderTypeMIT, Side: trade.OrderSideSell,
+//	  TriggerPrice: decimal.NewFromInt(280), TimeInForce: trade.TimeTypeGTC,
+//	})
+type BracketManager struct {
+	seq      uint64 // keep 64-bit aligned for atomic
+	tc       TradeAPI
+	opts     *BracketOptions
+	mu       sync.Mutex
+	brackets map[string]*Bracket
+	orders   map[string]*Bracket // order id -> bracket
+	busy     map[string]bool     // id of brackets with requests in flight
+	// push events of orders which are not tracked yet, kept while some orders are being submitted
+	early      map[string][]*PushOrderChanged
+	submitting int
+	events     chan *PushOrderChanged
+	remove     func()
+	done       chan struct{}
+	closed     sync.Once
+}
+
+// NewBracketManager return BracketManager, brackets in the store are loaded and reconciled with OrderDetail.
+func NewBracketManager(ctx context.Context, tc TradeAPI, opt ...BracketOption) (*BracketManager, error) {
+	opts := &BracketOptions{legRetries: 3}
+	for _, o := range opt {
+		o(opts)
+	}
+	if opts.store == nil {
+		opts.store = NewMemoryBracketStore()
+	}
+	m := &BracketManager{
+		tc:       tc,
+		opts:     opts,
+		brackets: make(map[string]*Bracket),
+		orders:   make(map[string]*Bracket),
+		busy:     make(map[string]bool),
+		early:    make(map[string][]*PushOrderChanged),
+		events:   make(chan *PushOrderChanged, 1024),
+		done:     make(chan struct{}),
+	}
+	list, err := opts.store.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "load brackets error")
+	}
+	for _, b := range list {
+		m.track(b)
+	}
+	m.remove = tc.AddTradeHandler(func(event *PushEvent) {
+		if event.Data == nil || event.TransitionErr != nil {
+			return
+		}
+		select {
+		case m.events <- event.Data:
+		default:
+			log.Errorf("bracket manager event queue is full, order %s event dropped", event.Data.OrderId)
+		}
+	})
+	go m.run()
+	if err = m.Sync(ctx); err != nil {
+		log.Warnf("bracket manager sync loaded brackets error:%v", err)
+	}
+	return m, nil
+}
+
+func (m *BracketManager) run() {
+	var tick <-chan time.Time
+	if m.opts.syncInterval > 0 {
+		ticker := time.NewTicker(m.opts.syncInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-m.done:
+			return
+		case ev := <-m.events:
+			m.handle(ev)
+		case <-tick:
+			if err := m.Sync(context.Background()); err != nil {
+				log.Warnf("bracket manager sync error:%v", err)
+			}
+		}
+	}
+}
+
+func (m *BracketManager) track(b *Bracket) {
+	m.brackets[b.Id] = b
+	for _, l := range b.legs() {
+		if l.submitted() {
+			m.orders[l.OrderId] = b
+		}
+	}
+}
+
+func (m *BracketManager) untrack(b *Bracket) {
+	delete(m.brackets, b.Id)
+	for _, l := range b.legs() {
+		if l.submitted() {
+			delete(m.orders, l.OrderId)
+		}
+	}
+}
+
+func (m *BracketManager) nextId() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(atomic.AddUint64(&m.seq, 1), 10)
+}
+
+// SubmitBracket submits the parent entry order, the children are submitted when the parent is filled.
+// SubmittedQuantity of children is ignored, it follows the parent executed quantity.
+func (m *BracketManager) SubmitBracket(ctx context.Context, parent *SubmitOrder, children ...*SubmitOrder) (*Bracket, error) {
+	if parent == nil || len(children) == 0 {
+		return nil, errors.New("bracket requires a parent and at least one child order")
+	}
+	now := time.Now()
+	b := &Bracket{
+		Id:        m.nextId(),
+		Kind:      BracketKindBracket,
+		Parent:    &BracketLeg{Order: *parent, Quantity: parent.SubmittedQuantity},
+		State:     BracketStatePending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, c := range children {
+		b.Children = append(b.Children, &BracketLeg{Order: *c})
+	}
+
+	steps := []bracketStep{{op: bracketSubmit, leg: b.Parent, quantity: parent.SubmittedQuantity}}
+	m.mu.Lock()
+	m.submitting++
+	m.mu.Unlock()
+	m.send(ctx, steps)
+	m.mu.Lock()
+	if err := m.apply(b, steps); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	m.brackets[b.Id] = b
+	res := b.clone()
+	m.mu.Unlock()
+	// the push events received during the request may have filled the parent already
+	m.drive(ctx, b)
+	return res, nil
+}
+
+// SubmitOCO submits all legs at once, when one leg executes the others are resized to the remaining quantity or canceled.
+// All legs should have the same SubmittedQuantity.
+func (m *BracketManager) SubmitOCO(ctx context.Context, legs ...*SubmitOrder) (*Bracket, error) {
+	if len(legs) < 2 {
+		return nil, errors.New("oco requires at least two orders")
+	}
+	for _, l := range legs[1:] {
+		if l.SubmittedQuantity != legs[0].SubmittedQuantity {
+			return nil, errors.New("oco orders must have the same quantity")
+		}
+	}
+	now := time.Now()
+	b := &Bracket{
+		Id:        m.nextId(),
+		Kind:      BracketKindOCO,
+		State:     BracketStateActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	steps := make([]bracketStep, 0, len(legs))
+	for _, l := range legs {
+		leg := &BracketLeg{Order: *l}
+		b.Children = append(b.Children, leg)
+		steps = append(steps, bracketStep{op: bracketSubmit, leg: leg, quantity: l.SubmittedQuantity})
+	}
+
+	m.mu.Lock()
+	m.brackets[b.Id] = b
+	m.busy[b.Id] = true
+	m.submitting += len(steps)
+	m.mu.Unlock()
+	// stop at the first failure, the submitted legs are canceled
+	var sent int
+	for sent < len(steps) {
+		m.send(ctx, steps[sent:sent+1])
+		sent++
+		if steps[sent-1].err != nil {
+			break
+		}
+	}
+	m.mu.Lock()
+	err := m.apply(b, steps[:sent])
+	m.doneSubmitting(len(steps) - sent)
+	delete(m.busy, b.Id)
+	if err != nil {
+		b.State = BracketStateCanceled
+		m.save(b)
+		m.mu.Unlock()
+		m.drive(ctx, b)
+		return nil, err
+	}
+	res := b.clone()
+	m.mu.Unlock()
+	m.drive(ctx, b)
+	return res, nil
+}
+
+// Cancel cancels all open orders of the bracket, it returns an error if the bracket is not found, e.g. it is finished.
+// If requests of the bracket are in flight, the orders are canceled when they return and Cancel returns nil.
+func (m *BracketManager) Cancel(ctx context.Context, id string) error {
+	m.mu.Lock()
+	b, ok := m.brackets[id]
+	if !ok {
+		m.mu.Unlock()
+		return errors.Errorf("bracket %s not found", id)
+	}
+	if b.Finished() {
+		m.mu.Unlock()
+		return nil
+	}
+	b.State = BracketStateCanceled
+	m.save(b)
+	m.mu.Unlock()
+	return m.drive(ctx, b)
+}
+
+// Get returns a copy of the bracket, it is nil if not found or finished
+func (m *BracketManager) Get(id string) *Bracket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b, ok := m.brackets[id]; ok {
+		return b.clone()
+	}
+	return nil
+}
+
+// List returns copies of all brackets which are not finished
+func (m *BracketManager) List() []*Bracket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Bracket, 0, len(m.brackets))
+	for _, b := range m.brackets {
+		list = append(list, b.clone())
+	}
+	return list
+}
+
+// Sync reconciles open brackets with OrderDetail of their orders, then submits, resizes or cancels legs as needed.
+// It returns the last error of the requests.
+func (m *BracketManager) Sync(ctx context.Context) error {
+	m.mu.Lock()
+	var (
+		brackets []*Bracket
+		orders   []string
+	)
+	for _, b := range m.brackets {
+		if b.Finished() {
+			continue
+		}
+		brackets = append(brackets, b)
+		for _, l := range b.legs() {
+			if l.open() {
+				orders = append(orders, l.OrderId)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	var lastErr error
+	details := make(map[string]OrderDetail, len(orders))
+	for _, id := range orders {
+		detail, err := m.tc.OrderDetail(ctx, id)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		details[id] = detail
+	}
+
+	m.mu.Lock()
+	for _, b := range brackets {
+		for _, l := range b.legs() {
+			detail, ok := details[l.OrderId]
+			executed := uint64(detail.ExecutedQuantity.IntPart())
+			// a push event received during the request is newer than the detail
+			if !ok || l.Status.IsTerminal() || executed < l.ExecutedQuantity {
+				continue
+			}
+			l.Status = detail.Status
+			l.ExecutedQuantity = executed
+		}
+	}
+	m.mu.Unlock()
+	for _, b := range brackets {
+		if err := m.drive(ctx, b); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Close stops receiving push events, open orders are kept.
+func (m *BracketManager) Close() {
+	m.closed.Do(func() {
+		m.remove()
+		close(m.done)
+	})
+}
+
+func (m *BracketManager) handle(ev *PushOrderChanged) {
+	m.mu.Lock()
+	b, ok := m.orders[ev.OrderId]
+	if !ok {
+		// the order may be submitted by a request in flight, the event is applied when it returns
+		if m.submitting > 0 {
+			m.early[ev.OrderId] = append(m.early[ev.OrderId], ev)
+		}
+		m.mu.Unlock()
+		return
+	}
+	if b.Finished() {
+		m.mu.Unlock()
+		return
+	}
+	m.update(b, ev)
+	m.mu.Unlock()
+	m.drive(context.Background(), b)
+}
+
+func (m *BracketManager) update(b *Bracket, ev *PushOrderChanged) {
+	for _, l := range b.legs() {
+		if l.OrderId != ev.OrderId {
+			continue
+		}
+		l.Status = ev.Status
+		if ev.ExecutedQuantity != nil {
+			l.ExecutedQuantity = uint64(ev.ExecutedQuantity.IntPart())
+		}
+		if ev.Status == OrderRejectedStatus {
+			l.Error = fmt.Sprintf("order %s rejected: %s", l.OrderId, ev.Msg)
+		}
+	}
+}
+
+type bracketOp string
+
+const (
+	bracketSubmit  bracketOp = "submit"
+	bracketReplace bracketOp = "replace"
+	bracketCancel  bracketOp = "cancel"
+)
+
+// bracketStep is a request of a leg, it is planned by advance and sent without holding m.mu
+type bracketStep struct {
+	op       bracketOp
+	leg      *BracketLeg
+	quantity uint64        // quantity to submit or replace to
+	replace  *ReplaceOrder // for bracketReplace
+	orderId  string        // order to cancel, or the order submitted
+	err      error
+}
+
+// drive advances the bracket and sends the planned requests until there is nothing to do or a request fails,
+// failed requests are retried on the next event or Sync. Only one goroutine drives a bracket at a time,
+// the changes made by the others while it sends requests are picked up by its next advance.
+func (m *BracketManager) drive(ctx context.Context, b *Bracket) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.busy[b.Id] {
+		return nil
+	}
+	m.busy[b.Id] = true
+	defer delete(m.busy, b.Id)
+	for {
+		steps := m.advance(b)
+		if len(steps) == 0 {
+			break
+		}
+		for _, s := range steps {
+			if s.op == bracketSubmit {
+				m.submitting++
+			}
+		}
+		m.mu.Unlock()
+		m.send(ctx, steps)
+		m.mu.Lock()
+		if err = m.apply(b, steps); err != nil {
+			m.save(b)
+			break
+		}
+	}
+	if b.Finished() {
+		// the orders submitted after the bracket was canceled
+		m.untrack(b)
+	}
+	return err
+}
+
+// send sends the requests of steps, it must be called without holding m.mu
+func (m *BracketManager) send(ctx context.Context, steps []bracketStep) {
+	for i := range steps {
+		s := &steps[i]
+		switch s.op {
+		case bracketSubmit:
+			order := s.leg.Order
+			order.SubmittedQuantity = s.quantity
+			s.orderId, s.err = m.tc.SubmitOrder(ctx, &order)
+		case bracketReplace:
+			s.err = m.tc.ReplaceOrder(ctx, s.replace)
+		case bracketCancel:
+			s.err = m.tc.CancelOrder(ctx, s.orderId)
+		}
+	}
+}
+
+// apply updates the legs by the results of steps, it returns the last error
+func (m *BracketManager) apply(b *Bracket, steps []bracketStep) (err error) {
+	for i := range steps {
+		s := &steps[i]
+		l := s.leg
+		if s.op == bracketSubmit {
+			m.doneSubmitting(1)
+		}
+		if s.err != nil {
+			log.Errorf("bracket %s %s order error:%v", b.Id, s.op, s.err)
+			l.Error = s.err.Error()
+			err = s.err
+			continue
+		}
+		switch s.op {
+		case bracketSubmit:
+			if l.submitted() {
+				// resubmitted after rejected
+				delete(m.orders, l.OrderId)
+			}
+			l.OrderId = s.orderId
+			l.Quantity = s.quantity
+			l.Status = OrderWaitToNew
+			l.ExecutedQuantity = 0
+			l.Error = ""
+			m.orders[l.OrderId] = b
+			for _, ev := range m.early[l.OrderId] {
+				m.update(b, ev)
+			}
+			delete(m.early, l.OrderId)
+		case bracketReplace:
+			l.Quantity = s.quantity
+		case bracketCancel:
+			// not canceled again before the push event of the cancel arrives
+			if l.OrderId == s.orderId && !l.Status.IsTerminal() {
+				l.Status = OrderPendingCancelStatus
+			}
+		}
+	}
+	return
+}
+
+func (m *BracketManager) doneSubmitting(n int) {
+	m.submitting -= n
+	if m.submitting == 0 && len(m.early) > 0 {
+		m.early = make(map[string][]*PushOrderChanged)
+	}
+}
+
+// advance moves the bracket forward by the current state of its legs and returns the requests to send.
+// It is idempotent, the legs with requests planned are changed by apply only.
+func (m *BracketManager) advance(b *Bracket) []bracketStep {
+	if b.State == BracketStateCanceled {
+		return m.cancelOpen(b)
+	}
+	if b.Finished() {
+		return nil
+	}
+	exposure := b.exposure()
+
+	if p := b.Parent; p != nil {
+		if p.Status.IsTerminal() && exposure == 0 {
+			b.State = BracketStateCanceled
+			m.save(b)
+			return m.cancelOpen(b)
+		}
+		if exposure > 0 && b.State == BracketStatePending {
+			b.State = BracketStateActive
+		}
+	}
+
+	var (
+		steps         []bracketStep
+		childExecuted uint64
+	)
+	for _, c := range b.Children {
+		childExecuted += c.ExecutedQuantity
+	}
+	// an exit leg executed, stop entering more
+	if p := b.Parent; p != nil && childExecuted > 0 && p.open() && !p.Status.IsPendingCancel() {
+		steps = append(steps, bracketStep{op: bracketCancel, leg: p, orderId: p.OrderId})
+	}
+
+	uncovered := false
+	if exposure > 0 {
+		for _, c := range b.Children {
+			target := exposure - minUint64(exposure, childExecuted-c.ExecutedQuantity)
+			switch {
+			case !c.submitted():
+				if target > 0 && childExecuted == 0 {
+					steps = append(steps, bracketStep{op: bracketSubmit, leg: c, quantity: target})
+				}
+			case c.Status == OrderRejectedStatus && target > c.ExecutedQuantity:
+				uncovered = true
+				if c.Retries < m.opts.legRetries {
+					c.Retries++
+					steps = append(steps, bracketStep{op: bracketSubmit, leg: c, quantity: target - c.ExecutedQuantity})
+				}
+			case !c.open() || c.Status.IsPendingCancel() || c.Status.IsPendingReplace():
+			case target <= c.ExecutedQuantity:
+				steps = append(steps, bracketStep{op: bracketCancel, leg: c, orderId: c.OrderId})
+			case target != c.Quantity:
+				steps = append(steps, bracketStep{op: bracketReplace, leg: c, quantity: target, replace: &ReplaceOrder{
+					OrderId:         c.OrderId,
+					Quantity:        target,
+					Price:           c.Order.SubmittedPrice,
+					TriggerPrice:    c.Order.TriggerPrice,
+					LimitOffset:     c.Order.LimitOffset,
+					TrailingAmount:  c.Order.TrailingAmount,
+					TrailingPercent: c.Order.TrailingPercent,
+					Remark:          c.Order.Remark,
+				}})
+			}
+		}
+	}
+
+	// a leg rejected more than the retries keeps the bracket active, its exposure is not covered
+	finished := (b.Parent == nil || b.Parent.Status.IsTerminal()) && !uncovered
+	for _, c := range b.Children {
+		if c.open() || (!c.submitted() && exposure > 0 && childExecuted == 0) {
+			finished = false
+		}
+	}
+	if finished && len(steps) == 0 {
+		b.State = BracketStateDone
+	}
+	m.save(b)
+	return steps
+}
+
+// cancelOpen returns the requests to cancel the open orders of the bracket
+func (m *BracketManager) cancelOpen(b *Bracket) (steps []bracketStep) {
+	for _, l := range b.legs() {
+		if !l.open() || l.Status.IsPendingCancel() {
+			continue
+		}
+		steps = append(steps, bracketStep{op: bracketCancel, leg: l, orderId: l.OrderId})
+	}
+	return
+}
+
+// save persists the bracket and notifies OnBracketUpdate callbacks, a finished bracket is removed from the manager
+func (m *BracketManager) save(b *Bracket) {
+	b.UpdatedAt = time.Now()
+	var err error
+	if b.Finished() {
+		m.untrack(b)
+		err = m.opts.store.Delete(b.Id)
+	} else {
+		err = m.opts.store.Save(b)
+	}
+	if err != nil {
+		log.Errorf("bracket %s persist error:%v", b.Id, err)
+	}
+	for _, fn := range m.opts.onUpdate {
+		fn(b.clone())
+	}
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,350 @@
+package trade_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+	"github.com/longportapp/openapi-go/trade/tradetest"
+)
+
+// bracketBroker records the requests of BracketManager to a fake
+type bracketBroker struct {
+	*tradetest.Fake
+	mu       sync.Mutex
+	seq      int
+	submits  map[string]trade.SubmitOrder
+	replaces []trade.ReplaceOrder
+	cancels  []string
+}
+
+func newBracketBroker() *bracketBroker {
+	b := &bracketBroker{Fake: tradetest.New(), submits: map[string]trade.SubmitOrder{}}
+	b.SubmitOrderFunc = func(ctx context.Context, params *trade.SubmitOrder) (string, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.seq++
+		id := strconv.Itoa(b.seq)
+		b.submits[id] = *params
+		return id, nil
+	}
+	b.ReplaceOrderFunc = func(ctx context.Context, params *trade.ReplaceOrder) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.replaces = append(b.replaces, *params)
+		return nil
+	}
+	b.CancelOrderFunc = func(ctx context.Context, orderId string) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.cancels = append(b.cancels, orderId)
+		return nil
+	}
+	return b
+}
+
+func (b *bracketBroker) submitted(id string) (trade.SubmitOrder, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.submits[id]
+	return o, ok
+}
+
+func (b *bracketBroker) replaced() []trade.ReplaceOrder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]trade.ReplaceOrder(nil), b.replaces...)
+}
+
+func (b *bracketBroker) canceled() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.cancels...)
+}
+
+func (b *bracketBroker) emit(orderId string, status trade.OrderStatus, executed int64) {
+	qty := decimal.NewFromInt(executed)
+	b.EmitOrderChanged(&trade.PushOrderChanged{OrderId: orderId, Status: status, ExecutedQuantity: &qty})
+}
+
+// eventually waits for cond, push events are handled by BracketManager asynchronously
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func bracketOrders() (parent, takeProfit, stopLoss *trade.SubmitOrder) {
+	parent = &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy,
+		SubmittedQuantity: 200, SubmittedPrice: decimal.NewFromInt(300), TimeInForce: trade.TimeTypeDay,
+	}
+	takeProfit = &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideSell,
+		SubmittedPrice: decimal.NewFromInt(330), TimeInForce: trade.TimeTypeGTC,
+	}
+	stopLoss = &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeMIT, Side: trade.OrderSideSell,
+		TriggerPrice: decimal.NewFromInt(280), TimeInForce: trade.TimeTypeGTC,
+	}
+	return
+}
+
+func TestBracketParentFill(t *testing.T) {
+	broker := newBracketBroker()
+	bm, err := trade.NewBracketManager(context.Background(), broker)
+	assert.NoError(t, err)
+	defer bm.Close()
+
+	parent, takeProfit, stopLoss := bracketOrders()
+	b, err := bm.SubmitBracket(context.Background(), parent, takeProfit, stopLoss)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", b.Parent.OrderId)
+	assert.Equal(t, trade.BracketStatePending, b.State)
+
+	broker.emit("1", trade.OrderFilledStatus, 200)
+	eventually(t, func() bool {
+		_, ok := broker.submitted("3")
+		return ok
+	})
+	tp, _ := broker.submitted("2")
+	sl, _ := broker.submitted("3")
+	assert.Equal(t, uint64(200), tp.SubmittedQuantity)
+	assert.Equal(t, trade.OrderSideSell, tp.Side)
+	assert.Equal(t, uint64(200), sl.SubmittedQuantity)
+	assert.Equal(t, trade.OrderTypeMIT, sl.OrderType)
+	assert.Equal(t, trade.BracketStateActive, bm.Get(b.Id).State)
+}
+
+func TestBracketPartialFill(t *testing.T) {
+	broker := newBracketBroker()
+	bm, err := trade.NewBracketManager(context.Background(), broker)
+	assert.NoError(t, err)
+	defer bm.Close()
+
+	parent, takeProfit, stopLoss := bracketOrders()
+	b, err := bm.SubmitBracket(context.Background(), parent, takeProfit, stopLoss)
+	assert.NoError(t, err)
+
+	broker.emit("1", trade.OrderPartialFilledStatus, 100)
+	eventually(t, func() bool {
+		_, ok := broker.submitted("3")
+		return ok
+	})
+	tp, _ := broker.submitted("2")
+	assert.Equal(t, uint64(100), tp.SubmittedQuantity)
+
+	// the children follow the parent executed quantity
+	broker.emit("1", trade.OrderFilledStatus, 200)
+	eventually(t, func() bool { return len(broker.replaced()) == 2 })
+	for _, r := range broker.replaced() {
+		assert.Equal(t, uint64(200), r.Quantity)
+	}
+	got := bm.Get(b.Id)
+	assert.Equal(t, uint64(200), got.Children[0].Quantity)
+	assert.Equal(t, uint64(200), got.Children[1].Quantity)
+}
+
+func TestBracketChildFillCancelsSibling(t *testing.T) {
+	broker := newBracketBroker()
+	var (
+		mu      sync.Mutex
+		updates []*trade.Bracket
+	)
+	bm, err := trade.NewBracketManager(context.Background(), broker, trade.OnBracketUpdate(func(b *trade.Bracket) {
+		mu.Lock()
+		updates = append(updates, b)
+		mu.Unlock()
+	}))
+	assert.NoError(t, err)
+	defer bm.Close()
+
+	parent, takeProfit, stopLoss := bracketOrders()
+	b, err := bm.SubmitBracket(context.Background(), parent, takeProfit, stopLoss)
+	assert.NoError(t, err)
+	broker.emit("1", trade.OrderFilledStatus, 200)
+	eventually(t, func() bool {
+		_, ok := broker.submitted("3")
+		return ok
+	})
+
+	// take profit is filled, stop loss is canceled
+	broker.emit("2", trade.OrderFilledStatus, 200)
+	eventually(t, func() bool { return len(broker.canceled()) == 1 })
+	assert.Equal(t, []string{"3"}, broker.canceled())
+	assert.Equal(t, trade.BracketStateActive, bm.Get(b.Id).State)
+
+	broker.emit("3", trade.OrderCanceledStatus, 0)
+	eventually(t, func() bool { return bm.Get(b.Id) == nil })
+	// finished brackets are not kept
+	assert.Equal(t, 0, len(bm.List()))
+	mu.Lock()
+	assert.Equal(t, trade.BracketStateDone, updates[len(updates)-1].State)
+	mu.Unlock()
+}
+
+func TestBracketSyncFromStore(t *testing.T) {
+	store := trade.NewMemoryBracketStore()
+	parent, takeProfit, stopLoss := bracketOrders()
+	takeProfit.SubmittedQuantity, stopLoss.SubmittedQuantity = 200, 200
+	assert.NoError(t, store.Save(&trade.Bracket{
+		Id:     "b1",
+		Kind:   trade.BracketKindBracket,
+		Parent: &trade.BracketLeg{Order: *parent, OrderId: "10", Status: trade.OrderFilledStatus, Quantity: 200, ExecutedQuantity: 200},
+		Children: []*trade.BracketLeg{
+			{Order: *takeProfit, OrderId: "11", Status: trade.OrderNewStatus, Quantity: 200},
+			{Order: *stopLoss, OrderId: "12", Status: trade.OrderNewStatus, Quantity: 200},
+		},
+		State: trade.BracketStateActive,
+	}))
+
+	broker := newBracketBroker()
+	// the take profit was filled while the process was down
+	broker.OrderDetailFunc = func(ctx context.Context, orderId string) (trade.OrderDetail, error) {
+		if orderId == "11" {
+			return trade.OrderDetail{OrderId: orderId, Status: trade.OrderFilledStatus, ExecutedQuantity: decimal.NewFromInt(200)}, nil
+		}
+		return trade.OrderDetail{OrderId: orderId, Status: trade.OrderNewStatus}, nil
+	}
+	bm, err := trade.NewBracketManager(context.Background(), broker, trade.WithBracketStore(store))
+	assert.NoError(t, err)
+	defer bm.Close()
+
+	assert.Equal(t, []string{"12"}, broker.canceled())
+	assert.Equal(t, trade.OrderFilledStatus, bm.Get("b1").Children[0].Status)
+
+	// push events of the loaded orders are tracked
+	broker.emit("12", trade.OrderCanceledStatus, 0)
+	eventually(t, func() bool { return bm.Get("b1") == nil })
+	list, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(list))
+}
+
+func TestBracketRejectedChild(t *testing.T) {
+	broker := newBracketBroker()
+	var (
+		mu      sync.Mutex
+		updates []*trade.Bracket
+	)
+	bm, err := trade.NewBracketManager(context.Background(), broker, trade.WithBracketLegRetries(1),
+		trade.OnBracketUpdate(func(b *trade.Bracket) {
+			mu.Lock()
+			updates = append(updates, b)
+			mu.Unlock()
+		}))
+	assert.NoError(t, err)
+	defer bm.Close()
+
+	parent, takeProfit, stopLoss := bracketOrders()
+	b, err := bm.SubmitBracket(context.Background(), parent, takeProfit, stopLoss)
+	assert.NoError(t, err)
+	broker.emit("1", trade.OrderFilledStatus, 200)
+	eventually(t, func() bool {
+		_, ok := broker.submitted("3")
+		return ok
+	})
+
+	// the rejected take profit is submitted again
+	broker.EmitOrderChanged(&trade.PushOrderChanged{OrderId: "2", Status: trade.OrderRejectedStatus, Msg: "price out of range"})
+	eventually(t, func() bool {
+		_, ok := broker.submitted("4")
+		return ok
+	})
+	tp, _ := broker.submitted("4")
+	assert.Equal(t, uint64(200), tp.SubmittedQuantity)
+	assert.Equal(t, trade.OrderTypeLO, tp.OrderType)
+	got := bm.Get(b.Id)
+	assert.Equal(t, "4", got.Children[0].OrderId)
+	assert.Equal(t, 1, got.Children[0].Retries)
+	assert.Equal(t, "", got.Children[0].Error)
+
+	// no retries left, the failure is reported and the bracket is kept
+	broker.EmitOrderChanged(&trade.PushOrderChanged{OrderId: "4", Status: trade.OrderRejectedStatus, Msg: "price out of range"})
+	eventually(t, func() bool { return bm.Get(b.Id).Children[0].Error != "" })
+	assert.Equal(t, "order 4 rejected: price out of range", bm.Get(b.Id).Children[0].Error)
+	_, ok := broker.submitted("5")
+	assert.False(t, ok)
+	mu.Lock()
+	last := updates[len(updates)-1]
+	mu.Unlock()
+	assert.Equal(t, trade.BracketStateActive, last.State)
+	assert.Equal(t, trade.OrderRejectedStatus, last.Children[0].Status)
+
+	// the stop loss still covers the position, its fill finishes the bracket
+	broker.emit("3", trade.OrderFilledStatus, 200)
+	eventually(t, func() bool { return bm.Get(b.Id) == nil })
+}
+
+func TestBracketSubmitError(t *testing.T) {
+	broker := newBracketBroker()
+	bm, err := trade.NewBracketManager(context.Background(), broker)
+	assert.NoError(t, err)
+	defer bm.Close()
+
+	parent, takeProfit, stopLoss := bracketOrders()
+	b, err := bm.SubmitBracket(context.Background(), parent, takeProfit, stopLoss)
+	assert.NoError(t, err)
+	broker.InjectError("SubmitOrder", errors.New("timeout"))
+	broker.emit("1", trade.OrderFilledStatus, 200)
+	eventually(t, func() bool { return bm.Get(b.Id).Children[0].Error == "timeout" })
+
+	// retried by Sync
+	broker.InjectError("SubmitOrder", nil)
+	assert.NoError(t, bm.Sync(context.Background()))
+	got := bm.Get(b.Id)
+	assert.Equal(t, "", got.Children[0].Error)
+	assert.Equal(t, "2", got.Children[0].OrderId)
+	assert.Equal(t, "3", got.Children[1].OrderId)
+}
+
+func TestBracketUnlockedRequests(t *testing.T) {
+	broker := newBracketBroker()
+	submit := broker.SubmitOrderFunc
+	release := make(chan struct{})
+	broker.SubmitOrderFunc = func(ctx context.Context, params *trade.SubmitOrder) (string, error) {
+		if params.Side == trade.OrderSideSell {
+			<-release
+		}
+		return submit(ctx, params)
+	}
+	bm, err := trade.NewBracketManager(context.Background(), broker)
+	assert.NoError(t, err)
+	defer bm.Close()
+
+	parent, takeProfit, stopLoss := bracketOrders()
+	takeProfit.SubmittedQuantity, stopLoss.SubmittedQuantity = 200, 200
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := bm.SubmitOCO(context.Background(), takeProfit, stopLoss)
+		assert.NoError(t, err)
+	}()
+	eventually(t, func() bool { return len(bm.List()) == 1 })
+
+	// the manager is not locked while an order is being submitted
+	b, err := bm.SubmitBracket(context.Background(), parent, takeProfit, stopLoss)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bm.List()))
+
+	// the first leg is filled before its submit request returns
+	broker.emit("2", trade.OrderFilledStatus, 200)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-done
+	eventually(t, func() bool { return len(broker.canceled()) == 1 })
+	assert.Equal(t, []string{"3"}, broker.canceled())
+	assert.Equal(t, trade.BracketStatePending, bm.Get(b.Id).State)
+}
@@ -0,0 +1,338 @@
+package trade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/http"
+	"github.com/longportapp/openapi-go/log"
+)
+
+const (
+	// DefaultBulkConcurrency is the default number of concurrent requests of CancelAll and ReplaceAll
+	DefaultBulkConcurrency = 4
+	// DefaultBulkInterval is the default interval between two requests of CancelAll and ReplaceAll,
+	// which keeps them under the limit of 30 trade requests per 30 seconds.
+	DefaultBulkInterval = time.Second
+	// DefaultBulkRetries is the default number of retries of a request rejected by rate limits
+	DefaultBulkRetries = 3
+)
+
+// OrderFilter selects today's open orders for CancelAll and ReplaceAll.
+// Empty fields match all orders, a list matches orders equal to any of its values.
+type OrderFilter struct {
+	Symbols    []string
+	Markets    []openapi.Market
+	Side       OrderSide
+	OrderTypes []OrderType
+}
+
+// Match reports whether the order is selected by the filter, the status of the order is not checked
+func (f *OrderFilter) Match(o *Order) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Symbols) > 0 && !containsFold(f.Symbols, o.Symbol) {
+		return false
+	}
+	if len(f.Markets) > 0 {
+		market := SymbolMarket(o.Symbol)
+		found := false
+		for _, m := range f.Markets {
+			if strings.EqualFold(string(m), string(market)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Side != "" && f.Side != o.Side {
+		return false
+	}
+	if len(f.OrderTypes) > 0 {
+		found := false
+		for _, t := range f.OrderTypes {
+			if t == o.OrderType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// BulkOptions for CancelAll and ReplaceAll
+type BulkOptions struct {
+	// Concurrency is the max number of requests in flight
+	Concurrency int
+	// Interval is the min interval between the starts of two requests
+	Interval time.Duration
+	// Retries is the max number of retries of a request rejected by rate limits (HTTP status 429)
+	Retries int
+}
+
+// BulkOption for CancelAll and ReplaceAll
+type BulkOption func(*BulkOptions)
+
+// WithBulkConcurrency to set the max number of requests in flight
+func WithBulkConcurrency(n int) BulkOption {
+	return func(o *BulkOptions) {
+		if n > 0 {
+			o.Concurrency = n
+		}
+	}
+}
+
+// WithBulkInterval to set the min interval between the starts of two requests, 0 means no pacing
+func WithBulkInterval(d time.Duration) BulkOption {
+	return func(o *BulkOptions) {
+		if d >= 0 {
+			o.Interval = d
+		}
+	}
+}
+
+// WithBulkRetries to set the max number of retries of a request rejected by rate limits
+func WithBulkRetries(n int) BulkOption {
+	return func(o *BulkOptions) {
+		if n >= 0 {
+			o.Retries = n
+		}
+	}
+}
+
+func newBulkOptions(opt ...BulkOption) *BulkOptions {
+	opts := BulkOptions{
+		Concurrency: DefaultBulkConcurrency,
+		Interval:    DefaultBulkInterval,
+		Retries:     DefaultBulkRetries,
+	}
+	for _, o := range opt {
+		o(&opts)
+	}
+	return &opts
+}
+
+// BulkResult is the result of one order of CancelAll or ReplaceAll
+type BulkResult struct {
+	Order *Order
+	// Skipped is true if the mutate function of ReplaceAll returned nil for the order
+	Skipped bool
+	Err     error
+}
+
+// BulkReport is the per-order report of CancelAll and ReplaceAll, in the order of TodayOrders
+type BulkReport struct {
+	Results   []*BulkResult
+	Succeeded int
+	Failed    int
+	Skipped   int
+}
+
+// Err returns an error listing the failed orders, it is nil if no order failed
+func (r *BulkReport) Err() error {
+	if r == nil || r.Failed == 0 {
+		return nil
+	}
+	var failed []string
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res.Order.OrderId+": "+res.Err.Error())
+		}
+	}
+	return fmt.Errorf("%d of %d orders failed, %s", r.Failed, len(r.Results), strings.Join(failed, "; "))
+}
+
+// CancelAll cancels today's open orders matching the filter, a nil filter matches all open orders.
+// Requests run with bounded concurrency and pacing of the options, and are retried when rejected by rate limits.
+// The returned error is for listing today's orders, failures of single orders are in the report, see BulkReport.Err.
+//
+// Example:
+//
+//	conf, err := config.NewFromEnv()
+//	tctx, err := trade.NewFromCfg(conf)
+//	report, err := tctx.CancelAll(context.Background(), &trade.OrderFilter{
+//	  Symbols: []string{"AAPL.US", "MSFT.US", "NVDA.US"},
+//	  Side:    trade.OrderSideBuy,
+//	})
+//	if err == nil {
+//	  err = report.Err()
+//	}
+func (c *TradeContext) CancelAll(ctx context.Context, filter *OrderFilter, opt ...BulkOption) (report *BulkReport, err error) {
+	return CancelAllWith(ctx, c, filter, opt...)
+}
+
+// ReplaceAll replaces today's open orders matching the filter with the request returned by mutate,
+// orders are skipped if mutate returns nil. OrderId of the request is set to the order by ReplaceAll,
+// and the request is checked by ValidateFor the order type before being sent.
+// Requests run like CancelAll.
+//
+// Example:
+//
+//	conf, err := config.NewFromEnv()
+//	tctx, err := trade.NewFromCfg(conf)
+//	report, err := tctx.ReplaceAll(context.Background(), &trade.OrderFilter{
+//	  Markets:    []openapi.Market{openapi.MarketHK},
+//	  OrderTypes: []trade.OrderType{trade.OrderTypeLO},
+//	}, func(o *trade.Order) *trade.ReplaceOrder {
+//	  qty := uint64(o.Quantity.IntPart())
+//	  return &trade.ReplaceOrder{Quantity: qty, Price: o.Price.Mul(decimal.NewFromFloat(0.99))}
+//	})
+func (c *TradeContext) ReplaceAll(ctx context.Context, filter *OrderFilter, mutate func(o *Order) *ReplaceOrder, opt ...BulkOption) (report *BulkReport, err error) {
+	return ReplaceAllWith(ctx, c, filter, mutate, opt...)
+}
+
+// CancelAllWith is CancelAll on api, e.g. a fake TradeAPI in tests
+func CancelAllWith(ctx context.Context, api TradeAPI, filter *OrderFilter, opt ...BulkOption) (report *BulkReport, err error) {
+	return bulk(ctx, api, filter, func(ctx context.Context, o *Order) (bool, error) {
+		if o.Status.IsPendingCancel() {
+			return true, nil
+		}
+		return false, api.CancelOrder(ctx, o.OrderId)
+	}, opt...)
+}
+
+// ReplaceAllWith is ReplaceAll on api, e.g. a fake TradeAPI in tests
+func ReplaceAllWith(ctx context.Context, api TradeAPI, filter *OrderFilter, mutate func(o *Order) *ReplaceOrder, opt ...BulkOption) (report *BulkReport, err error) {
+	return bulk(ctx, api, filter, func(ctx context.Context, o *Order) (bool, error) {
+		params := mutate(o)
+		if params == nil {
+			return true, nil
+		}
+		params.OrderId = o.OrderId
+		if err := params.ValidateFor(o.OrderType); err != nil {
+			return false, err
+		}
+		return false, api.ReplaceOrder(ctx, params)
+	}, opt...)
+}
+
+// bulk runs do for the open orders matching the filter, do returns true if the order is skipped
+func bulk(ctx context.Context, api TradeAPI, filter *OrderFilter, do func(ctx context.Context, o *Order) (bool, error), opt ...BulkOption) (report *BulkReport, err error) {
+	opts := newBulkOptions(opt...)
+	params := &GetTodayOrders{}
+	if filter != nil {
+		params.Side = filter.Side
+		if len(filter.Symbols) == 1 {
+			params.Symbol = filter.Symbols[0]
+		}
+	}
+	orders, err := api.TodayOrders(ctx, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "bulk list today orders error")
+	}
+
+	report = &BulkReport{}
+	for _, o := range orders {
+		if o.Status.IsOpen() && filter.Match(o) {
+			report.Results = append(report.Results, &BulkResult{Order: o})
+		}
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, opts.Concurrency)
+		pace = newPacer(opts.Interval)
+	)
+	for _, res := range report.Results {
+		if err := pace.wait(ctx); err != nil {
+			res.Err = err
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			res.Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(res *BulkResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for attempt := 0; ; attempt++ {
+				res.Skipped, res.Err = do(ctx, res.Order)
+				if res.Err == nil || attempt >= opts.Retries || !http.IsRateLimit(res.Err) {
+					return
+				}
+				backoff := opts.Interval << uint(attempt+1)
+				if backoff <= 0 {
+					backoff = time.Second
+				}
+				log.Warnf("bulk order %s rate limited, retry in %v", res.Order.OrderId, backoff)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				if err := pace.wait(ctx); err != nil {
+					return
+				}
+			}
+		}(res)
+	}
+	wg.Wait()
+
+	for _, res := range report.Results {
+		switch {
+		case res.Err != nil:
+			report.Failed++
+		case res.Skipped:
+			report.Skipped++
+		default:
+			report.Succeeded++
+		}
+	}
+	return report, nil
+}
+
+// pacer spaces the starts of requests by interval
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newPacer(interval time.Duration) *pacer {
+	return &pacer{interval: interval}
+}
+
+// wait blocks until the next slot, it returns ctx.Err() if ctx is done first
+func (p *pacer) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	now := time.Now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package trade_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/http"
+	"github.com/longportapp/openapi-go/trade"
+	"github.com/longportapp/openapi-go/trade/tradetest"
+)
+
+func TestCancelAll(t *testing.T) {
+	fake := tradetest.New()
+	fake.TodayOrdersFunc = func(ctx context.Context, params *trade.GetTodayOrders) ([]*trade.Order, error) {
+		return []*trade.Order{
+			{OrderId: "1", Symbol: "AAPL.US", Side: trade.OrderSideBuy, OrderType: trade.OrderTypeLO, Status: trade.OrderNewStatus},
+			{OrderId: "2", Symbol: "MSFT.US", Side: trade.OrderSideBuy, OrderType: trade.OrderTypeLO, Status: trade.OrderPartialFilledStatus},
+			{OrderId: "3", Symbol: "AAPL.US", Side: trade.OrderSideBuy, OrderType: trade.OrderTypeLO, Status: trade.OrderFilledStatus},
+			{OrderId: "4", Symbol: "700.HK", Side: trade.OrderSideBuy, OrderType: trade.OrderTypeLO, Status: trade.OrderNewStatus},
+			{OrderId: "5", Symbol: "NVDA.US", Side: trade.OrderSideSell, OrderType: trade.OrderTypeLO, Status: trade.OrderNewStatus},
+		}, nil
+	}
+	var (
+		mu       sync.Mutex
+		canceled = map[string]int{}
+	)
+	fake.CancelOrderFunc = func(ctx context.Context, orderId string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		canceled[orderId]++
+		switch {
+		case orderId == "2" && canceled[orderId] == 1:
+			return &http.ApiError{HttpStatus: 429, Message: "too many requests"}
+		case orderId == "5":
+			return &http.ApiError{HttpStatus: 400, Message: "order can not be canceled"}
+		}
+		return nil
+	}
+
+	report, err := fake.CancelAll(context.Background(), &trade.OrderFilter{
+		Markets: []openapi.Market{openapi.MarketUS},
+	}, trade.WithBulkInterval(0), trade.WithBulkConcurrency(2))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(report.Results))
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 2, canceled["2"])
+	assert.Equal(t, 0, canceled["4"])
+	assert.Error(t, report.Err())
+
+	report, err = fake.ReplaceAll(context.Background(), &trade.OrderFilter{Side: trade.OrderSideBuy}, func(o *trade.Order) *trade.ReplaceOrder {
+		if o.Symbol == "700.HK" {
+			return nil
+		}
+		return &trade.ReplaceOrder{Quantity: 100, Price: decimal.NewFromInt(10)}
+	}, trade.WithBulkInterval(0))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 2, fake.Calls("ReplaceOrder"))
+}
+
+func TestCancelAllAbort(t *testing.T) {
+	fake := tradetest.New()
+	fake.TodayOrdersFunc = func(ctx context.Context, params *trade.GetTodayOrders) ([]*trade.Order, error) {
+		return []*trade.Order{
+			{OrderId: "1", Symbol: "AAPL.US", Status: trade.OrderNewStatus},
+			{OrderId: "2", Symbol: "AAPL.US", Status: trade.OrderNewStatus},
+			{OrderId: "3", Symbol: "AAPL.US", Status: trade.OrderNewStatus},
+		}, nil
+	}
+	release := make(chan struct{})
+	fake.CancelOrderFunc = func(ctx context.Context, orderId string) error {
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *trade.BulkReport)
+	go func() {
+		report, err := fake.CancelAll(ctx, nil, trade.WithBulkInterval(0), trade.WithBulkConcurrency(1))
+		assert.NoError(t, err)
+		done <- report
+	}()
+	eventually(t, func() bool { return fake.Calls("CancelOrder") == 1 })
+	// the orders waiting for the request in flight give up
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	report := <-done
+	assert.Equal(t, 1, fake.Calls("CancelOrder"))
+	assert.Equal(t, 1, report.Succeeded)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, context.Canceled, report.Results[1].Err)
+	assert.Equal(t, context.Canceled, report.Results[2].Err)
+}
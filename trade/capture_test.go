@@ -0,0 +1,61 @@
+package trade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/longportapp/openapi-go/config"
+	"github.com/longportapp/openapi-go/http"
+)
+
+// TestCaptureOrderDetail writes the sanitized responses of filled orders to testdata for TestEstimateCharges.
+// It runs only if LONGPORT_CAPTURE_ORDER_IDS is set to comma separated order ids, with the credentials
+// of config.NewFormEnv, e.g.
+//
+//	LONGPORT_CAPTURE_ORDER_IDS=701276261045858304,701276261045858305 go test -run TestCaptureOrderDetail ./trade/
+func TestCaptureOrderDetail(t *testing.T) {
+	ids := os.Getenv("LONGPORT_CAPTURE_ORDER_IDS")
+	if ids == "" {
+		t.Skip("LONGPORT_CAPTURE_ORDER_IDS is not set")
+	}
+	cfg, err := config.NewFormEnv()
+	assert.NoError(t, err)
+	cli, err := http.NewFromCfg(cfg)
+	assert.NoError(t, err)
+	for i, id := range strings.Split(ids, ",") {
+		var raw map[string]interface{}
+		values := url.Values{}
+		values.Add("order_id", strings.TrimSpace(id))
+		assert.NoError(t, cli.Get(context.Background(), "/v1/trade/order", values, &raw))
+		sanitizeOrderDetail(raw, i+1)
+
+		b, err := json.MarshalIndent(raw, "", "  ")
+		assert.NoError(t, err)
+		market := strings.ToLower(string(SymbolMarket(fmt.Sprint(raw["symbol"]))))
+		name := fmt.Sprintf("testdata/order_detail_%s_%d.json", market, i+1)
+		assert.NoError(t, os.WriteFile(name, append(b, '\n'), 0o644))
+		t.Logf("captured order %s to %s", id, name)
+	}
+}
+
+// sanitizeOrderDetail removes the fields identifying the account from the raw order detail,
+// the prices, quantities and charge detail are kept as is
+func sanitizeOrderDetail(raw map[string]interface{}, seq int) {
+	raw["order_id"] = fmt.Sprintf("captured-%d", seq)
+	raw["remark"] = ""
+	raw["msg"] = ""
+	if history, ok := raw["history"].([]interface{}); ok {
+		for _, h := range history {
+			if m, ok := h.(map[string]interface{}); ok {
+				m["msg"] = ""
+			}
+		}
+	}
+}
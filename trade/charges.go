@@ -0,0 +1,176 @@
+package trade
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go"
+)
+
+// Fee codes of the default fee schedules
+const (
+	FeeCodeCommission    = "commission"
+	FeeCodePlatformFee   = "platform_fee"
+	FeeCodeStampDuty     = "stamp_duty"
+	FeeCodeTradingFee    = "trading_fee"
+	FeeCodeSFCLevy       = "sfc_levy"
+	FeeCodeFRCLevy       = "frc_levy"
+	FeeCodeSettlementFee = "settlement_fee"
+	FeeCodeSECFee        = "sec_fee"
+	FeeCodeTAF           = "trading_activity_fee"
+)
+
+// FeeRule is one fee of a FeeSchedule, the fee is Rate * amount + PerShare * quantity + Fixed,
+// bounded by Min and Max, then rounded to Scale decimal places.
+type FeeRule struct {
+	Code     string
+	Name     string
+	Category ChargeCategoryCode // ChargeCategoryCodeBrokerFees or ChargeCategoryCodeThirdFees
+	Rate     decimal.Decimal    // fraction of the order amount, e.g. 0.001 for 0.1%
+	PerShare decimal.Decimal
+	Fixed    decimal.Decimal
+	Min      decimal.Decimal // zero means no min
+	Max      decimal.Decimal // zero means no max
+	Side     OrderSide       // charged on this side only, empty means both sides
+	Scale    int32           // decimal places of the fee
+	RoundUp  bool            // round up instead of half up, e.g. HK stamp duty is rounded up to the dollar
+}
+
+// Amount returns the fee of an order, it is zero if the rule does not apply to side
+func (r *FeeRule) Amount(side OrderSide, quantity uint64, price decimal.Decimal) decimal.Decimal {
+	if r.Side != "" && r.Side != side {
+		return decimal.Zero
+	}
+	qty := decimal.NewFromInt(int64(quantity))
+	fee := r.Rate.Mul(price.Mul(qty)).Add(r.PerShare.Mul(qty)).Add(r.Fixed)
+	if r.Min.IsPositive() && fee.LessThan(r.Min) {
+		fee = r.Min
+	}
+	if r.Max.IsPositive() && fee.GreaterThan(r.Max) {
+		fee = r.Max
+	}
+	if r.RoundUp {
+		shift := decimal.New(1, r.Scale)
+		return fee.Mul(shift).Ceil().Div(shift)
+	}
+	return fee.Round(r.Scale)
+}
+
+// FeeSchedule is the fees of orders in a market
+type FeeSchedule struct {
+	Currency string
+	Rules    []FeeRule
+}
+
+var chargeCategoryNames = map[ChargeCategoryCode]string{
+	ChargeCategoryCodeBrokerFees: "Broker Fees",
+	ChargeCategoryCodeThirdFees:  "Third Party Fees",
+}
+
+// Estimate returns the charges of an order in the shape of OrderDetail.ChargeDetail,
+// fees are grouped into items by category in the order of the rules.
+func (s *FeeSchedule) Estimate(side OrderSide, quantity uint64, price decimal.Decimal) OrderChargeDetail {
+	detail := OrderChargeDetail{Currency: s.Currency}
+	index := make(map[ChargeCategoryCode]int)
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		if r.Side != "" && r.Side != side {
+			continue
+		}
+		code := r.Category
+		if code == "" {
+			code = ChargeCategoryCodeUnknown
+		}
+		idx, ok := index[code]
+		if !ok {
+			idx = len(detail.Items)
+			index[code] = idx
+			detail.Items = append(detail.Items, OrderChargeItem{Code: code, Name: chargeCategoryNames[code]})
+		}
+		amount := r.Amount(side, quantity, price)
+		detail.Items[idx].Fees = append(detail.Items[idx].Fees, OrderChargeFee{
+			Code:     r.Code,
+			Name:     r.Name,
+			Amount:   amount,
+			Currency: s.Currency,
+		})
+		detail.TotalAmount = detail.TotalAmount.Add(amount)
+	}
+	return detail
+}
+
+// DefaultFeeSchedules returns the fee schedules of HK and US stocks used by EstimateCharges,
+// which follow the public price list at the time of writing and may differ from the fees of your account.
+// Use WithFeeSchedule to replace them.
+func DefaultFeeSchedules() map[openapi.Market]*FeeSchedule {
+	d := decimal.RequireFromString
+	broker, third := ChargeCategoryCodeBrokerFees, ChargeCategoryCodeThirdFees
+	return map[openapi.Market]*FeeSchedule{
+		openapi.MarketHK: {
+			Currency: "HKD",
+			Rules: []FeeRule{
+				{Code: FeeCodeCommission, Name: "Commission", Category: broker, Rate: d("0.0003"), Min: d("3"), Scale: 2},
+				{Code: FeeCodePlatformFee, Name: "Platform Fee", Category: broker, Fixed: d("15"), Scale: 2},
+				{Code: FeeCodeStampDuty, Name: "Stamp Duty", Category: third, Rate: d("0.001"), Scale: 0, RoundUp: true},
+				{Code: FeeCodeTradingFee, Name: "Trading Fee", Category: third, Rate: d("0.0000565"), Min: d("0.01"), Scale: 2},
+				{Code: FeeCodeSFCLevy, Name: "SFC Transaction Levy", Category: third, Rate: d("0.000027"), Min: d("0.01"), Scale: 2},
+				{Code: FeeCodeFRCLevy, Name: "FRC Transaction Levy", Category: third, Rate: d("0.0000015"), Min: d("0.01"), Scale: 2},
+				{Code: FeeCodeSettlementFee, Name: "Settlement Fee", Category: third, Rate: d("0.00002"), Min: d("2"), Max: d("100"), Scale: 2},
+			},
+		},
+		openapi.MarketUS: {
+			Currency: "USD",
+			Rules: []FeeRule{
+				{Code: FeeCodeCommission, Name: "Commission", Category: broker, PerShare: d("0.0049"), Min: d("0.99"), Scale: 2},
+				{Code: FeeCodePlatformFee, Name: "Platform Fee", Category: broker, PerShare: d("0.005"), Min: d("1"), Scale: 2},
+				{Code: FeeCodeSettlementFee, Name: "Settlement Fee", Category: third, PerShare: d("0.003"), Scale: 2},
+				{Code: FeeCodeSECFee, Name: "SEC Fee", Category: third, Rate: d("0.0000278"), Min: d("0.01"), Side: OrderSideSell, Scale: 2},
+				{Code: FeeCodeTAF, Name: "Trading Activity Fee", Category: third, PerShare: d("0.000166"), Min: d("0.01"), Max: d("8.3"), Side: OrderSideSell, Scale: 2},
+			},
+		},
+	}
+}
+
+// EstimateCharges estimates the charges of an order before submitting it, in the shape of OrderDetail.ChargeDetail.
+// The fee schedule of the symbol market is set by WithFeeSchedule, or DefaultFeeSchedules for HK and US.
+// The order is priced at SubmittedPrice, then TriggerPrice, then the last done price of WithPriceSource.
+//
+// Example:
+//
+//	conf, err := config.NewFromEnv()
+//	tctx, err := trade.NewFromCfg(conf)
+//	charges, err := tctx.EstimateCharges(context.Background(), &trade.SubmitOrder{
+//	  Symbol: "700.HK",
+//	  OrderType: trade.OrderTypeLO,
+//	  Side: trade.OrderSideBuy,
+//	  SubmittedPrice: decimal.NewFromInt(320),
+//	  SubmittedQuantity: 100,
+//	  TimeInForce: trade.TimeTypeDay,
+//	})
+//	fmt.Println(charges.TotalAmount, charges.Currency)
+func (c *TradeContext) EstimateCharges(ctx context.Context, params *SubmitOrder) (detail OrderChargeDetail, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+	market := SymbolMarket(params.Symbol)
+	schedule := c.opts.feeSchedules[market]
+	if schedule == nil {
+		schedule = DefaultFeeSchedules()[market]
+	}
+	if schedule == nil {
+		return detail, errors.Errorf("no fee schedule of market %s", market)
+	}
+	price := params.SubmittedPrice
+	if !price.IsPositive() {
+		price = params.TriggerPrice
+	}
+	if !price.IsPositive() && c.risk != nil {
+		price = c.risk.lastDone(ctx, params.Symbol)
+	}
+	if !price.IsPositive() {
+		return detail, errors.Errorf("no price to estimate charges of %s order of %s, set WithPriceSource", params.OrderType, params.Symbol)
+	}
+	return schedule.Estimate(params.Side, params.SubmittedQuantity, price), nil
+}
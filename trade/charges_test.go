@@ -0,0 +1,122 @@
+package trade
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/trade/jsontypes"
+)
+
+func loadOrderDetail(t *testing.T, name string) OrderDetail {
+	b, err := os.ReadFile("testdata/" + name)
+	assert.NoError(t, err)
+	var data jsontypes.OrderDetail
+	assert.NoError(t, json.Unmarshal(b, &data))
+	detail, err := toOrderDetail(&data)
+	assert.NoError(t, err)
+	return detail
+}
+
+func TestFeeScheduleEstimate(t *testing.T) {
+	schedules := DefaultFeeSchedules()
+	cases := []struct {
+		market   openapi.Market
+		side     OrderSide
+		quantity uint64
+		price    string
+		fees     map[string]string
+		total    string
+	}{
+		// 100 x 320.2 = 32020 HKD
+		{openapi.MarketHK, OrderSideBuy, 100, "320.2", map[string]string{
+			FeeCodeCommission:    "9.61", // 32020 x 0.03% = 9.606
+			FeeCodePlatformFee:   "15",   // fixed
+			FeeCodeStampDuty:     "33",   // 32020 x 0.1% = 32.02, rounded up to the dollar
+			FeeCodeTradingFee:    "1.81", // 32020 x 0.00565% = 1.809
+			FeeCodeSFCLevy:       "0.86", // 32020 x 0.0027% = 0.8645
+			FeeCodeFRCLevy:       "0.05", // 32020 x 0.00015% = 0.048
+			FeeCodeSettlementFee: "2",    // 32020 x 0.002% = 0.64, min 2
+		}, "62.33"},
+		// 50 x 190.5 = 9525 USD
+		{openapi.MarketUS, OrderSideSell, 50, "190.5", map[string]string{
+			FeeCodeCommission:    "0.99", // 50 x 0.0049 = 0.245, min 0.99
+			FeeCodePlatformFee:   "1",    // 50 x 0.005 = 0.25, min 1
+			FeeCodeSettlementFee: "0.15", // 50 x 0.003
+			FeeCodeSECFee:        "0.26", // 9525 x 0.00278% = 0.2648
+			FeeCodeTAF:           "0.01", // 50 x 0.000166 = 0.0083, min 0.01
+		}, "2.41"},
+		// SEC fee and TAF are charged on sells only
+		{openapi.MarketUS, OrderSideBuy, 50, "190.5", map[string]string{
+			FeeCodeCommission:    "0.99",
+			FeeCodePlatformFee:   "1",
+			FeeCodeSettlementFee: "0.15",
+		}, "2.14"},
+	}
+	for _, c := range cases {
+		detail := schedules[c.market].Estimate(c.side, c.quantity, decimal.RequireFromString(c.price))
+		fees := make(map[string]string)
+		for _, item := range detail.Items {
+			for _, fee := range item.Fees {
+				fees[fee.Code] = fee.Amount.String()
+			}
+		}
+		assert.Equal(t, c.fees, fees)
+		assert.Equal(t, c.total, detail.TotalAmount.String())
+	}
+}
+
+// TestEstimateCharges checks every fee of the estimates against the charge details of filled orders
+// captured from the API by TestCaptureOrderDetail, see testdata/README.md
+func TestEstimateCharges(t *testing.T) {
+	files, err := filepath.Glob("testdata/order_detail_*.json")
+	assert.NoError(t, err)
+	if len(files) == 0 {
+		t.Skip("no captured order details in testdata, see testdata/README.md")
+	}
+	tc := &TradeContext{opts: newOptions(), risk: newRiskControl(nil, nil)}
+	for _, file := range files {
+		detail := loadOrderDetail(t, filepath.Base(file))
+		assert.Equal(t, OrderFilledStatus, detail.Status)
+		// charges are of the executions
+		estimated, err := tc.EstimateCharges(context.Background(), &SubmitOrder{
+			Symbol:            detail.Symbol,
+			OrderType:         OrderTypeLO,
+			Side:              detail.Side,
+			SubmittedQuantity: uint64(detail.ExecutedQuantity.IntPart()),
+			SubmittedPrice:    *detail.ExecutedPrice,
+			TimeInForce:       TimeTypeDay,
+		})
+		assert.NoError(t, err)
+
+		actual := detail.ChargeDetail
+		assert.Equal(t, actual.Currency, estimated.Currency)
+		assert.Equal(t, chargeFees(actual), chargeFees(estimated))
+		assert.Equal(t, actual.TotalAmount.String(), estimated.TotalAmount.String())
+	}
+}
+
+// chargeFees returns "item/fee code" to the amount of every fee
+func chargeFees(detail OrderChargeDetail) map[string]string {
+	fees := make(map[string]string)
+	for _, item := range detail.Items {
+		for _, fee := range item.Fees {
+			fees[string(item.Code)+"/"+fee.Code] = fee.Amount.String()
+		}
+	}
+	return fees
+}
+
+func TestEstimateChargesPrice(t *testing.T) {
+	tc := &TradeContext{opts: newOptions(), risk: newRiskControl(nil, nil)}
+	_, err := tc.EstimateCharges(context.Background(), &SubmitOrder{
+		Symbol: "700.HK", OrderType: OrderTypeMO, Side: OrderSideBuy, SubmittedQuantity: 100, TimeInForce: TimeTypeDay,
+	})
+	assert.Error(t, err)
+}
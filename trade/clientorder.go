@@ -0,0 +1,252 @@
+package trade
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/longportapp/openapi-go/http"
+	"github.com/longportapp/openapi-go/log"
+)
+
+const (
+	// clientOrderIdPrefix marks the client order id at the beginning of the order remark
+	clientOrderIdPrefix = "cid:"
+	// clientOrderIdSep separates the client order id from the user remark
+	clientOrderIdSep = "|"
+	// MaxClientOrderIdLength is the max length of SubmitOrder.ClientOrderId
+	MaxClientOrderIdLength = 32
+	// MaxRemarkLength is the max length of the order remark accepted by the server
+	MaxRemarkLength = 64
+	// DefaultClientOrderLookupTimeout is the timeout of looking up an order by client order id after ctx is done
+	DefaultClientOrderLookupTimeout = 10 * time.Second
+	// DefaultClientOrderCacheSize is the default max number of client order ids remembered by TradeContext
+	DefaultClientOrderCacheSize = 10000
+	// DefaultClientOrderCacheTTL is the default time a client order id is remembered by TradeContext
+	DefaultClientOrderCacheTTL = 24 * time.Hour
+)
+
+// ClientOrderIdFromRemark returns the client order id carried in the remark of an order submitted with
+// SubmitOrder.ClientOrderId, it returns empty string if there is none.
+//
+// Example:
+//
+//	orders, err := tctx.TodayOrders(context.Background(), nil)
+//	for _, o := range orders {
+//	  if trade.ClientOrderIdFromRemark(o.Remark) == "my-key-1" {
+//	    // found
+//	  }
+//	}
+func ClientOrderIdFromRemark(remark string) string {
+	if !strings.HasPrefix(remark, clientOrderIdPrefix) {
+		return ""
+	}
+	id := remark[len(clientOrderIdPrefix):]
+	if idx := strings.Index(id, clientOrderIdSep); idx >= 0 {
+		id = id[:idx]
+	}
+	if !validClientOrderId(id) {
+		return ""
+	}
+	return id
+}
+
+// remark returns the remark sent to the server, which carries ClientOrderId
+func (o *SubmitOrder) remark() string {
+	if o.ClientOrderId == "" {
+		return o.Remark
+	}
+	if o.Remark == "" {
+		return clientOrderIdPrefix + o.ClientOrderId
+	}
+	return clientOrderIdPrefix + o.ClientOrderId + clientOrderIdSep + o.Remark
+}
+
+func validClientOrderId(id string) bool {
+	if id == "" || len(id) > MaxClientOrderIdLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isAmbiguous reports whether the order may have been accepted by the server although err is returned,
+// e.g. a timeout or a server error. Requests rejected with an API error of status 4xx are not ambiguous.
+func isAmbiguous(err error) bool {
+	var apiErr *http.ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr.HttpStatus >= 500 || apiErr.HttpStatus == 408
+	}
+	return true
+}
+
+// clientOrders remembers the order ids of client order ids submitted by this TradeContext, at most size
+// of them for ttl. Submissions of the same client order id are serialized by acquire.
+type clientOrders struct {
+	mu       sync.Mutex
+	size     int
+	ttl      time.Duration
+	now      func() time.Time
+	orderIds map[string]*list.Element // client order id -> element of recent
+	recent   *list.List               // *clientOrder, the oldest first
+	inflight map[string]*inflightOrder
+}
+
+type clientOrder struct {
+	clientOrderId string
+	orderId       string
+	at            time.Time
+}
+
+// inflightOrder is the lock of a client order id, refs counts the holder and waiters
+type inflightOrder struct {
+	ch   chan struct{}
+	refs int
+}
+
+func newClientOrders(size int, ttl time.Duration) *clientOrders {
+	return &clientOrders{
+		size:     size,
+		ttl:      ttl,
+		now:      time.Now,
+		orderIds: make(map[string]*list.Element),
+		recent:   list.New(),
+		inflight: make(map[string]*inflightOrder),
+	}
+}
+
+// acquire locks the client order id until release is called, so that concurrent submissions of the same
+// client order id look up and post one by one. It returns ctx.Err() if ctx is done while waiting.
+func (co *clientOrders) acquire(ctx context.Context, clientOrderId string) (release func(), err error) {
+	co.mu.Lock()
+	f := co.inflight[clientOrderId]
+	if f == nil {
+		f = &inflightOrder{ch: make(chan struct{}, 1)}
+		co.inflight[clientOrderId] = f
+	}
+	f.refs++
+	co.mu.Unlock()
+
+	unref := func() {
+		co.mu.Lock()
+		defer co.mu.Unlock()
+		if f.refs--; f.refs == 0 {
+			delete(co.inflight, clientOrderId)
+		}
+	}
+	select {
+	case f.ch <- struct{}{}:
+		return func() {
+			<-f.ch
+			unref()
+		}, nil
+	case <-ctx.Done():
+		unref()
+		return nil, ctx.Err()
+	}
+}
+
+// get returns the order id of the client order id, it is empty if the client order id is not remembered
+func (co *clientOrders) get(clientOrderId string) (orderId string) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.evict()
+	if e, ok := co.orderIds[clientOrderId]; ok {
+		return e.Value.(*clientOrder).orderId
+	}
+	return ""
+}
+
+func (co *clientOrders) set(clientOrderId, orderId string) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	if e, ok := co.orderIds[clientOrderId]; ok {
+		co.recent.Remove(e)
+	}
+	co.orderIds[clientOrderId] = co.recent.PushBack(&clientOrder{clientOrderId: clientOrderId, orderId: orderId, at: co.now()})
+	co.evict()
+}
+
+// evict removes the client order ids which are expired or exceed the size, the oldest first
+func (co *clientOrders) evict() {
+	for e := co.recent.Front(); e != nil; e = co.recent.Front() {
+		o := e.Value.(*clientOrder)
+		if co.recent.Len() <= co.size && co.now().Sub(o.at) < co.ttl {
+			return
+		}
+		co.recent.Remove(e)
+		delete(co.orderIds, o.clientOrderId)
+	}
+}
+
+// findClientOrder looks up today's orders of the symbol for the client order id.
+// The lookup uses a new context if ctx is done, e.g. the submission timed out.
+func (c *TradeContext) findClientOrder(ctx context.Context, symbol, clientOrderId string) (orderId string, err error) {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), DefaultClientOrderLookupTimeout)
+		defer cancel()
+	}
+	orders, err := c.TodayOrders(ctx, &GetTodayOrders{Symbol: symbol})
+	if err != nil {
+		return "", errors.Wrapf(err, "look up client order id %s error", clientOrderId)
+	}
+	for _, o := range orders {
+		if ClientOrderIdFromRemark(o.Remark) == clientOrderId {
+			c.clientOrders.set(clientOrderId, o.OrderId)
+			return o.OrderId, nil
+		}
+	}
+	return "", nil
+}
+
+// submitClientOrder submits the order with ClientOrderId at most once, see SubmitOrder
+func (c *TradeContext) submitClientOrder(ctx context.Context, params *SubmitOrder) (orderId string, err error) {
+	key := params.ClientOrderId
+	release, err := c.clientOrders.acquire(ctx, key)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	if orderId = c.clientOrders.get(key); orderId != "" {
+		return orderId, nil
+	}
+	// the client order id may have been submitted before a restart, by another TradeContext,
+	// or by a submission which failed ambiguously
+	if orderId, err = c.findClientOrder(ctx, params.Symbol, key); err != nil || orderId != "" {
+		return
+	}
+
+	if err = c.checkSubmitRisk(ctx, params); err != nil {
+		return
+	}
+	orderId, err = c.postOrder(ctx, params)
+	if err == nil {
+		c.clientOrders.set(key, orderId)
+		return
+	}
+	if !isAmbiguous(err) {
+		return
+	}
+	existing, lerr := c.findClientOrder(ctx, params.Symbol, key)
+	if lerr != nil {
+		log.Warnf("submit order with client order id %s error:%v, %v", key, err, lerr)
+		return
+	}
+	if existing != "" {
+		log.Infof("submit order with client order id %s error:%v, found order %s", key, err, existing)
+		return existing, nil
+	}
+	return
+}
@@ -0,0 +1,213 @@
+package trade
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	nhttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/http"
+)
+
+func TestClientOrderId(t *testing.T) {
+	var (
+		posts  int
+		remark string
+	)
+	srv := httptest.NewServer(nhttp.HandlerFunc(func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.Method {
+		case nhttp.MethodPost:
+			posts++
+			var body map[string]interface{}
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &body)
+			remark, _ = body["remark"].(string)
+			// the order is placed but the response is lost
+			w.WriteHeader(nhttp.StatusBadGateway)
+			_, _ = w.Write([]byte(`{"code":500,"message":"bad gateway"}`))
+		case nhttp.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{"orders": []map[string]string{
+					{"order_id": "100", "symbol": "700.HK", "remark": "manual order"},
+					{"order_id": "101", "symbol": "700.HK", "remark": remark},
+				}},
+			})
+		}
+	}))
+	defer srv.Close()
+
+	httpClient, err := http.New(http.WithURL(srv.URL))
+	assert.NoError(t, err)
+	tc := &TradeContext{
+		opts:         newOptions(WithHttpClient(httpClient)),
+		risk:         newRiskControl(nil, nil),
+		clientOrders: newClientOrders(DefaultClientOrderCacheSize, DefaultClientOrderCacheTTL),
+	}
+	order := &SubmitOrder{
+		Symbol:            "700.HK",
+		OrderType:         OrderTypeLO,
+		Side:              OrderSideBuy,
+		SubmittedQuantity: 100,
+		SubmittedPrice:    decimal.NewFromInt(300),
+		TimeInForce:       TimeTypeDay,
+		Remark:            "rebalance",
+		ClientOrderId:     "rb-20240102-1",
+	}
+	orderId, err := tc.SubmitOrder(context.Background(), order)
+	assert.NoError(t, err)
+	assert.Equal(t, "101", orderId)
+	assert.Equal(t, "cid:rb-20240102-1|rebalance", remark)
+	assert.Equal(t, "rb-20240102-1", ClientOrderIdFromRemark(remark))
+
+	// resubmitting returns the existing order
+	orderId, err = tc.SubmitOrder(context.Background(), order)
+	assert.NoError(t, err)
+	assert.Equal(t, "101", orderId)
+	assert.Equal(t, 1, posts)
+
+	order.ClientOrderId = "bad key"
+	assert.Error(t, order.Validate())
+}
+
+func TestClientOrderIdConcurrent(t *testing.T) {
+	var posts int32
+	srv := httptest.NewServer(nhttp.HandlerFunc(func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		w.Header().Set("content-type", "application/json")
+		if r.Method == nhttp.MethodPost {
+			n := atomic.AddInt32(&posts, 1)
+			// keep the submission in flight while the others arrive
+			time.Sleep(20 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"code":0,"data":{"order_id":"` + strconv.Itoa(int(100+n)) + `"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"orders":[]}}`))
+	}))
+	defer srv.Close()
+
+	httpClient, err := http.New(http.WithURL(srv.URL))
+	assert.NoError(t, err)
+	tc := &TradeContext{
+		opts:         newOptions(WithHttpClient(httpClient)),
+		risk:         newRiskControl(nil, nil),
+		clientOrders: newClientOrders(DefaultClientOrderCacheSize, DefaultClientOrderCacheTTL),
+	}
+	var (
+		wg  sync.WaitGroup
+		ids = make([]string, 8)
+	)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := tc.SubmitOrder(context.Background(), &SubmitOrder{
+				Symbol:            "700.HK",
+				OrderType:         OrderTypeLO,
+				Side:              OrderSideBuy,
+				SubmittedQuantity: 100,
+				SubmittedPrice:    decimal.NewFromInt(300),
+				TimeInForce:       TimeTypeDay,
+				ClientOrderId:     "concurrent-1",
+			})
+			assert.NoError(t, err)
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&posts))
+	for _, id := range ids {
+		assert.Equal(t, "101", id)
+	}
+	assert.Equal(t, 0, len(tc.clientOrders.inflight))
+
+	// a waiter gives up when its ctx is done
+	release, err := tc.clientOrders.acquire(context.Background(), "concurrent-2")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = tc.clientOrders.acquire(ctx, "concurrent-2")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	release()
+	assert.Equal(t, 0, len(tc.clientOrders.inflight))
+}
+
+func TestClientOrderIdLookup(t *testing.T) {
+	var posts, gets int
+	srv := httptest.NewServer(nhttp.HandlerFunc(func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		w.Header().Set("content-type", "application/json")
+		if r.Method == nhttp.MethodPost {
+			posts++
+			_, _ = w.Write([]byte(`{"code":0,"data":{"order_id":"102"}}`))
+			return
+		}
+		gets++
+		assert.Equal(t, "700.HK", r.URL.Query().Get("symbol"))
+		// submitted before the restart
+		_, _ = w.Write([]byte(`{"code":0,"data":{"orders":[{"order_id":"99","symbol":"700.HK","remark":"cid:restart-1|rebalance"}]}}`))
+	}))
+	defer srv.Close()
+
+	httpClient, err := http.New(http.WithURL(srv.URL))
+	assert.NoError(t, err)
+	tc := &TradeContext{
+		opts:         newOptions(WithHttpClient(httpClient)),
+		risk:         newRiskControl(nil, nil),
+		clientOrders: newClientOrders(DefaultClientOrderCacheSize, DefaultClientOrderCacheTTL),
+	}
+	order := &SubmitOrder{
+		Symbol:            "700.HK",
+		OrderType:         OrderTypeLO,
+		Side:              OrderSideBuy,
+		SubmittedQuantity: 100,
+		SubmittedPrice:    decimal.NewFromInt(300),
+		TimeInForce:       TimeTypeDay,
+		ClientOrderId:     "restart-1",
+	}
+	orderId, err := tc.SubmitOrder(context.Background(), order)
+	assert.NoError(t, err)
+	assert.Equal(t, "99", orderId)
+	assert.Equal(t, 0, posts)
+
+	// a new client order id is looked up once, then remembered
+	order.ClientOrderId = "restart-2"
+	for i := 0; i < 2; i++ {
+		orderId, err = tc.SubmitOrder(context.Background(), order)
+		assert.NoError(t, err)
+		assert.Equal(t, "102", orderId)
+	}
+	assert.Equal(t, 1, posts)
+	assert.Equal(t, 2, gets)
+}
+
+func TestClientOrdersEviction(t *testing.T) {
+	now := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	co := newClientOrders(2, time.Hour)
+	co.now = func() time.Time { return now }
+
+	co.set("a", "1")
+	co.set("b", "2")
+	co.set("c", "3")
+	// the oldest is evicted by size
+	assert.Equal(t, "", co.get("a"))
+	assert.Equal(t, "2", co.get("b"))
+	assert.Equal(t, "3", co.get("c"))
+
+	now = now.Add(30 * time.Minute)
+	co.set("b", "2")
+	now = now.Add(40 * time.Minute)
+	// c is expired, b is renewed by set
+	assert.Equal(t, "", co.get("c"))
+	assert.Equal(t, "2", co.get("b"))
+	assert.Equal(t, 1, len(co.orderIds))
+	assert.Equal(t, 1, co.recent.Len())
+}
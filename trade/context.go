@@ -24,7 +24,7 @@ import (
 //	tctx.OnTrade(func(orderEvent *trade.PushEvent) {
 //	  fmt.Printf("order event: %v", orderEvent)
 //	})
-//	_, err := tctx.Subscribe(context.Background(), []string{"private"})
+//	_, err := tctx.Subscribe(context.Background(), []trade.Topic{trade.TopicPrivate})
 //	price := decimal.NewFromString("175.62")
 //	oid, err := tctx.SubmitOrder(context.Background(), &trade.SubmitOrder{
 //	  Symbol: "AAPL.US",
@@ -35,24 +35,41 @@ import (
 //	  TimeInForce: trade.TimeTypeDay,
 //	})
 type TradeContext struct {
-	opts *Options
-	core *core
+	opts         *Options
+	core         *core
+	risk         *riskControl
+	clientOrders *clientOrders
 }
 
-// OnQuote set callback function which will be called when server push events.
+// OnTrade set callback function which will be called when server push events,
+// it replaces the callback set before. Use AddTradeHandler to add more callbacks.
 func (c *TradeContext) OnTrade(f func(*PushEvent)) {
 	c.core.SetHandler(f)
 }
 
+// AddTradeHandler adds a callback function which will be called when server push events, after the callback of OnTrade.
+// The returned function removes the callback.
+func (c *TradeContext) AddTradeHandler(f func(*PushEvent)) (remove func()) {
+	return c.core.addHandler(f)
+}
+
+// TrackedOrderStatus returns the last valid order status received from push events.
+// Out-of-order or impossible updates are not applied, see PushEvent.TransitionErr.
+// It returns empty status if no push event of the order has been received,
+// or the order has been terminal for a while and is no longer tracked.
+func (c *TradeContext) TrackedOrderStatus(orderId string) OrderStatus {
+	return c.core.tracker.Status(orderId)
+}
+
 // Subscribe topics then the handler will receive push event.
 // Reference: https://open.longportapp.com/en/docs/trade/trade-push#subscribe
-func (c *TradeContext) Subscribe(ctx context.Context, topics []string) (subRes *SubResponse, err error) {
+func (c *TradeContext) Subscribe(ctx context.Context, topics []Topic) (subRes *SubResponse, err error) {
 	return c.core.Subscribe(ctx, topics)
 }
 
 // Unsubscribe topics then the handler will not receive the symbol's event.
 // Reference: https://open.longportapp.com/en/docs/trade/trade-push#cancel-subscribe
-func (c *TradeContext) Unsubscribe(ctx context.Context, topics []string) (unsubRes *UnsubResponse, err error) {
+func (c *TradeContext) Unsubscribe(ctx context.Context, topics []Topic) (unsubRes *UnsubResponse, err error) {
 	return c.core.Unsubscribe(ctx, topics)
 }
 
@@ -74,7 +91,7 @@ func (c *TradeContext) HistoryExecutions(ctx context.Context, params *GetHistory
 	if err != nil {
 		return
 	}
-	err = util.Copy(&trades, resp.Trades)
+	trades, err = toExecutions(resp.Trades)
 	return
 }
 
@@ -91,7 +108,7 @@ func (c *TradeContext) TodayExecutions(ctx context.Context, params *GetTodayExec
 	if err != nil {
 		return
 	}
-	err = util.Copy(&trades, resp.Trades)
+	trades, err = toExecutions(resp.Trades)
 	return
 }
 
@@ -112,7 +129,7 @@ func (c *TradeContext) HistoryOrders(ctx context.Context, params *GetHistoryOrde
 		return
 	}
 	hasMore = resp.HasMore
-	err = util.Copy(&orders, resp.Orders)
+	orders, err = toOrders(resp.Orders)
 	return
 }
 
@@ -130,11 +147,14 @@ func (c *TradeContext) TodayOrders(ctx context.Context, params *GetTodayOrders)
 	if err != nil {
 		return
 	}
-	err = util.Copy(&orders, resp.Orders)
+	orders, err = toOrders(resp.Orders)
 	return
 }
 
 // ReplaceOrder modify quantity or price
+// The request is checked by ReplaceOrder.Validate before being sent, *ValidationError is returned if it is invalid.
+// Then it is checked by the risk controls set by WithRiskConfig, *RiskRejection is returned if it is blocked.
+// Rules depending on the symbol query the OrderDetail of the order first.
 // Reference: https://open.longportapp.com/en/docs/trade/order/replace
 //
 // Example:
@@ -143,6 +163,12 @@ func (c *TradeContext) TodayOrders(ctx context.Context, params *GetTodayOrders)
 //	tctx, err := trade.NewFromCfg(conf)
 //	err := tctx.ReplaceOrder(context.Background(), &trade.ReplaceOrder{OrderId: "123123", Quantity: 2, Remark: "just replace the order"})
 func (c *TradeContext) ReplaceOrder(ctx context.Context, params *ReplaceOrder) (err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+	if err = c.checkReplaceRisk(ctx, params); err != nil {
+		return
+	}
 	var jsonbody jsontypes.ReplaceOrder
 	err = util.Copy(&jsonbody, params)
 	if err != nil {
@@ -153,6 +179,14 @@ func (c *TradeContext) ReplaceOrder(ctx context.Context, params *ReplaceOrder) (
 }
 
 // SubmitOrder HK and US stocks, warrant and option
+// The order is checked by SubmitOrder.Validate before being sent, *ValidationError is returned if it is invalid.
+// Then it is checked by the risk controls set by WithRiskConfig, *RiskRejection is returned if it is blocked.
+//
+// The order is submitted at most once per ClientOrderId, which is carried in the order remark.
+// Order ids of the ClientOrderIds submitted recently are remembered, see WithClientOrderCache. Other
+// ClientOrderIds are looked up in today's orders of the symbol before the order is placed, and again if
+// the submission fails ambiguously, e.g. times out. The existing order id is returned without error
+// instead of placing another order, see ClientOrderIdFromRemark.
 // Reference: https://open.longportapp.com/en/docs/trade/order/submit
 //
 // Example:
@@ -167,14 +201,28 @@ func (c *TradeContext) ReplaceOrder(ctx context.Context, params *ReplaceOrder) (
 //	  SubmittedPrice: price,
 //	  SubmittedQuantity: 2,
 //	  TimeInForce: trade.TimeTypeDay,
+//	  ClientOrderId: "my-order-1",
 //	})
-
 func (c *TradeContext) SubmitOrder(ctx context.Context, params *SubmitOrder) (orderId string, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+	if params.ClientOrderId != "" {
+		return c.submitClientOrder(ctx, params)
+	}
+	if err = c.checkSubmitRisk(ctx, params); err != nil {
+		return
+	}
+	return c.postOrder(ctx, params)
+}
+
+func (c *TradeContext) postOrder(ctx context.Context, params *SubmitOrder) (orderId string, err error) {
 	var jsonbody jsontypes.SubmitOrder
 	err = util.Copy(&jsonbody, params)
 	if err != nil {
 		return
 	}
+	jsonbody.Remark = params.remark()
 	resp := &jsontypes.SubmitOrderResponse{}
 	err = c.opts.httpClient.Post(ctx, "/v1/trade/order", jsonbody, resp)
 	if err != nil {
@@ -244,7 +292,7 @@ func (c *TradeContext) CashFlow(ctx context.Context, params *GetCashFlow) (cashf
 	if err != nil {
 		return
 	}
-	err = util.Copy(&cashflows, resp.List)
+	cashflows, err = toCashFlows(resp.List)
 	return
 }
 
@@ -284,7 +332,7 @@ func (c *TradeContext) StockPositions(ctx context.Context, symbols []string) (st
 	if err != nil {
 		return
 	}
-	err = util.Copy(&stockPositionChannels, resp.List)
+	stockPositionChannels, err = toStockPositionChannels(resp.List)
 	return
 }
 
@@ -322,7 +370,7 @@ func (c *TradeContext) OrderDetail(ctx context.Context, orderId string) (orderDe
 	if err != nil {
 		return
 	}
-	err = util.Copy(&orderDetail, resp)
+	orderDetail, err = toOrderDetail(&resp)
 	return
 }
 
@@ -366,13 +414,15 @@ func NewFormEnv() (*TradeContext, error) {
 }
 
 // NewFromCfg return TradeContext with config.Config.
-func NewFromCfg(cfg *config.Config) (*TradeContext, error) {
+// Additional options are applied after the options from config, e.g. WithPriceSource.
+func NewFromCfg(cfg *config.Config, opt ...Option) (*TradeContext, error) {
 	httpClient, err := http.New(
 		http.WithAccessToken(cfg.AccessToken),
 		http.WithAppKey(cfg.AppKey),
 		http.WithAppSecret(cfg.AppSecret),
 		http.WithURL(cfg.HttpURL),
 		http.WithLanguage(cfg.Language),
+		http.WithRateLimitConfig(&cfg.RateLimit),
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "create http client error")
@@ -385,13 +435,15 @@ func NewFromCfg(cfg *config.Config) (*TradeContext, error) {
 		longbridge.WithWriteQueueSize(cfg.WriteQueueSize),
 		longbridge.WithMinGzipSize(cfg.MinGzipSize),
 	)
-	return New(
+	opts := []Option{
 		WithTradeURL(cfg.TradeUrl),
 		WithHttpClient(httpClient),
 		WithLbOptions(lbOpts),
 		WithLogger(cfg.Logger()),
 		WithLogLevel(cfg.LogLevel),
-	)
+		WithRiskConfig(&cfg.Risk),
+	}
+	return New(append(opts, opt...)...)
 }
 
 // New return TradeContext with option.
@@ -403,8 +455,10 @@ func New(opt ...Option) (*TradeContext, error) {
 		return nil, errors.Wrap(err, "failed to create core")
 	}
 	tc := &TradeContext{
-		opts: opts,
-		core: core,
+		opts:         opts,
+		core:         core,
+		risk:         newRiskControl(opts.riskConfig, opts.priceSource),
+		clientOrders: newClientOrders(opts.clientOrderCacheSize, opts.clientOrderCacheTTL),
 	}
 	return tc, nil
 }
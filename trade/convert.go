@@ -0,0 +1,58 @@
+package trade
+
+import (
+	"github.com/longportapp/openapi-go/internal/util"
+	"github.com/longportapp/openapi-go/trade/jsontypes"
+)
+
+// The functions below convert the API responses by util.Copy and keep the responses in Raw
+
+func toOrders(raw []*jsontypes.Order) (orders []*Order, err error) {
+	if err = util.Copy(&orders, raw); err != nil {
+		return
+	}
+	for i, o := range orders {
+		o.Raw = raw[i]
+	}
+	return
+}
+
+func toExecutions(raw []*jsontypes.Execution) (trades []*Execution, err error) {
+	if err = util.Copy(&trades, raw); err != nil {
+		return
+	}
+	for i, t := range trades {
+		t.Raw = raw[i]
+	}
+	return
+}
+
+func toCashFlows(raw []*jsontypes.CashFlow) (cashflows []*CashFlow, err error) {
+	if err = util.Copy(&cashflows, raw); err != nil {
+		return
+	}
+	for i, f := range cashflows {
+		f.Raw = raw[i]
+	}
+	return
+}
+
+func toStockPositionChannels(raw []*jsontypes.StockPositionChannel) (channels []*StockPositionChannel, err error) {
+	if err = util.Copy(&channels, raw); err != nil {
+		return
+	}
+	for i, ch := range channels {
+		for j, p := range ch.Positions {
+			p.Raw = raw[i].Positions[j]
+		}
+	}
+	return
+}
+
+func toOrderDetail(raw *jsontypes.OrderDetail) (detail OrderDetail, err error) {
+	if err = util.Copy(&detail, raw); err != nil {
+		return
+	}
+	detail.Raw = raw
+	return
+}
@@ -0,0 +1,50 @@
+package trade
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/longportapp/openapi-go/trade/jsontypes"
+)
+
+func TestToOrders(t *testing.T) {
+	var resp jsontypes.Orders
+	assert.NoError(t, json.Unmarshal([]byte(`{"orders":[{"order_id":"1","quantity":"200","executed_quantity":"0",
+		"submitted_at":"1704160821","updated_at":"1704160825","trigger_at":"0","expire_date":"2024-01-05","time_in_force":"GTD"}]}`), &resp))
+	orders, err := toOrders(resp.Orders)
+	assert.NoError(t, err)
+	o := orders[0]
+	assert.Equal(t, "200", o.Quantity.String())
+	assert.True(t, o.ExecutedQuantity.IsZero())
+	assert.Equal(t, time.Unix(1704160821, 0), o.SubmittedAt)
+	assert.Equal(t, time.Unix(1704160825, 0), o.UpdatedAt)
+	assert.True(t, o.TriggerAt.IsZero())
+	assert.Equal(t, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), o.ExpireDate)
+	assert.Equal(t, "1704160821", o.Raw.SubmittedAt)
+	assert.Equal(t, "2024-01-05", o.Raw.ExpireDate)
+
+	var data jsontypes.OrderDetail
+	assert.NoError(t, json.Unmarshal([]byte(`{"order_id":"1","quantity":"100","executed_quantity":"100",
+		"submitted_at":"1704160821","updated_at":"1704160825","trigger_at":"0",
+		"history":[{"price":"320.200","quantity":"100","status":"FilledStatus","time":"1704160825"}]}`), &data))
+	detail, err := toOrderDetail(&data)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1704160825, 0), detail.History[0].Time)
+	assert.Equal(t, "1704160825", detail.Raw.History[0].Time)
+}
+
+func TestToOrdersOddTime(t *testing.T) {
+	var resp jsontypes.Orders
+	assert.NoError(t, json.Unmarshal([]byte(`{"orders":[{"order_id":"1","quantity":"100","executed_quantity":"0",
+		"submitted_at":"1704160821","updated_at":"2024/01/02 10:00"},{"order_id":"2","quantity":"100","executed_quantity":"0",
+		"submitted_at":"1704160821","updated_at":"1704160825"}]}`), &resp))
+	orders, err := toOrders(resp.Orders)
+	assert.NoError(t, err)
+	assert.True(t, orders[0].UpdatedAt.IsZero())
+	assert.Equal(t, "2024/01/02 10:00", orders[0].Raw.UpdatedAt)
+	assert.Equal(t, time.Unix(1704160821, 0), orders[0].SubmittedAt)
+	assert.Equal(t, time.Unix(1704160825, 0), orders[1].UpdatedAt)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"sync"
 
+	"github.com/longportapp/openapi-go/http"
 	"github.com/longportapp/openapi-go/internal/util"
 	"github.com/longportapp/openapi-go/log"
 	"github.com/longportapp/openapi-go/trade/jsontypes"
@@ -18,8 +19,16 @@ import (
 type core struct {
 	client        client.Client
 	url           string
-	subscriptions []string
+	subscriptions []Topic
 	mu            sync.Mutex
+	tracker       *orderTracker
+	handlersMu    sync.RWMutex
+	handler       *pushHandler   // set by OnTrade
+	handlers      []*pushHandler // added by AddTradeHandler
+}
+
+type pushHandler struct {
+	f func(*PushEvent)
 }
 
 func newCore(opts *Options) (*core, error) {
@@ -51,10 +60,11 @@ func newCore(opts *Options) (*core, error) {
 		client.WriteQueueSize(opts.lbOpts.WriteQueueSize),
 	)
 	if err != nil {
-		return nil, err
+		return nil, http.WrapProtocolError(err)
 	}
 
-	core := &core{client: cl, url: opts.tradeURL}
+	core := &core{client: cl, url: opts.tradeURL, tracker: newOrderTracker()}
+	core.client.Subscribe(uint32(tradev1.Command_CMD_NOTIFY), parseNotifyFunc(core.dispatch, core.tracker))
 
 	core.client.AfterReconnected(func() {
 		resubFlag := true
@@ -70,20 +80,57 @@ func newCore(opts *Options) (*core, error) {
 	return core, nil
 }
 
+// SetHandler replaces the handler of OnTrade, handlers added by addHandler are kept.
 func (c *core) SetHandler(f func(*PushEvent)) {
-	c.client.Subscribe(uint32(tradev1.Command_CMD_NOTIFY), parseNotifyFunc(f))
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	if f == nil {
+		c.handler = nil
+		return
+	}
+	c.handler = &pushHandler{f: f}
 }
 
-func (c *core) Subscribe(ctx context.Context, topics []string) (subRes *SubResponse, err error) {
+// addHandler registers f to receive push events, the returned function removes it.
+func (c *core) addHandler(f func(*PushEvent)) (remove func()) {
+	h := &pushHandler{f: f}
+	c.handlersMu.Lock()
+	c.handlers = append(c.handlers, h)
+	c.handlersMu.Unlock()
+	return func() {
+		c.handlersMu.Lock()
+		defer c.handlersMu.Unlock()
+		for i, v := range c.handlers {
+			if v == h {
+				c.handlers = append(c.handlers[:i:i], c.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (c *core) dispatch(event *PushEvent) {
+	c.handlersMu.RLock()
+	handler, handlers := c.handler, c.handlers
+	c.handlersMu.RUnlock()
+	if handler != nil {
+		handler.f(event)
+	}
+	for _, h := range handlers {
+		h.f(event)
+	}
+}
+
+func (c *core) Subscribe(ctx context.Context, topics []Topic) (subRes *SubResponse, err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	return c.doSubscribe(ctx, topics)
 }
 
-func (c *core) doSubscribe(ctx context.Context, topics []string) (subRes *SubResponse, err error) {
+func (c *core) doSubscribe(ctx context.Context, topics []Topic) (subRes *SubResponse, err error) {
 	var res *protocol.Packet
-	req := &tradev1.Sub{Topics: topics}
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(tradev1.Command_CMD_SUB), Body: req})
+	req := &tradev1.Sub{Topics: topicStrings(topics)}
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(tradev1.Command_CMD_SUB), Body: req})
 	if err != nil {
 		return
 	}
@@ -92,22 +139,22 @@ func (c *core) doSubscribe(ctx context.Context, topics []string) (subRes *SubRes
 		return
 	}
 	subRes = &SubResponse{}
-	subRes.Current = tradeRes.GetCurrent()
-	subRes.Success = tradeRes.GetSuccess()
-	subRes.Fail = make([]*SubResponseFail, len(tradeRes.GetFail()))
+	subRes.Current = toTopics(tradeRes.GetCurrent())
+	subRes.Success = toTopics(tradeRes.GetSuccess())
+	subRes.Fail = make([]*SubResponseFail, 0, len(tradeRes.GetFail()))
 	for _, f := range tradeRes.GetFail() {
-		subRes.Fail = append(subRes.Fail, &SubResponseFail{Topic: f.GetTopic(), Reason: f.GetReason()})
+		subRes.Fail = append(subRes.Fail, &SubResponseFail{Topic: Topic(f.GetTopic()), Reason: f.GetReason()})
 	}
 	c.subscriptions = subRes.Current
 	return
 }
 
-func (c *core) Unsubscribe(ctx context.Context, topics []string) (unsubRes *UnsubResponse, err error) {
+func (c *core) Unsubscribe(ctx context.Context, topics []Topic) (unsubRes *UnsubResponse, err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	var res *protocol.Packet
-	req := &tradev1.Unsub{Topics: topics}
-	res, err = c.client.Do(ctx, &client.Request{Cmd: uint32(tradev1.Command_CMD_UNSUB), Body: req})
+	req := &tradev1.Unsub{Topics: topicStrings(topics)}
+	res, err = c.do(ctx, &client.Request{Cmd: uint32(tradev1.Command_CMD_UNSUB), Body: req})
 	if err != nil {
 		return
 	}
@@ -116,8 +163,8 @@ func (c *core) Unsubscribe(ctx context.Context, topics []string) (unsubRes *Unsu
 		return
 	}
 	unsubRes = &UnsubResponse{}
-	unsubRes.Current = tradeRes.GetCurrent()
-	c.subscriptions = tradeRes.GetCurrent()
+	unsubRes.Current = toTopics(tradeRes.GetCurrent())
+	c.subscriptions = unsubRes.Current
 	return
 }
 
@@ -134,11 +181,17 @@ func (c *core) resubscribe(ctx context.Context) error {
 	return nil
 }
 
+// do sends the request, errors of the server are classified by the sentinel errors of http, see http.IsRetryable
+func (c *core) do(ctx context.Context, req *client.Request) (*protocol.Packet, error) {
+	res, err := c.client.Do(ctx, req)
+	return res, http.WrapProtocolError(err)
+}
+
 func (c *core) Close() error {
 	return c.client.Close(nil)
 }
 
-func parseNotifyFunc(f func(*PushEvent)) func(*protocol.Packet) {
+func parseNotifyFunc(f func(*PushEvent), tracker *orderTracker) func(*protocol.Packet) {
 	return func(packet *protocol.Packet) {
 		var notify tradev1.Notification
 		if err := packet.Unmarshal(&notify); err != nil {
@@ -150,11 +203,44 @@ func parseNotifyFunc(f func(*PushEvent)) func(*protocol.Packet) {
 			log.Errorf("trade context json unmarshal push event error:%v", err)
 			return
 		}
-		var event PushEvent
-		if err := util.Copy(&event, data); err != nil {
-			log.Errorf("trade context copy push event error:%v", err)
-			return
+		event := PushEvent{Event: EventKind(data.Event)}
+		switch event.Event {
+		case EventOrderChanged:
+			var changed jsontypes.PushOrderChanged
+			if err := json.Unmarshal(data.Data, &changed); err != nil {
+				log.Errorf("trade context json unmarshal order changed event error:%v", err)
+				return
+			}
+			event.Data = &PushOrderChanged{}
+			if err := util.Copy(event.Data, changed); err != nil {
+				log.Errorf("trade context copy order changed event error:%v", err)
+				return
+			}
+			event.Data.Raw = &changed
+			if err := tracker.Apply(event.Data.OrderId, event.Data.Status); err != nil {
+				log.Warnf("trade context push event status ignored:%v", err)
+				event.TransitionErr = err
+			}
+		default:
+			// unknown kinds are delivered as is
+			event.Raw = data.Data
 		}
 		f(&event)
 	}
 }
+
+func topicStrings(topics []Topic) []string {
+	ss := make([]string, len(topics))
+	for i, t := range topics {
+		ss[i] = string(t)
+	}
+	return ss
+}
+
+func toTopics(ss []string) []Topic {
+	topics := make([]Topic, len(ss))
+	for i, s := range ss {
+		topics[i] = Topic(s)
+	}
+	return topics
+}
@@ -0,0 +1,405 @@
+package trade
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultIterWindow is the longest time range of a request sent by iterators
+	DefaultIterWindow = 90 * 24 * time.Hour
+	// DefaultIterPageSize is the page size of CashFlow requests sent by iterators
+	DefaultIterPageSize = 100
+)
+
+// IterOptions for iterators
+type IterOptions struct {
+	Window   time.Duration
+	PageSize int64
+}
+
+// IterOption for iterators
+type IterOption func(*IterOptions)
+
+// WithIterWindow to set the longest time range of a request, longer ranges are split. Default is DefaultIterWindow.
+func WithIterWindow(d time.Duration) IterOption {
+	return func(o *IterOptions) {
+		if d > 0 {
+			o.Window = d
+		}
+	}
+}
+
+// WithIterPageSize to set page size of CashFlow requests. Default is DefaultIterPageSize.
+func WithIterPageSize(size int64) IterOption {
+	return func(o *IterOptions) {
+		if size > 0 {
+			o.PageSize = size
+		}
+	}
+}
+
+func newIterOptions(opt ...IterOption) *IterOptions {
+	opts := &IterOptions{Window: DefaultIterWindow, PageSize: DefaultIterPageSize}
+	for _, o := range opt {
+		o(opts)
+	}
+	return opts
+}
+
+type timeRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// splitRange splits [start, end] into windows from the newest to the oldest.
+// Adjacent windows share the boundary second, iterators remove the duplicates.
+func splitRange(start, end time.Time, window time.Duration) []timeRange {
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		start = end.Add(-window)
+	}
+	var ranges []timeRange
+	for e := end; e.After(start); e = e.Add(-window) {
+		s := e.Add(-window)
+		if s.Before(start) {
+			s = start
+		}
+		ranges = append(ranges, timeRange{start: s, end: e})
+	}
+	return ranges
+}
+
+func unixTime(v int64) time.Time {
+	if v <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(v, 0)
+}
+
+// pager walks windows and narrows the end of the current window by the results
+type pager struct {
+	ctx     context.Context
+	windows []timeRange
+	idx     int
+	end     time.Time // end of the next request in the current window, zero means the window end
+	seen    map[string]bool
+	err     error
+}
+
+func (p *pager) done() bool {
+	if p.err != nil || p.idx >= len(p.windows) {
+		return true
+	}
+	if err := p.ctx.Err(); err != nil {
+		p.err = err
+		return true
+	}
+	return false
+}
+
+func (p *pager) window() timeRange {
+	w := p.windows[p.idx]
+	if !p.end.IsZero() {
+		w.end = p.end
+	}
+	return w
+}
+
+// narrow continues the current window before oldest if there may be more results, otherwise moves to the next window
+func (p *pager) narrow(more bool, fresh int, oldest time.Time) {
+	w := p.window()
+	if more && fresh > 0 && !oldest.IsZero() && oldest.After(w.start) && !oldest.After(w.end) {
+		p.end = oldest
+		return
+	}
+	p.idx++
+	p.end = time.Time{}
+}
+
+// fresh reports whether key is not seen and marks it
+func (p *pager) fresh(key string) bool {
+	if p.seen[key] {
+		return false
+	}
+	p.seen[key] = true
+	return true
+}
+
+// OrderIterator walks history orders from the newest to the oldest
+type OrderIterator struct {
+	pager
+	fetch  func(ctx context.Context, params *GetHistoryOrders) ([]*Order, bool, error)
+	params GetHistoryOrders
+	buf    []*Order
+	cur    *Order
+}
+
+// Next advances to the next order, it returns false when there is no more order or an error happens
+func (it *OrderIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done() {
+			return false
+		}
+		w := it.window()
+		params := it.params
+		params.StartAt, params.EndAt = w.start.Unix(), w.end.Unix()
+		orders, hasMore, err := it.fetch(it.ctx, &params)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		var oldest time.Time
+		for _, o := range orders {
+			if at := o.SubmittedAt; !at.IsZero() && (oldest.IsZero() || at.Before(oldest)) {
+				oldest = at
+			}
+			if it.fresh(o.OrderId) {
+				it.buf = append(it.buf, o)
+			}
+		}
+		it.narrow(hasMore, len(it.buf), oldest)
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Order returns the current order
+func (it *OrderIterator) Order() *Order {
+	return it.cur
+}
+
+// Err returns the error stopped the iterator, including the error of ctx
+func (it *OrderIterator) Err() error {
+	return it.err
+}
+
+// HistoryOrdersIter returns an iterator of history orders in [params.StartAt, params.EndAt].
+// The range is split into windows of WithIterWindow, and each window is paged by narrowing the end time
+// to the oldest order of the last page while HistoryOrders has more. Orders are de-duplicated by OrderId.
+// Zero EndAt means now, zero StartAt means one window before EndAt.
+//
+// Example:
+//
+//	it := tctx.HistoryOrdersIter(ctx, &trade.GetHistoryOrders{
+//	  Symbol:  "700.HK",
+//	  StartAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+//	})
+//	for it.Next() {
+//	  order := it.Order()
+//	}
+//	if err := it.Err(); err != nil {
+//	  return err
+//	}
+func (c *TradeContext) HistoryOrdersIter(ctx context.Context, params *GetHistoryOrders, opt ...IterOption) *OrderIterator {
+	return NewOrderIterator(ctx, c.HistoryOrders, params, opt...)
+}
+
+// NewOrderIterator returns an iterator like HistoryOrdersIter, which pages by fetch instead of TradeContext.HistoryOrders
+func NewOrderIterator(ctx context.Context, fetch func(ctx context.Context, params *GetHistoryOrders) ([]*Order, bool, error), params *GetHistoryOrders, opt ...IterOption) *OrderIterator {
+	opts := newIterOptions(opt...)
+	it := &OrderIterator{fetch: fetch}
+	if params != nil {
+		it.params = *params
+	}
+	it.pager = pager{
+		ctx:     ctx,
+		windows: splitRange(unixTime(it.params.StartAt), unixTime(it.params.EndAt), opts.Window),
+		seen:    make(map[string]bool),
+	}
+	return it
+}
+
+// ExecutionIterator walks history executions from the newest to the oldest
+type ExecutionIterator struct {
+	pager
+	fetch  func(ctx context.Context, params *GetHistoryExecutions) ([]*Execution, error)
+	params GetHistoryExecutions
+	buf    []*Execution
+	cur    *Execution
+}
+
+// Next advances to the next execution, it returns false when there is no more execution or an error happens
+func (it *ExecutionIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done() {
+			return false
+		}
+		w := it.window()
+		params := it.params
+		params.StartAt, params.EndAt = w.start, w.end
+		trades, err := it.fetch(it.ctx, &params)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		var oldest time.Time
+		for _, t := range trades {
+			if !t.TradeDoneAt.IsZero() && (oldest.IsZero() || t.TradeDoneAt.Before(oldest)) {
+				oldest = t.TradeDoneAt
+			}
+			if it.fresh(t.OrderId + "/" + t.TradeId) {
+				it.buf = append(it.buf, t)
+			}
+		}
+		// HistoryExecutions has no more flag, keep narrowing until a page has no new execution
+		it.narrow(len(trades) > 0, len(it.buf), oldest)
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// Execution returns the current execution
+func (it *ExecutionIterator) Execution() *Execution {
+	return it.cur
+}
+
+// Err returns the error stopped the iterator, including the error of ctx
+func (it *ExecutionIterator) Err() error {
+	return it.err
+}
+
+// HistoryExecutionsIter returns an iterator of history executions in [params.StartAt, params.EndAt].
+// The range is split into windows of WithIterWindow, and each window is paged by narrowing the end time
+// to the oldest execution of the last page until no new execution is returned.
+// Executions are de-duplicated by OrderId and TradeId.
+// Zero EndAt means now, zero StartAt means one window before EndAt.
+//
+// Example:
+//
+//	it := tctx.HistoryExecutionsIter(ctx, &trade.GetHistoryExecutions{
+//	  StartAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+//	  EndAt:   time.Now(),
+//	})
+//	for it.Next() {
+//	  execution := it.Execution()
+//	}
+//	err := it.Err()
+func (c *TradeContext) HistoryExecutionsIter(ctx context.Context, params *GetHistoryExecutions, opt ...IterOption) *ExecutionIterator {
+	return NewExecutionIterator(ctx, c.HistoryExecutions, params, opt...)
+}
+
+// NewExecutionIterator returns an iterator like HistoryExecutionsIter, which pages by fetch instead of TradeContext.HistoryExecutions
+func NewExecutionIterator(ctx context.Context, fetch func(ctx context.Context, params *GetHistoryExecutions) ([]*Execution, error), params *GetHistoryExecutions, opt ...IterOption) *ExecutionIterator {
+	opts := newIterOptions(opt...)
+	it := &ExecutionIterator{fetch: fetch}
+	if params != nil {
+		it.params = *params
+	}
+	it.pager = pager{
+		ctx:     ctx,
+		windows: splitRange(it.params.StartAt, it.params.EndAt, opts.Window),
+		seen:    make(map[string]bool),
+	}
+	return it
+}
+
+// CashFlowIterator walks cash flows window by window from the newest to the oldest
+type CashFlowIterator struct {
+	pager
+	fetch  func(ctx context.Context, params *GetCashFlow) ([]*CashFlow, error)
+	params GetCashFlow
+	size   int64
+	page   int64
+	buf    []*CashFlow
+	cur    *CashFlow
+	// edge counts the cash flows of the current window at its start second,
+	// boundary counts those of the newer window at the end second of the current window
+	edge     map[string]int
+	boundary map[string]int
+}
+
+// Next advances to the next cash flow, it returns false when there is no more cash flow or an error happens
+func (it *CashFlowIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done() {
+			return false
+		}
+		w := it.windows[it.idx]
+		it.page++
+		params := it.params
+		params.StartAt, params.EndAt = w.start.Unix(), w.end.Unix()
+		params.Page, params.Size = it.page, it.size
+		flows, err := it.fetch(it.ctx, &params)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		for _, f := range flows {
+			at := f.BusinessTime.Unix()
+			if at == w.end.Unix() && it.boundary[cashFlowKey(f)] > 0 {
+				// returned by the newer window which shares the boundary second
+				it.boundary[cashFlowKey(f)]--
+				continue
+			}
+			if at == w.start.Unix() {
+				it.edge[cashFlowKey(f)]++
+			}
+			it.buf = append(it.buf, f)
+		}
+		if int64(len(flows)) < it.size {
+			it.idx++
+			it.page = 0
+			it.boundary, it.edge = it.edge, make(map[string]int)
+		}
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+// CashFlow returns the current cash flow
+func (it *CashFlowIterator) CashFlow() *CashFlow {
+	return it.cur
+}
+
+// Err returns the error stopped the iterator, including the error of ctx
+func (it *CashFlowIterator) Err() error {
+	return it.err
+}
+
+// cashFlowKey is the content of a cash flow, cash flows have no id
+func cashFlowKey(f *CashFlow) string {
+	balance := ""
+	if f.Balance != nil {
+		balance = f.Balance.String()
+	}
+	return fmt.Sprintf("%s|%d|%d|%s|%s|%d|%s|%s", f.TransactionFlowName, f.Direction, f.BusinessType,
+		balance, f.Currency, f.BusinessTime.Unix(), f.Symbol, f.Description)
+}
+
+// CashFlowIter returns an iterator of cash flows in [params.StartAt, params.EndAt].
+// The range is split into windows of WithIterWindow, and each window is paged by Page and Size of WithIterPageSize.
+// params.Page and params.Size are ignored. Adjacent windows share the boundary second, the cash flows of that second
+// returned by both windows are removed from the older one, identical cash flows within a window are all kept.
+// Zero EndAt means now, zero StartAt means one window before EndAt.
+//
+// Example:
+//
+//	it := tctx.CashFlowIter(ctx, &trade.GetCashFlow{
+//	  StartAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+//	  EndAt:   time.Now().Unix(),
+//	}, trade.WithIterPageSize(500))
+//	for it.Next() {
+//	  flow := it.CashFlow()
+//	}
+//	err := it.Err()
+func (c *TradeContext) CashFlowIter(ctx context.Context, params *GetCashFlow, opt ...IterOption) *CashFlowIterator {
+	return NewCashFlowIterator(ctx, c.CashFlow, params, opt...)
+}
+
+// NewCashFlowIterator returns an iterator like CashFlowIter, which pages by fetch instead of TradeContext.CashFlow
+func NewCashFlowIterator(ctx context.Context, fetch func(ctx context.Context, params *GetCashFlow) ([]*CashFlow, error), params *GetCashFlow, opt ...IterOption) *CashFlowIterator {
+	opts := newIterOptions(opt...)
+	it := &CashFlowIterator{fetch: fetch, size: opts.PageSize, edge: make(map[string]int)}
+	if params != nil {
+		it.params = *params
+	}
+	it.pager = pager{
+		ctx:     ctx,
+		windows: splitRange(unixTime(it.params.StartAt), unixTime(it.params.EndAt), opts.Window),
+	}
+	return it
+}
@@ -0,0 +1,114 @@
+package trade
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+)
+
+func TestSplitRange(t *testing.T) {
+	end := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	ranges := splitRange(end.AddDate(0, 0, -200), end, DefaultIterWindow)
+	assert.Equal(t, 3, len(ranges))
+	assert.Equal(t, end, ranges[0].end)
+	assert.Equal(t, ranges[0].start, ranges[1].end)
+	assert.Equal(t, end.AddDate(0, 0, -200), ranges[2].start)
+}
+
+func TestOrderIterator(t *testing.T) {
+	end := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	// one order per day for 100 days
+	var all []*Order
+	for i := 0; i < 100; i++ {
+		at := end.AddDate(0, 0, -i)
+		all = append(all, &Order{OrderId: fmt.Sprint(i), SubmittedAt: at})
+	}
+	var calls int
+	fetch := func(ctx context.Context, params *GetHistoryOrders) ([]*Order, bool, error) {
+		calls++
+		var page []*Order
+		for _, o := range all {
+			at := o.SubmittedAt.Unix()
+			if at >= params.StartAt && at <= params.EndAt {
+				page = append(page, o)
+			}
+		}
+		if len(page) > 30 {
+			return page[:30], true, nil
+		}
+		return page, false, nil
+	}
+
+	ctx := context.Background()
+	it := &OrderIterator{fetch: fetch}
+	it.pager = pager{ctx: ctx, windows: splitRange(end.AddDate(0, 0, -99), end, 60*24*time.Hour), seen: map[string]bool{}}
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Order().OrderId)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, 100, len(ids))
+	assert.Equal(t, "0", ids[0])
+	assert.Equal(t, "99", ids[99])
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	it = &OrderIterator{fetch: fetch}
+	it.pager = pager{ctx: ctx, windows: splitRange(time.Time{}, end, DefaultIterWindow), seen: map[string]bool{}}
+	assert.Equal(t, false, it.Next())
+	assert.Equal(t, context.Canceled, it.Err())
+}
+
+func TestCashFlowIterator(t *testing.T) {
+	end := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	boundary := end.AddDate(0, 0, -10)
+	fee := func(at time.Time) *CashFlow {
+		amount := decimal.RequireFromString("-15")
+		return &CashFlow{TransactionFlowName: "Platform Fee", Direction: 1, Balance: &amount, Currency: "HKD", BusinessTime: at}
+	}
+	all := []*CashFlow{
+		fee(end.AddDate(0, 0, -1)),
+		// identical fees in the same second are all kept
+		fee(end.AddDate(0, 0, -2)),
+		fee(end.AddDate(0, 0, -2)),
+		fee(end.AddDate(0, 0, -3)),
+		// returned by both windows
+		fee(boundary),
+		fee(boundary),
+		fee(end.AddDate(0, 0, -15)),
+	}
+	fetch := func(ctx context.Context, params *GetCashFlow) ([]*CashFlow, error) {
+		var flows []*CashFlow
+		for _, f := range all {
+			if at := f.BusinessTime.Unix(); at >= params.StartAt && at <= params.EndAt {
+				flows = append(flows, f)
+			}
+		}
+		from := (params.Page - 1) * params.Size
+		if from >= int64(len(flows)) {
+			return nil, nil
+		}
+		if to := from + params.Size; to < int64(len(flows)) {
+			return flows[from:to], nil
+		}
+		return flows[from:], nil
+	}
+
+	it := NewCashFlowIterator(context.Background(), fetch, &GetCashFlow{
+		StartAt: end.AddDate(0, 0, -20).Unix(),
+		EndAt:   end.Unix(),
+	}, WithIterWindow(10*24*time.Hour), WithIterPageSize(2))
+	var flows []*CashFlow
+	for it.Next() {
+		flows = append(flows, it.CashFlow())
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, len(all), len(flows))
+	for i := range all {
+		assert.Equal(t, all[i].BusinessTime, flows[i].BusinessTime)
+	}
+}
@@ -1,5 +1,7 @@
 package jsontypes
 
+import "encoding/json"
+
 // Execution is execution details
 type Execution struct {
 	OrderId     string `json:"order_id"`
@@ -34,7 +36,7 @@ type Order struct {
 	ExecutedQuantity string `json:"executed_quantity"`
 	Price            string `json:"price"`
 	ExecutedPrice    string `json:"executed_price"`
-	SubmittedAt      string `json:"submmited_at"`
+	SubmittedAt      string `json:"submitted_at"`
 	Side             string `json:"side"`
 	Symbol           string `json:"symbol"`
 	OrderType        string `json:"order_type"`
@@ -146,8 +148,8 @@ type CashInfo struct {
 
 // PushEvent is quote context callback event
 type PushEvent struct {
-	Event string            `json:"event"`
-	Data  *PushOrderChanged `json:"data"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
 }
 
 // PushOrderChanged is order change event details
@@ -0,0 +1,56 @@
+package lots
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// WriteOpenLotsCSV writes open lots as CSV with a header
+func WriteOpenLotsCSV(w io.Writer, lots []Lot) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"symbol", "currency", "order_id", "trade_id", "opened_at", "quantity", "price", "cost"})
+	for _, l := range lots {
+		_ = cw.Write([]string{
+			l.Symbol, l.Currency, l.OrderId, l.TradeId, formatTime(l.OpenedAt),
+			l.Quantity.String(), l.Price.String(), l.Cost().String(),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteClosedLotsCSV writes closed lots as CSV with a header
+func WriteClosedLotsCSV(w io.Writer, lots []ClosedLot) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"symbol", "currency", "open_trade_id", "opened_at", "open_price",
+		"close_trade_id", "closed_at", "close_price", "quantity", "gain"})
+	for _, l := range lots {
+		_ = cw.Write([]string{
+			l.Symbol, l.Currency, l.OpenTradeId, formatTime(l.OpenedAt), l.OpenPrice.String(),
+			l.CloseTradeId, formatTime(l.ClosedAt), l.ClosePrice.String(), l.Quantity.String(), l.Gain.String(),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteSummariesCSV writes summaries as CSV with a header
+func WriteSummariesCSV(w io.Writer, summaries []Summary) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"currency", "symbol", "realized", "fees", "income", "net"})
+	for _, s := range summaries {
+		_ = cw.Write([]string{
+			s.Currency, s.Symbol, s.Realized.String(), s.Fees.String(), s.Income.String(), s.Net.String(),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
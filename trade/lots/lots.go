@@ -0,0 +1,427 @@
+// Package lots computes cost basis, tax lots and realized gains from execution history
+package lots
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// Method to match closing trades with open lots
+type Method string
+
+const (
+	FIFO    Method = "FIFO"    // first in first out
+	LIFO    Method = "LIFO"    // last in first out
+	Average Method = "AVERAGE" // average cost, all open lots of a symbol share the average price
+	// SpecificLot matches by the LotSelector set by WithLotSelector, the rest quantity falls back to FIFO
+	SpecificLot Method = "SPECIFIC"
+)
+
+// Trade is an execution with side and currency
+type Trade struct {
+	OrderId  string
+	TradeId  string
+	Symbol   string
+	Side     trade.OrderSide
+	Currency string
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+	Time     time.Time
+}
+
+// Lot is an open lot, Quantity is negative for short lots
+type Lot struct {
+	Symbol   string
+	Currency string
+	OrderId  string
+	TradeId  string
+	OpenedAt time.Time
+	Quantity decimal.Decimal
+	Price    decimal.Decimal // cost per share
+}
+
+// Cost returns the cost of the lot
+func (l *Lot) Cost() decimal.Decimal {
+	return l.Price.Mul(l.Quantity)
+}
+
+// ClosedLot is a closed part of a lot, Quantity is negative for short lots
+type ClosedLot struct {
+	Symbol       string
+	Currency     string
+	OpenTradeId  string
+	OpenedAt     time.Time
+	OpenPrice    decimal.Decimal
+	CloseTradeId string
+	ClosedAt     time.Time
+	ClosePrice   decimal.Decimal
+	Quantity     decimal.Decimal
+	Gain         decimal.Decimal
+}
+
+// LotMatch is the quantity closed from the open lot of TradeId
+type LotMatch struct {
+	TradeId  string
+	Quantity decimal.Decimal
+}
+
+// LotSelector selects open lots closed by the trade for SpecificLot method
+type LotSelector func(t *Trade, open []Lot) []LotMatch
+
+// CashFlowKind classifies cash flows
+type CashFlowKind int
+
+const (
+	CashFlowIgnore CashFlowKind = iota
+	CashFlowFee                 // fees and commissions
+	CashFlowIncome              // corporate cash like dividends
+)
+
+// CashFlowClassifier classifies a cash flow
+type CashFlowClassifier func(f *trade.CashFlow) CashFlowKind
+
+// DefaultCashFlowClassifier classifies cash flows of a symbol by keywords of TransactionFlowName and Description
+func DefaultCashFlowClassifier(f *trade.CashFlow) CashFlowKind {
+	if f.Symbol == "" {
+		return CashFlowIgnore
+	}
+	text := strings.ToLower(f.TransactionFlowName + " " + f.Description)
+	for _, k := range []string{"fee", "commission", "levy", "stamp duty", "charge"} {
+		if strings.Contains(text, k) {
+			return CashFlowFee
+		}
+	}
+	for _, k := range []string{"dividend", "distribution", "interest", "bonus"} {
+		if strings.Contains(text, k) {
+			return CashFlowIncome
+		}
+	}
+	return CashFlowIgnore
+}
+
+// Summary of a symbol in a currency
+type Summary struct {
+	Symbol   string
+	Currency string
+	Realized decimal.Decimal
+	Fees     decimal.Decimal
+	Income   decimal.Decimal
+	// Net is Realized - Fees + Income
+	Net decimal.Decimal
+}
+
+// Option for Engine
+type Option func(*Engine)
+
+// WithLotSelector to set selector of SpecificLot method
+func WithLotSelector(s LotSelector) Option {
+	return func(e *Engine) {
+		e.selector = s
+	}
+}
+
+// WithCashFlowClassifier to set classifier of cash flows, default is DefaultCashFlowClassifier
+func WithCashFlowClassifier(c CashFlowClassifier) Option {
+	return func(e *Engine) {
+		if c != nil {
+			e.classifier = c
+		}
+	}
+}
+
+// Engine matches trades into lots by a Method
+//
+// Example:
+//
+//	executions, err := tctx.HistoryExecutions(ctx, &trade.GetHistoryExecutions{StartAt: start, EndAt: end})
+//	orders, _, err := tctx.HistoryOrders(ctx, &trade.GetHistoryOrders{StartAt: start.Unix(), EndAt: end.Unix()})
+//	flows, err := tctx.CashFlow(ctx, &trade.GetCashFlow{StartAt: start.Unix(), EndAt: end.Unix()})
+//	engine := lots.New(lots.FIFO)
+//	err = engine.AddExecutions(executions, orders)
+//	engine.AddCashFlows(flows)
+//	err = lots.WriteClosedLotsCSV(os.Stdout, engine.ClosedLots())
+type Engine struct {
+	method     Method
+	selector   LotSelector
+	classifier CashFlowClassifier
+	open       map[string][]*Lot
+	closed     []ClosedLot
+	summaries  map[string]*Summary
+	trades     map[string]bool
+}
+
+// New returns an Engine of method
+func New(method Method, opt ...Option) *Engine {
+	e := &Engine{
+		method:     method,
+		classifier: DefaultCashFlowClassifier,
+		open:       make(map[string][]*Lot),
+		summaries:  make(map[string]*Summary),
+		trades:     make(map[string]bool),
+	}
+	for _, o := range opt {
+		o(e)
+	}
+	return e
+}
+
+// AddExecutions applies executions sorted by trade time. Executions have no side and currency,
+// they come from orders of the same OrderId, e.g. from HistoryOrders. Executions already applied are skipped.
+func (e *Engine) AddExecutions(executions []*trade.Execution, orders []*trade.Order) error {
+	byId := make(map[string]*trade.Order, len(orders))
+	for _, o := range orders {
+		byId[o.OrderId] = o
+	}
+	trades := make([]*Trade, 0, len(executions))
+	for _, ex := range executions {
+		o, ok := byId[ex.OrderId]
+		if !ok {
+			return errors.Errorf("no order %s of execution %s", ex.OrderId, ex.TradeId)
+		}
+		qty := ex.Quantity
+		if ex.Price == nil {
+			return errors.Errorf("no price of execution %s", ex.TradeId)
+		}
+		trades = append(trades, &Trade{
+			OrderId:  ex.OrderId,
+			TradeId:  ex.TradeId,
+			Symbol:   ex.Symbol,
+			Side:     o.Side,
+			Currency: o.Currency,
+			Quantity: qty,
+			Price:    *ex.Price,
+			Time:     ex.TradeDoneAt,
+		})
+	}
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].Time.Before(trades[j].Time) })
+	for _, t := range trades {
+		if err := e.Apply(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply applies a trade, trades must be applied in time order. A trade already applied is skipped.
+func (e *Engine) Apply(t *Trade) error {
+	if !t.Quantity.IsPositive() {
+		return errors.Errorf("invalid quantity %s of trade %s", t.Quantity, t.TradeId)
+	}
+	key := t.OrderId + "/" + t.TradeId
+	if e.trades[key] {
+		return nil
+	}
+	qty := t.Quantity
+	switch t.Side {
+	case trade.OrderSideBuy:
+	case trade.OrderSideSell:
+		qty = qty.Neg()
+	default:
+		return errors.Errorf("invalid side %q of trade %s", t.Side, t.TradeId)
+	}
+	e.trades[key] = true
+
+	rest, err := e.close(t, qty)
+	if err != nil {
+		return err
+	}
+	if !rest.IsZero() {
+		e.open[t.Symbol] = append(e.open[t.Symbol], &Lot{
+			Symbol:   t.Symbol,
+			Currency: t.Currency,
+			OrderId:  t.OrderId,
+			TradeId:  t.TradeId,
+			OpenedAt: t.Time,
+			Quantity: rest,
+			Price:    t.Price,
+		})
+		if e.method == Average {
+			e.average(t.Symbol)
+		}
+	}
+	return nil
+}
+
+// close closes open lots of the opposite sign by signed qty and returns the rest quantity
+func (e *Engine) close(t *Trade, qty decimal.Decimal) (decimal.Decimal, error) {
+	lots := e.open[t.Symbol]
+	if len(lots) == 0 || lots[0].Quantity.Sign() == qty.Sign() {
+		return qty, nil
+	}
+
+	var order []*Lot
+	var limits map[*Lot]decimal.Decimal
+	switch e.method {
+	case FIFO, Average:
+		order = lots
+	case LIFO:
+		for i := len(lots) - 1; i >= 0; i-- {
+			order = append(order, lots[i])
+		}
+	case SpecificLot:
+		if e.selector == nil {
+			return qty, errors.New("no lot selector for specific lot method")
+		}
+		open := make([]Lot, 0, len(lots))
+		for _, l := range lots {
+			open = append(open, *l)
+		}
+		limits = make(map[*Lot]decimal.Decimal)
+		for _, m := range e.selector(t, open) {
+			for _, l := range lots {
+				if l.TradeId == m.TradeId {
+					order = append(order, l)
+					limits[l] = m.Quantity.Abs()
+				}
+			}
+		}
+		// the rest falls back to FIFO
+		order = append(order, lots...)
+	default:
+		return qty, errors.Errorf("unknown lot method %q", e.method)
+	}
+
+	for _, l := range order {
+		if qty.IsZero() {
+			break
+		}
+		if l.Quantity.IsZero() {
+			continue
+		}
+		n := decimal.Min(qty.Abs(), l.Quantity.Abs())
+		if limit, ok := limits[l]; ok {
+			n = decimal.Min(n, limit)
+			delete(limits, l)
+		}
+		if !n.IsPositive() {
+			continue
+		}
+		closed := n
+		if l.Quantity.IsNegative() {
+			closed = n.Neg()
+		}
+		gain := t.Price.Sub(l.Price).Mul(closed)
+		e.closed = append(e.closed, ClosedLot{
+			Symbol:       t.Symbol,
+			Currency:     l.Currency,
+			OpenTradeId:  l.TradeId,
+			OpenedAt:     l.OpenedAt,
+			OpenPrice:    l.Price,
+			CloseTradeId: t.TradeId,
+			ClosedAt:     t.Time,
+			ClosePrice:   t.Price,
+			Quantity:     closed,
+			Gain:         gain,
+		})
+		s := e.summary(t.Symbol, l.Currency)
+		s.Realized = s.Realized.Add(gain)
+		l.Quantity = l.Quantity.Sub(closed)
+		qty = qty.Add(closed)
+	}
+
+	kept := lots[:0]
+	for _, l := range lots {
+		if !l.Quantity.IsZero() {
+			kept = append(kept, l)
+		}
+	}
+	e.open[t.Symbol] = kept
+	return qty, nil
+}
+
+// average sets price of all open lots of symbol to the average cost
+func (e *Engine) average(symbol string) {
+	lots := e.open[symbol]
+	qty, cost := decimal.Zero, decimal.Zero
+	for _, l := range lots {
+		qty = qty.Add(l.Quantity)
+		cost = cost.Add(l.Cost())
+	}
+	if qty.IsZero() {
+		return
+	}
+	avg := cost.Div(qty)
+	for _, l := range lots {
+		l.Price = avg
+	}
+}
+
+func (e *Engine) summary(symbol, currency string) *Summary {
+	key := currency + "/" + symbol
+	s, ok := e.summaries[key]
+	if !ok {
+		s = &Summary{Symbol: symbol, Currency: currency}
+		e.summaries[key] = s
+	}
+	return s
+}
+
+// AddCashFlows adds fees and corporate cash of symbols classified by the CashFlowClassifier
+func (e *Engine) AddCashFlows(flows []*trade.CashFlow) {
+	for _, f := range flows {
+		if f.Balance == nil {
+			continue
+		}
+		amount := f.Balance.Abs()
+		switch e.classifier(f) {
+		case CashFlowFee:
+			s := e.summary(f.Symbol, f.Currency)
+			if f.Direction == trade.OfDirectionIn {
+				// refunded fees
+				amount = amount.Neg()
+			}
+			s.Fees = s.Fees.Add(amount)
+		case CashFlowIncome:
+			s := e.summary(f.Symbol, f.Currency)
+			if f.Direction == trade.OfDirectionOut {
+				amount = amount.Neg()
+			}
+			s.Income = s.Income.Add(amount)
+		}
+	}
+}
+
+// OpenLots returns open lots sorted by symbol and open time
+func (e *Engine) OpenLots() []Lot {
+	var list []Lot
+	for _, lots := range e.open {
+		for _, l := range lots {
+			list = append(list, *l)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Symbol != list[j].Symbol {
+			return list[i].Symbol < list[j].Symbol
+		}
+		return list[i].OpenedAt.Before(list[j].OpenedAt)
+	})
+	return list
+}
+
+// ClosedLots returns closed lots in close order
+func (e *Engine) ClosedLots() []ClosedLot {
+	return append([]ClosedLot(nil), e.closed...)
+}
+
+// Summaries returns summaries sorted by currency and symbol
+func (e *Engine) Summaries() []Summary {
+	list := make([]Summary, 0, len(e.summaries))
+	for _, s := range e.summaries {
+		v := *s
+		v.Net = v.Realized.Sub(v.Fees).Add(v.Income)
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Currency != list[j].Currency {
+			return list[i].Currency < list[j].Currency
+		}
+		return list[i].Symbol < list[j].Symbol
+	})
+	return list
+}
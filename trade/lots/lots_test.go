@@ -0,0 +1,96 @@
+package lots_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+	"github.com/longportapp/openapi-go/trade/lots"
+)
+
+func dec(s string) *decimal.Decimal {
+	d := decimal.RequireFromString(s)
+	return &d
+}
+
+func history() ([]*trade.Execution, []*trade.Order) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	executions := []*trade.Execution{
+		{OrderId: "3", TradeId: "t3", Symbol: "700.HK", Quantity: *dec("150"), Price: dec("330"), TradeDoneAt: day.AddDate(0, 0, 2)},
+		{OrderId: "1", TradeId: "t1", Symbol: "700.HK", Quantity: *dec("100"), Price: dec("300"), TradeDoneAt: day},
+		{OrderId: "2", TradeId: "t2", Symbol: "700.HK", Quantity: *dec("100"), Price: dec("320"), TradeDoneAt: day.AddDate(0, 0, 1)},
+	}
+	orders := []*trade.Order{
+		{OrderId: "1", Side: trade.OrderSideBuy, Currency: "HKD"},
+		{OrderId: "2", Side: trade.OrderSideBuy, Currency: "HKD"},
+		{OrderId: "3", Side: trade.OrderSideSell, Currency: "HKD"},
+	}
+	return executions, orders
+}
+
+func realized(t *testing.T, e *lots.Engine) string {
+	sums := e.Summaries()
+	assert.Equal(t, 1, len(sums))
+	return sums[0].Realized.String()
+}
+
+func TestMethods(t *testing.T) {
+	executions, orders := history()
+
+	fifo := lots.New(lots.FIFO)
+	assert.NoError(t, fifo.AddExecutions(executions, orders))
+	// 100 * 30 + 50 * 10
+	assert.Equal(t, "3500", realized(t, fifo))
+	open := fifo.OpenLots()
+	assert.Equal(t, 1, len(open))
+	assert.Equal(t, "t2", open[0].TradeId)
+	assert.Equal(t, "50", open[0].Quantity.String())
+
+	lifo := lots.New(lots.LIFO)
+	assert.NoError(t, lifo.AddExecutions(executions, orders))
+	// 100 * 10 + 50 * 30
+	assert.Equal(t, "2500", realized(t, lifo))
+
+	avg := lots.New(lots.Average)
+	assert.NoError(t, avg.AddExecutions(executions, orders))
+	// 150 * (330 - 310)
+	assert.Equal(t, "3000", realized(t, avg))
+
+	specific := lots.New(lots.SpecificLot, lots.WithLotSelector(func(tr *lots.Trade, open []lots.Lot) []lots.LotMatch {
+		return []lots.LotMatch{{TradeId: "t2", Quantity: decimal.NewFromInt(100)}}
+	}))
+	assert.NoError(t, specific.AddExecutions(executions, orders))
+	// 100 * 10 from t2 + 50 * 30 from t1
+	assert.Equal(t, "2500", realized(t, specific))
+	assert.Equal(t, "t1", specific.OpenLots()[0].TradeId)
+
+	// applied executions are skipped
+	assert.NoError(t, fifo.AddExecutions(executions, orders))
+	assert.Equal(t, "3500", realized(t, fifo))
+}
+
+func TestCashFlowsAndCSV(t *testing.T) {
+	executions, orders := history()
+	e := lots.New(lots.FIFO)
+	assert.NoError(t, e.AddExecutions(executions, orders))
+	e.AddCashFlows([]*trade.CashFlow{
+		{TransactionFlowName: "Trading Fee", Symbol: "700.HK", Currency: "HKD", Direction: trade.OfDirectionOut, Balance: dec("-50")},
+		{TransactionFlowName: "Cash Dividend", Symbol: "700.HK", Currency: "HKD", Direction: trade.OfDirectionIn, Balance: dec("240")},
+		{TransactionFlowName: "Deposit", Currency: "HKD", Direction: trade.OfDirectionIn, Balance: dec("10000")},
+	})
+	sums := e.Summaries()
+	assert.Equal(t, "50", sums[0].Fees.String())
+	assert.Equal(t, "240", sums[0].Income.String())
+	assert.Equal(t, "3690", sums[0].Net.String())
+
+	var buf bytes.Buffer
+	assert.NoError(t, lots.WriteClosedLotsCSV(&buf, e.ClosedLots()))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, "700.HK,HKD,t1,2024-01-01T00:00:00Z,300,t3,2024-01-03T00:00:00Z,330,100,3000", lines[1])
+}
@@ -1,6 +1,10 @@
 package trade
 
 import (
+	"time"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/config"
 	"github.com/longportapp/openapi-go/http"
 	"github.com/longportapp/openapi-go/log"
 	"github.com/longportapp/openapi-go/longbridge"
@@ -19,6 +23,13 @@ type Options struct {
 	logLevel           string
 	logger             log.Logger
 	reconnectCallbacks []func(resubFlag bool)
+	riskConfig         *config.RiskConfig
+	priceSource        PriceSource
+	feeSchedules       map[openapi.Market]*FeeSchedule
+	httpMiddlewares    []http.Middleware
+	// client order ids remembered by SubmitOrder
+	clientOrderCacheSize int
+	clientOrderCacheTTL  time.Duration
 }
 
 // Option
@@ -76,14 +87,71 @@ func OnReconnect(fn func(successResub bool)) Option {
 	}
 }
 
+// WithRiskConfig to set pre-trade risk controls for trade context
+func WithRiskConfig(cfg *config.RiskConfig) Option {
+	return func(o *Options) {
+		if cfg != nil {
+			o.riskConfig = cfg
+		}
+	}
+}
+
+// WithPriceSource to set last done price source used by risk controls, e.g. *quote.QuoteContext
+func WithPriceSource(src PriceSource) Option {
+	return func(o *Options) {
+		if src != nil {
+			o.priceSource = src
+		}
+	}
+}
+
+// WithFeeSchedule to set the fee schedule of market used by EstimateCharges, see DefaultFeeSchedules
+func WithFeeSchedule(market openapi.Market, schedule *FeeSchedule) Option {
+	return func(o *Options) {
+		if schedule == nil {
+			return
+		}
+		if o.feeSchedules == nil {
+			o.feeSchedules = make(map[openapi.Market]*FeeSchedule)
+		}
+		o.feeSchedules[market] = schedule
+	}
+}
+
+// WithHttpMiddleware to add middlewares of the http client of trade context, see http.Middleware
+func WithHttpMiddleware(m ...http.Middleware) Option {
+	return func(o *Options) {
+		o.httpMiddlewares = append(o.httpMiddlewares, m...)
+	}
+}
+
+// WithClientOrderCache to set how many ClientOrderIds of SubmitOrder are remembered and for how long,
+// default is DefaultClientOrderCacheSize and DefaultClientOrderCacheTTL. ClientOrderIds which are not
+// remembered are looked up in today's orders.
+func WithClientOrderCache(size int, ttl time.Duration) Option {
+	return func(o *Options) {
+		if size > 0 {
+			o.clientOrderCacheSize = size
+		}
+		if ttl > 0 {
+			o.clientOrderCacheTTL = ttl
+		}
+	}
+}
+
 func newOptions(opt ...Option) *Options {
 	opts := Options{
-		tradeURL: DefaultTradeUrl,
-		lbOpts:   longbridge.NewOptions(),
-		logger:   &protocol.DefaultLogger{},
+		tradeURL:             DefaultTradeUrl,
+		lbOpts:               longbridge.NewOptions(),
+		logger:               &protocol.DefaultLogger{},
+		clientOrderCacheSize: DefaultClientOrderCacheSize,
+		clientOrderCacheTTL:  DefaultClientOrderCacheTTL,
 	}
 	for _, o := range opt {
 		o(&opts)
 	}
+	if opts.httpClient != nil && len(opts.httpMiddlewares) > 0 {
+		opts.httpClient = opts.httpClient.Wrap(opts.httpMiddlewares...)
+	}
 	return &opts
 }
@@ -0,0 +1,796 @@
+// Package paper provides a simulated broker with the API of trade.TradeContext, orders are filled against live quotes
+package paper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/quote"
+	"github.com/longportapp/openapi-go/trade"
+)
+
+var (
+	// ErrOrderNotFound is returned when the order id is unknown
+	ErrOrderNotFound = errors.New("paper: order not found")
+	// ErrOrderClosed is returned when replacing or canceling an order which is not open
+	ErrOrderClosed = errors.New("paper: order is not open")
+	// ErrInsufficientCash is returned when buying more than the available cash without margin
+	ErrInsufficientCash = errors.New("paper: insufficient cash")
+	// ErrInsufficientPosition is returned when selling more than the available position without short selling
+	ErrInsufficientPosition = errors.New("paper: insufficient position")
+	// ErrUnsupportedOrderType is returned for order types the broker can not simulate
+	ErrUnsupportedOrderType = errors.New("paper: unsupported order type")
+)
+
+type order struct {
+	id            string
+	req           trade.SubmitOrder
+	status        trade.OrderStatus
+	currency      string
+	executedQty   uint64
+	executedPrice decimal.Decimal // average
+	lastShare     uint64
+	lastPrice     decimal.Decimal
+	fees          decimal.Decimal
+	msg           string
+	triggered     bool
+	submittedAt   time.Time
+	updatedAt     time.Time
+	triggerAt     time.Time
+	// acked is false until the order is accepted after latency
+	acked bool
+}
+
+func (o *order) remaining() uint64 {
+	return o.req.SubmittedQuantity - o.executedQty
+}
+
+func decPtr(d decimal.Decimal) *decimal.Decimal {
+	if d.IsZero() {
+		return nil
+	}
+	return &d
+}
+
+func (o *order) toOrder() *trade.Order {
+	return &trade.Order{
+		OrderId:          o.id,
+		Status:           o.status,
+		Quantity:         decimal.NewFromInt(int64(o.req.SubmittedQuantity)),
+		ExecutedQuantity: decimal.NewFromInt(int64(o.executedQty)),
+		Price:            decPtr(o.req.SubmittedPrice),
+		ExecutedPrice:    decPtr(o.executedPrice),
+		SubmittedAt:      o.submittedAt,
+		Side:             o.req.Side,
+		Symbol:           o.req.Symbol,
+		OrderType:        o.req.OrderType,
+		TriggerPrice:     decPtr(o.req.TriggerPrice),
+		Msg:              o.msg,
+		Tag:              "Normal",
+		TimeInForce:      o.req.TimeInForce,
+		UpdatedAt:        o.updatedAt,
+		TriggerAt:        o.triggerAt,
+		Currency:         o.currency,
+		OutsideRth:       o.req.OutsideRTH,
+		Remark:           o.req.Remark,
+	}
+}
+
+func (o *order) toDetail() trade.OrderDetail {
+	return trade.OrderDetail{
+		OrderId:          o.id,
+		Status:           o.status,
+		Quantity:         decimal.NewFromInt(int64(o.req.SubmittedQuantity)),
+		ExecutedQuantity: decimal.NewFromInt(int64(o.executedQty)),
+		Price:            decPtr(o.req.SubmittedPrice),
+		ExecutedPrice:    decPtr(o.executedPrice),
+		SubmittedAt:      o.submittedAt,
+		Side:             o.req.Side,
+		Symbol:           o.req.Symbol,
+		OrderType:        o.req.OrderType,
+		TriggerPrice:     decPtr(o.req.TriggerPrice),
+		Msg:              o.msg,
+		Tag:              "Normal",
+		TimeInForce:      o.req.TimeInForce,
+		UpdatedAt:        o.updatedAt,
+		TriggerAt:        o.triggerAt,
+		Currency:         o.currency,
+		OutsideRth:       o.req.OutsideRTH,
+		Remark:           o.req.Remark,
+	}
+}
+
+func (o *order) toPush(accountNo string) *trade.PushEvent {
+	qty := decimal.NewFromInt(int64(o.req.SubmittedQuantity))
+	executed := decimal.NewFromInt(int64(o.executedQty))
+	lastShare := decimal.NewFromInt(int64(o.lastShare))
+	executedPrice := o.executedPrice
+	return &trade.PushEvent{
+		Event: trade.EventOrderChanged,
+		Data: &trade.PushOrderChanged{
+			AccountNo:        accountNo,
+			Currency:         o.currency,
+			ExecutedPrice:    &executedPrice,
+			ExecutedQuantity: &executed,
+			LastPrice:        decPtr(o.lastPrice),
+			LastShare:        &lastShare,
+			Msg:              o.msg,
+			OrderId:          o.id,
+			OrderType:        o.req.OrderType,
+			Side:             o.req.Side,
+			Status:           o.status,
+			SubmittedAt:      o.submittedAt,
+			Price:            decPtr(o.req.SubmittedPrice),
+			Quantity:         &qty,
+			Symbol:           o.req.Symbol,
+			Tag:              "Normal",
+			TriggerAt:        o.triggerAt,
+			TriggerPrice:     decPtr(o.req.TriggerPrice),
+			UpdatedAt:        o.updatedAt,
+			Remark:           o.req.Remark,
+		},
+	}
+}
+
+type position struct {
+	quantity int64
+	cost     decimal.Decimal // average cost price
+	currency string
+}
+
+type handler struct {
+	f func(*trade.PushEvent)
+}
+
+// Broker is a simulated broker. Orders are accepted after the latency of WithLatency and filled
+// against the last done price of quotes passed to ApplyQuote, each quote fills the part of WithFillRatio,
+// with the slippage of WithSlippage and the fees of WithFees. LIT and MIT orders are triggered when the last done
+// price touches the trigger price. Trailing orders are not supported, SubmitOrder returns ErrUnsupportedOrderType.
+//
+// Example:
+//
+//	broker := paper.New(paper.WithCash("HKD", 1000000), paper.WithSlippage(5), paper.WithLatency(200*time.Millisecond))
+//	qctx.OnQuote(broker.ApplyQuote)
+//	_, err = qctx.Subscribe(ctx, []string{"700.HK"}, []quote.SubType{quote.SubTypeQuote}, true)
+//	broker.OnTrade(func(ev *trade.PushEvent) { log.Println(ev.Data.Status) })
+//	orderId, err := broker.SubmitOrder(ctx, &trade.SubmitOrder{
+//		Symbol:            "700.HK",
+//		OrderType:         trade.OrderTypeLO,
+//		Side:              trade.OrderSideBuy,
+//		SubmittedQuantity: 100,
+//		SubmittedPrice:    decimal.NewFromFloat(320),
+//		TimeInForce:       trade.TimeTypeDay,
+//	})
+type Broker struct {
+	opts       *Options
+	mu         sync.Mutex
+	seq        int64
+	orders     map[string]*order
+	orderList  []*order
+	positions  map[string]*position
+	cash       map[string]decimal.Decimal
+	last       map[string]decimal.Decimal
+	executions []*trade.Execution
+	handlersMu sync.RWMutex
+	handler    *handler   // set by OnTrade
+	handlers   []*handler // added by AddTradeHandler
+}
+
+// New returns a Broker
+func New(opt ...Option) *Broker {
+	opts := newOptions(opt...)
+	b := &Broker{
+		opts:      opts,
+		orders:    make(map[string]*order),
+		positions: make(map[string]*position),
+		cash:      make(map[string]decimal.Decimal),
+		last:      make(map[string]decimal.Decimal),
+	}
+	for c, v := range opts.cash {
+		b.cash[c] = v
+	}
+	return b
+}
+
+// symbolCurrency returns the trading currency of symbol by its market suffix
+func symbolCurrency(symbol string) string {
+	switch trade.SymbolMarket(symbol) {
+	case openapi.MarketHK:
+		return "HKD"
+	case openapi.MarketUS:
+		return "USD"
+	case "SH", "SZ":
+		return "CNY"
+	case openapi.MarketSG:
+		return "SGD"
+	}
+	return ""
+}
+
+func isMarketOrder(t trade.OrderType) bool {
+	return t == trade.OrderTypeMO || t == trade.OrderTypeAO || t == trade.OrderTypeMIT
+}
+
+func supported(t trade.OrderType) bool {
+	switch t {
+	case trade.OrderTypeLO, trade.OrderTypeELO, trade.OrderTypeMO, trade.OrderTypeAO, trade.OrderTypeALO,
+		trade.OrderTypeODD, trade.OrderTypeLIT, trade.OrderTypeMIT, trade.OrderTypeSLO:
+		return true
+	}
+	return false
+}
+
+// OnTrade set callback function which will be called when an order changes, it replaces the callback set before
+func (b *Broker) OnTrade(f func(*trade.PushEvent)) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+	if f == nil {
+		b.handler = nil
+		return
+	}
+	b.handler = &handler{f: f}
+}
+
+// AddTradeHandler adds a callback function which will be called when an order changes, after the callback of OnTrade.
+// The returned function removes the callback.
+func (b *Broker) AddTradeHandler(f func(*trade.PushEvent)) (remove func()) {
+	h := &handler{f: f}
+	b.handlersMu.Lock()
+	b.handlers = append(b.handlers, h)
+	b.handlersMu.Unlock()
+	return func() {
+		b.handlersMu.Lock()
+		defer b.handlersMu.Unlock()
+		for i, v := range b.handlers {
+			if v == h {
+				b.handlers = append(b.handlers[:i:i], b.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (b *Broker) emit(events []*trade.PushEvent) {
+	if len(events) == 0 {
+		return
+	}
+	b.handlersMu.RLock()
+	handlers := b.handlers
+	if b.handler != nil {
+		handlers = append([]*handler{b.handler}, handlers...)
+	}
+	b.handlersMu.RUnlock()
+	for _, ev := range events {
+		for _, h := range handlers {
+			h.f(ev)
+		}
+	}
+}
+
+// slip returns price worse by slippage for side
+func (b *Broker) slip(price decimal.Decimal, side trade.OrderSide) decimal.Decimal {
+	if b.opts.slippageBps.IsZero() {
+		return price
+	}
+	f := b.opts.slippageBps.Div(decimal.NewFromInt(10000))
+	if side == trade.OrderSideSell {
+		f = f.Neg()
+	}
+	return price.Mul(decimal.NewFromInt(1).Add(f)).Round(b.opts.priceRound)
+}
+
+// SubmitOrder submits a simulated order, it is accepted after latency and filled by following quotes
+func (b *Broker) SubmitOrder(ctx context.Context, params *trade.SubmitOrder) (orderId string, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+	if !supported(params.OrderType) {
+		return "", errors.Wrapf(ErrUnsupportedOrderType, "%s", params.OrderType)
+	}
+	b.mu.Lock()
+	if err = b.checkFunds(params, params.SubmittedQuantity, nil); err != nil {
+		b.mu.Unlock()
+		return
+	}
+	b.seq++
+	now := b.opts.nowFunc()
+	o := &order{
+		id:          fmt.Sprintf("P%d%06d", now.Unix(), b.seq),
+		req:         *params,
+		status:      trade.OrderNotReported,
+		currency:    symbolCurrency(params.Symbol),
+		submittedAt: now,
+		updatedAt:   now,
+	}
+	if o.req.TimeInForce == "" {
+		o.req.TimeInForce = trade.TimeTypeDay
+	}
+	b.orders[o.id] = o
+	b.orderList = append(b.orderList, o)
+	b.mu.Unlock()
+
+	b.opts.afterFunc(b.opts.latency, func() { b.ack(o) })
+	return o.id, nil
+}
+
+func (b *Broker) ack(o *order) {
+	b.mu.Lock()
+	var events []*trade.PushEvent
+	if o.status == trade.OrderNotReported {
+		o.acked = true
+		o.status = trade.OrderNewStatus
+		o.updatedAt = b.opts.nowFunc()
+		events = append(events, o.toPush(b.opts.accountNo))
+		if last, ok := b.last[o.req.Symbol]; ok {
+			events = append(events, b.match(o, last)...)
+		}
+	}
+	b.mu.Unlock()
+	b.emit(events)
+}
+
+// checkFunds checks the cash of a buy order or the position of a sell order of qty, the cash or position held
+// by replaced is available to it. Must be called with lock.
+func (b *Broker) checkFunds(req *trade.SubmitOrder, qty uint64, replaced *order) error {
+	if req.Side == trade.OrderSideBuy {
+		if b.opts.allowMargin {
+			return nil
+		}
+		price := req.SubmittedPrice
+		if isMarketOrder(req.OrderType) || price.IsZero() {
+			price = b.slip(b.last[req.Symbol], req.Side)
+		}
+		available := b.availableCash(symbolCurrency(req.Symbol))
+		if replaced != nil {
+			available = available.Add(b.frozenCash(replaced))
+		}
+		if price.Mul(decimal.NewFromInt(int64(qty))).GreaterThan(available) {
+			return ErrInsufficientCash
+		}
+		return nil
+	}
+	if b.opts.allowShort {
+		return nil
+	}
+	available := b.availablePosition(req.Symbol)
+	if replaced != nil {
+		available += int64(replaced.remaining())
+	}
+	if int64(qty) > available {
+		return ErrInsufficientPosition
+	}
+	return nil
+}
+
+// frozenCash returns the cash frozen by an open buy order, must be called with lock
+func (b *Broker) frozenCash(o *order) decimal.Decimal {
+	price := o.req.SubmittedPrice
+	if price.IsZero() {
+		price = b.last[o.req.Symbol]
+	}
+	return price.Mul(decimal.NewFromInt(int64(o.remaining())))
+}
+
+// availableCash returns cash minus cash frozen by open buy orders, must be called with lock
+func (b *Broker) availableCash(currency string) decimal.Decimal {
+	cash := b.cash[currency]
+	for _, o := range b.orderList {
+		if o.status.IsOpen() && o.req.Side == trade.OrderSideBuy && o.currency == currency {
+			cash = cash.Sub(b.frozenCash(o))
+		}
+	}
+	return cash
+}
+
+// availablePosition returns position minus quantity of open sell orders, must be called with lock
+func (b *Broker) availablePosition(symbol string) int64 {
+	var qty int64
+	if p, ok := b.positions[symbol]; ok {
+		qty = p.quantity
+	}
+	for _, o := range b.orderList {
+		if o.status.IsOpen() && o.req.Side == trade.OrderSideSell && o.req.Symbol == symbol {
+			qty -= int64(o.remaining())
+		}
+	}
+	return qty
+}
+
+// ReplaceOrder replaces quantity and prices of an open order after latency. The cash or position is checked
+// like SubmitOrder for the remaining quantity at the new price.
+func (b *Broker) ReplaceOrder(ctx context.Context, params *trade.ReplaceOrder) (err error) {
+	b.mu.Lock()
+	o, ok := b.orders[params.OrderId]
+	if !ok {
+		b.mu.Unlock()
+		return ErrOrderNotFound
+	}
+	if !o.status.IsOpen() || o.status.IsPendingCancel() {
+		b.mu.Unlock()
+		return ErrOrderClosed
+	}
+	if err = params.ValidateFor(o.req.OrderType); err != nil {
+		b.mu.Unlock()
+		return
+	}
+	if params.Quantity > o.executedQty {
+		replaced := o.req
+		if !params.Price.IsZero() {
+			replaced.SubmittedPrice = params.Price
+		}
+		if err = b.checkFunds(&replaced, params.Quantity-o.executedQty, o); err != nil {
+			b.mu.Unlock()
+			return
+		}
+	}
+	b.mu.Unlock()
+	// the caller may reuse params after return
+	req := *params
+	b.opts.afterFunc(b.opts.latency, func() {
+		b.mu.Lock()
+		var events []*trade.PushEvent
+		if o.status.IsOpen() && !o.status.IsPendingCancel() {
+			if req.Quantity <= o.executedQty {
+				o.msg = "replace quantity is not more than executed quantity"
+			} else {
+				o.req.SubmittedQuantity = req.Quantity
+				if !req.Price.IsZero() {
+					o.req.SubmittedPrice = req.Price
+				}
+				if !req.TriggerPrice.IsZero() {
+					o.req.TriggerPrice = req.TriggerPrice
+				}
+				o.req.Remark = req.Remark
+				o.status = trade.OrderReplacedStatus
+				o.msg = ""
+			}
+			o.updatedAt = b.opts.nowFunc()
+			events = append(events, o.toPush(b.opts.accountNo))
+			if last, ok := b.last[o.req.Symbol]; ok && o.acked {
+				events = append(events, b.match(o, last)...)
+			}
+		}
+		b.mu.Unlock()
+		b.emit(events)
+	})
+	return
+}
+
+// CancelOrder cancels an open order after latency
+func (b *Broker) CancelOrder(ctx context.Context, orderId string) (err error) {
+	b.mu.Lock()
+	o, ok := b.orders[orderId]
+	if !ok {
+		b.mu.Unlock()
+		return ErrOrderNotFound
+	}
+	if !o.status.IsOpen() {
+		b.mu.Unlock()
+		return ErrOrderClosed
+	}
+	o.status = trade.OrderPendingCancelStatus
+	o.updatedAt = b.opts.nowFunc()
+	events := []*trade.PushEvent{o.toPush(b.opts.accountNo)}
+	b.mu.Unlock()
+	b.emit(events)
+
+	b.opts.afterFunc(b.opts.latency, func() {
+		b.mu.Lock()
+		var events []*trade.PushEvent
+		if o.status == trade.OrderPendingCancelStatus {
+			o.status = trade.OrderCanceledStatus
+			o.updatedAt = b.opts.nowFunc()
+			events = append(events, o.toPush(b.opts.accountNo))
+		}
+		b.mu.Unlock()
+		b.emit(events)
+	})
+	return
+}
+
+// ApplyQuote fills open orders of the symbol by the last done price, use it as the handler of QuoteContext.OnQuote
+func (b *Broker) ApplyQuote(q *quote.PushQuote) {
+	if q == nil || q.LastDone == nil || !q.LastDone.IsPositive() {
+		return
+	}
+	b.SetPrice(q.Symbol, *q.LastDone)
+}
+
+// SetPrice fills open orders of the symbol by the last done price
+func (b *Broker) SetPrice(symbol string, last decimal.Decimal) {
+	b.mu.Lock()
+	b.last[symbol] = last
+	var events []*trade.PushEvent
+	for _, o := range b.orderList {
+		if o.req.Symbol == symbol && o.acked && o.status.IsOpen() && !o.status.IsPendingCancel() {
+			events = append(events, b.match(o, last)...)
+		}
+	}
+	b.mu.Unlock()
+	b.emit(events)
+}
+
+// match fills the order by fill ratio if the last done price reaches it, must be called with lock
+func (b *Broker) match(o *order, last decimal.Decimal) []*trade.PushEvent {
+	side := o.req.Side
+	buy := side == trade.OrderSideBuy
+	now := b.opts.nowFunc()
+
+	if (o.req.OrderType == trade.OrderTypeLIT || o.req.OrderType == trade.OrderTypeMIT) && !o.triggered {
+		trigger := o.req.TriggerPrice
+		if (buy && last.GreaterThan(trigger)) || (!buy && last.LessThan(trigger)) {
+			return nil
+		}
+		o.triggered = true
+		o.triggerAt = now
+	}
+
+	price := b.slip(last, side)
+	if !isMarketOrder(o.req.OrderType) {
+		limit := o.req.SubmittedPrice
+		if (buy && last.GreaterThan(limit)) || (!buy && last.LessThan(limit)) {
+			return nil
+		}
+		if buy {
+			price = decimal.Min(price, limit)
+		} else {
+			price = decimal.Max(price, limit)
+		}
+	}
+
+	qty := b.fillQuantity(o)
+	amount := price.Mul(decimal.NewFromInt(int64(qty)))
+	fee := decimal.Zero
+	if b.opts.fee != nil {
+		fee = b.opts.fee(o.req.Symbol, side, qty, price)
+	}
+	if buy && !b.opts.allowMargin && amount.Add(fee).GreaterThan(b.cash[o.currency]) {
+		o.status = trade.OrderRejectedStatus
+		o.msg = ErrInsufficientCash.Error()
+		o.updatedAt = now
+		return []*trade.PushEvent{o.toPush(b.opts.accountNo)}
+	}
+
+	total := o.executedPrice.Mul(decimal.NewFromInt(int64(o.executedQty))).Add(amount)
+	o.executedQty += qty
+	o.executedPrice = total.Div(decimal.NewFromInt(int64(o.executedQty))).Round(b.opts.priceRound)
+	o.lastShare = qty
+	o.lastPrice = price
+	o.fees = o.fees.Add(fee)
+	o.status = trade.OrderFilledStatus
+	if o.remaining() > 0 {
+		o.status = trade.OrderPartialFilledStatus
+	}
+	o.updatedAt = now
+
+	if buy {
+		b.cash[o.currency] = b.cash[o.currency].Sub(amount).Sub(fee)
+	} else {
+		b.cash[o.currency] = b.cash[o.currency].Add(amount).Sub(fee)
+	}
+	b.applyPosition(o, int64(qty), price)
+	b.executions = append(b.executions, &trade.Execution{
+		OrderId:     o.id,
+		TradeId:     fmt.Sprintf("%s-%d", o.id, len(b.executions)+1),
+		Symbol:      o.req.Symbol,
+		TradeDoneAt: now,
+		Quantity:    decimal.NewFromInt(int64(qty)),
+		Price:       &price,
+	})
+	return []*trade.PushEvent{o.toPush(b.opts.accountNo)}
+}
+
+// fillQuantity returns the quantity filled by one matching quote, at least 1 and at most the remaining quantity
+func (b *Broker) fillQuantity(o *order) uint64 {
+	qty := uint64(b.opts.fillRatio.Mul(decimal.NewFromInt(int64(o.req.SubmittedQuantity))).IntPart())
+	if qty == 0 {
+		qty = 1
+	}
+	if remaining := o.remaining(); qty > remaining {
+		qty = remaining
+	}
+	return qty
+}
+
+// applyPosition updates the position with average cost, must be called with lock
+func (b *Broker) applyPosition(o *order, qty int64, price decimal.Decimal) {
+	p, ok := b.positions[o.req.Symbol]
+	if !ok {
+		p = &position{currency: o.currency}
+		b.positions[o.req.Symbol] = p
+	}
+	if o.req.Side == trade.OrderSideSell {
+		qty = -qty
+	}
+	switch total := p.quantity + qty; {
+	case total == 0:
+		p.cost = decimal.Zero
+	case p.quantity == 0 || (p.quantity > 0) == (qty > 0):
+		p.cost = p.cost.Mul(decimal.NewFromInt(p.quantity)).Add(price.Mul(decimal.NewFromInt(qty))).
+			Div(decimal.NewFromInt(total)).Round(b.opts.priceRound)
+	case (p.quantity > 0) != (total > 0):
+		// reversed
+		p.cost = price
+	}
+	p.quantity += qty
+}
+
+// EndOfDay expires open Day orders
+func (b *Broker) EndOfDay() {
+	b.mu.Lock()
+	var events []*trade.PushEvent
+	now := b.opts.nowFunc()
+	for _, o := range b.orderList {
+		if o.status.IsOpen() && o.req.TimeInForce == trade.TimeTypeDay {
+			o.status = trade.OrderExpiredStatus
+			o.updatedAt = now
+			events = append(events, o.toPush(b.opts.accountNo))
+		}
+	}
+	b.mu.Unlock()
+	b.emit(events)
+}
+
+// TodayOrders returns orders of the broker
+func (b *Broker) TodayOrders(ctx context.Context, params *trade.GetTodayOrders) (orders []*trade.Order, err error) {
+	if params == nil {
+		params = &trade.GetTodayOrders{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, o := range b.orderList {
+		if params.Symbol != "" && o.req.Symbol != params.Symbol {
+			continue
+		}
+		if params.Side != "" && o.req.Side != params.Side {
+			continue
+		}
+		if params.Market != "" && trade.SymbolMarket(o.req.Symbol) != params.Market {
+			continue
+		}
+		if len(params.Status) > 0 && !containsStatus(params.Status, o.status) {
+			continue
+		}
+		orders = append(orders, o.toOrder())
+	}
+	return
+}
+
+func containsStatus(list []trade.OrderStatus, s trade.OrderStatus) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderDetail returns detail of an order
+func (b *Broker) OrderDetail(ctx context.Context, orderId string) (orderDetail trade.OrderDetail, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.orders[orderId]
+	if !ok {
+		err = ErrOrderNotFound
+		return
+	}
+	return o.toDetail(), nil
+}
+
+// TodayExecutions returns executions of the broker
+func (b *Broker) TodayExecutions(ctx context.Context, params *trade.GetTodayExecutions) (trades []*trade.Execution, err error) {
+	if params == nil {
+		params = &trade.GetTodayExecutions{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range b.executions {
+		if (params.Symbol == "" || e.Symbol == params.Symbol) && (params.OrderId == "" || e.OrderId == params.OrderId) {
+			v := *e
+			trades = append(trades, &v)
+		}
+	}
+	return
+}
+
+// StockPositions returns positions of the broker in one account channel, empty symbols means all
+func (b *Broker) StockPositions(ctx context.Context, symbols []string) (stockPositionChannels []*trade.StockPositionChannel, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := &trade.StockPositionChannel{AccountChannel: b.opts.channelName}
+	keys := make([]string, 0, len(b.positions))
+	for s := range b.positions {
+		keys = append(keys, s)
+	}
+	sort.Strings(keys)
+	for _, s := range keys {
+		p := b.positions[s]
+		if p.quantity == 0 || (len(symbols) > 0 && !contains(symbols, s)) {
+			continue
+		}
+		available := b.availablePosition(s)
+		if available < 0 {
+			available = 0
+		}
+		cost := p.cost
+		ch.Positions = append(ch.Positions, &trade.StockPosition{
+			Symbol:            s,
+			Quantity:          decimal.NewFromInt(p.quantity),
+			AvailableQuantity: decimal.NewFromInt(available),
+			Currency:          p.currency,
+			CostPrice:         &cost,
+			Market:            trade.SymbolMarket(s),
+		})
+	}
+	return []*trade.StockPositionChannel{ch}, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountBalance returns cash of every currency, positions are valued by the last done prices in their own currency
+func (b *Broker) AccountBalance(ctx context.Context, params *trade.GetAccountBalance) (accounts []*trade.AccountBalance, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	set := make(map[string]bool)
+	for c := range b.cash {
+		set[c] = true
+	}
+	for _, p := range b.positions {
+		set[p.currency] = true
+	}
+	currencies := make([]string, 0, len(set))
+	for c := range set {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+
+	infos := make([]*trade.CashInfo, 0, len(currencies))
+	for _, c := range currencies {
+		cash, available := b.cash[c], b.availableCash(c)
+		frozen := cash.Sub(available)
+		infos = append(infos, &trade.CashInfo{
+			WithdrawCash:  &available,
+			AvailableCash: &available,
+			FrozenCash:    &frozen,
+			SettlingCash:  decPtr(decimal.Zero),
+			Currency:      c,
+		})
+	}
+	for _, c := range currencies {
+		if params != nil && params.Currency != "" && string(params.Currency) != c {
+			continue
+		}
+		cash := b.cash[c]
+		net := cash
+		for s, p := range b.positions {
+			if p.currency == c {
+				price, ok := b.last[s]
+				if !ok {
+					price = p.cost
+				}
+				net = net.Add(price.Mul(decimal.NewFromInt(p.quantity)))
+			}
+		}
+		accounts = append(accounts, &trade.AccountBalance{
+			TotalCash: &cash,
+			NetAssets: &net,
+			Currency:  c,
+			CashInfos: infos,
+		})
+	}
+	return
+}
@@ -0,0 +1,257 @@
+package paper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+	"github.com/longportapp/openapi-go/trade/algo"
+	"github.com/longportapp/openapi-go/trade/paper"
+)
+
+var _ algo.Trader = (*paper.Broker)(nil)
+
+func TestBroker(t *testing.T) {
+	ctx := context.Background()
+	broker := paper.New(paper.WithCash("HKD", 100000), paper.WithSlippage(10), paper.WithFees(paper.PercentFee(0.001, 5)))
+	var statuses []trade.OrderStatus
+	broker.OnTrade(func(ev *trade.PushEvent) { statuses = append(statuses, ev.Data.Status) })
+
+	broker.SetPrice("700.HK", decimal.NewFromInt(310))
+	orderId, err := broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol:            "700.HK",
+		OrderType:         trade.OrderTypeLO,
+		Side:              trade.OrderSideBuy,
+		SubmittedQuantity: 200,
+		SubmittedPrice:    decimal.NewFromInt(300),
+		TimeInForce:       trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []trade.OrderStatus{trade.OrderNewStatus}, statuses)
+
+	// slipped price is capped by the limit price
+	broker.SetPrice("700.HK", decimal.NewFromInt(300))
+	detail, err := broker.OrderDetail(ctx, orderId)
+	assert.NoError(t, err)
+	assert.Equal(t, trade.OrderFilledStatus, detail.Status)
+	assert.Equal(t, "300", detail.ExecutedPrice.String())
+
+	channels, err := broker.StockPositions(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "200", channels[0].Positions[0].Quantity.String())
+	balances, err := broker.AccountBalance(ctx, nil)
+	assert.NoError(t, err)
+	// 100000 - 60000 - 60 fee
+	assert.Equal(t, "39940", balances[0].TotalCash.String())
+
+	_, err = broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeMO, Side: trade.OrderSideSell, SubmittedQuantity: 300, TimeInForce: trade.TimeTypeDay,
+	})
+	assert.Equal(t, paper.ErrInsufficientPosition, err)
+
+	orderId, err = broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideSell, SubmittedQuantity: 100,
+		SubmittedPrice: decimal.NewFromInt(350), TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, broker.CancelOrder(ctx, orderId))
+	detail, _ = broker.OrderDetail(ctx, orderId)
+	assert.Equal(t, trade.OrderCanceledStatus, detail.Status)
+	assert.Equal(t, trade.OrderCanceledStatus, statuses[len(statuses)-1])
+}
+
+func TestBrokerPartialFill(t *testing.T) {
+	ctx := context.Background()
+	broker := paper.New(paper.WithCash("HKD", 100000), paper.WithFillRatio(0.25))
+	var (
+		statuses []trade.OrderStatus
+		executed []string
+	)
+	broker.OnTrade(func(ev *trade.PushEvent) {
+		statuses = append(statuses, ev.Data.Status)
+		executed = append(executed, ev.Data.ExecutedQuantity.String())
+	})
+
+	orderId, err := broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy, SubmittedQuantity: 200,
+		SubmittedPrice: decimal.NewFromInt(300), TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		broker.SetPrice("700.HK", decimal.NewFromInt(300))
+	}
+	// no more fill after the order is filled
+	broker.SetPrice("700.HK", decimal.NewFromInt(300))
+
+	assert.Equal(t, []trade.OrderStatus{
+		trade.OrderNewStatus,
+		trade.OrderPartialFilledStatus,
+		trade.OrderPartialFilledStatus,
+		trade.OrderPartialFilledStatus,
+		trade.OrderFilledStatus,
+	}, statuses)
+	assert.Equal(t, []string{"0", "50", "100", "150", "200"}, executed)
+	executions, err := broker.TodayExecutions(ctx, &trade.GetTodayExecutions{OrderId: orderId})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(executions))
+	detail, _ := broker.OrderDetail(ctx, orderId)
+	assert.Equal(t, trade.OrderFilledStatus, detail.Status)
+	assert.Equal(t, "200", detail.ExecutedQuantity.String())
+}
+
+func TestBrokerReplaceCopiesParams(t *testing.T) {
+	ctx := context.Background()
+	broker := paper.New(paper.WithCash("HKD", 100000), paper.WithLatency(10*time.Millisecond))
+	orderId, err := broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy, SubmittedQuantity: 100,
+		SubmittedPrice: decimal.NewFromInt(300), TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	params := &trade.ReplaceOrder{OrderId: orderId, Quantity: 200, Price: decimal.NewFromInt(301)}
+	assert.NoError(t, broker.ReplaceOrder(ctx, params))
+	// the caller reuses params before the replacement is applied
+	params.Quantity, params.Price = 300, decimal.NewFromInt(302)
+	time.Sleep(50 * time.Millisecond)
+
+	detail, _ := broker.OrderDetail(ctx, orderId)
+	assert.Equal(t, trade.OrderReplacedStatus, detail.Status)
+	assert.Equal(t, "200", detail.Quantity.String())
+	assert.Equal(t, "301", detail.Price.String())
+}
+
+func TestBrokerReplaceFunds(t *testing.T) {
+	ctx := context.Background()
+	broker := paper.New(paper.WithCash("HKD", 100000))
+	orderId, err := broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy, SubmittedQuantity: 200,
+		SubmittedPrice: decimal.NewFromInt(300), TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+	// the cash frozen by the order is available to its replacement
+	assert.NoError(t, broker.ReplaceOrder(ctx, &trade.ReplaceOrder{OrderId: orderId, Quantity: 300, Price: decimal.NewFromInt(300)}))
+	assert.Equal(t, paper.ErrInsufficientCash,
+		broker.ReplaceOrder(ctx, &trade.ReplaceOrder{OrderId: orderId, Quantity: 400, Price: decimal.NewFromInt(300)}))
+	assert.Equal(t, paper.ErrInsufficientCash,
+		broker.ReplaceOrder(ctx, &trade.ReplaceOrder{OrderId: orderId, Quantity: 300, Price: decimal.NewFromInt(334)}))
+	detail, _ := broker.OrderDetail(ctx, orderId)
+	assert.Equal(t, "300", detail.Quantity.String())
+	assert.Equal(t, "300", detail.Price.String())
+
+	broker.SetPrice("700.HK", decimal.NewFromInt(300))
+	orderId, err = broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideSell, SubmittedQuantity: 100,
+		SubmittedPrice: decimal.NewFromInt(350), TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, broker.ReplaceOrder(ctx, &trade.ReplaceOrder{OrderId: orderId, Quantity: 300, Price: decimal.NewFromInt(350)}))
+	assert.Equal(t, paper.ErrInsufficientPosition,
+		broker.ReplaceOrder(ctx, &trade.ReplaceOrder{OrderId: orderId, Quantity: 301, Price: decimal.NewFromInt(350)}))
+	detail, _ = broker.OrderDetail(ctx, orderId)
+	assert.Equal(t, "300", detail.Quantity.String())
+}
+
+func TestBrokerCancelBeforeAck(t *testing.T) {
+	ctx := context.Background()
+	broker := paper.New(paper.WithCash("HKD", 100000), paper.WithLatency(20*time.Millisecond))
+	statuses := make(chan trade.OrderStatus, 10)
+	broker.OnTrade(func(ev *trade.PushEvent) { statuses <- ev.Data.Status })
+
+	orderId, err := broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy, SubmittedQuantity: 100,
+		SubmittedPrice: decimal.NewFromInt(300), TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+	detail, _ := broker.OrderDetail(ctx, orderId)
+	assert.Equal(t, trade.OrderNotReported, detail.Status)
+	assert.NoError(t, broker.CancelOrder(ctx, orderId))
+	// not filled while waiting for the acknowledgement
+	broker.SetPrice("700.HK", decimal.NewFromInt(300))
+
+	var got []trade.OrderStatus
+	for len(got) < 2 {
+		select {
+		case s := <-statuses:
+			got = append(got, s)
+		case <-time.After(time.Second):
+			t.Fatalf("pushes %v", got)
+		}
+	}
+	// the order is never acknowledged
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, 0, len(statuses))
+	assert.Equal(t, []trade.OrderStatus{trade.OrderPendingCancelStatus, trade.OrderCanceledStatus}, got)
+	detail, _ = broker.OrderDetail(ctx, orderId)
+	assert.Equal(t, trade.OrderCanceledStatus, detail.Status)
+	assert.Equal(t, "0", detail.ExecutedQuantity.String())
+}
+
+func TestBrokerTouchedOrders(t *testing.T) {
+	ctx := context.Background()
+	broker := paper.New(paper.WithCash("HKD", 100000), paper.WithShortSelling(true))
+	broker.SetPrice("700.HK", decimal.NewFromInt(300))
+	lit, err := broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLIT, Side: trade.OrderSideSell, SubmittedQuantity: 100,
+		TriggerPrice: decimal.NewFromInt(310), SubmittedPrice: decimal.NewFromInt(312), TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+	mit, err := broker.SubmitOrder(ctx, &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeMIT, Side: trade.OrderSideBuy, SubmittedQuantity: 100,
+		TriggerPrice: decimal.NewFromInt(290), TimeInForce: trade.TimeTypeDay,
+	})
+	assert.NoError(t, err)
+
+	status := func(orderId string) trade.OrderDetail {
+		detail, err := broker.OrderDetail(ctx, orderId)
+		assert.NoError(t, err)
+		return detail
+	}
+	// the sell is triggered when the price rises to the trigger, but not filled below its limit price
+	broker.SetPrice("700.HK", decimal.NewFromInt(311))
+	assert.Equal(t, trade.OrderNewStatus, status(lit).Status)
+	assert.False(t, status(lit).TriggerAt.IsZero())
+	assert.True(t, status(mit).TriggerAt.IsZero())
+	// triggered once, the limit order is filled even after the price falls back below the trigger
+	broker.SetPrice("700.HK", decimal.NewFromInt(295))
+	assert.Equal(t, trade.OrderNewStatus, status(lit).Status)
+	broker.SetPrice("700.HK", decimal.NewFromInt(312))
+	assert.Equal(t, trade.OrderFilledStatus, status(lit).Status)
+	assert.Equal(t, "312", status(lit).ExecutedPrice.String())
+	assert.Equal(t, trade.OrderNewStatus, status(mit).Status)
+
+	// the buy is triggered when the price falls to the trigger, and filled at market
+	broker.SetPrice("700.HK", decimal.NewFromInt(289))
+	assert.Equal(t, trade.OrderFilledStatus, status(mit).Status)
+	assert.Equal(t, "289", status(mit).ExecutedPrice.String())
+}
+
+func TestBrokerTrailingOrders(t *testing.T) {
+	ctx := context.Background()
+	broker := paper.New(paper.WithCash("HKD", 100000), paper.WithShortSelling(true))
+	for _, o := range []*trade.SubmitOrder{
+		{
+			Symbol: "700.HK", OrderType: trade.OrderTypeTSLPAMT, Side: trade.OrderSideSell, SubmittedQuantity: 100,
+			TrailingAmount: decimal.NewFromInt(5), LimitOffset: decimal.NewFromInt(1), TimeInForce: trade.TimeTypeDay,
+		},
+		{
+			Symbol: "700.HK", OrderType: trade.OrderTypeTSLPPCT, Side: trade.OrderSideSell, SubmittedQuantity: 100,
+			TrailingPercent: decimal.NewFromInt(2), LimitOffset: decimal.NewFromInt(1), TimeInForce: trade.TimeTypeDay,
+		},
+		{
+			Symbol: "700.HK", OrderType: trade.OrderTypeTSMAMT, Side: trade.OrderSideSell, SubmittedQuantity: 100,
+			TrailingAmount: decimal.NewFromInt(5), TimeInForce: trade.TimeTypeDay,
+		},
+	} {
+		_, err := broker.SubmitOrder(ctx, o)
+		assert.True(t, errors.Is(err, paper.ErrUnsupportedOrderType))
+	}
+	orders, err := broker.TodayOrders(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(orders))
+}
@@ -0,0 +1,125 @@
+package paper
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// FeeFunc returns the fee of an execution in the currency of the symbol
+type FeeFunc func(symbol string, side trade.OrderSide, quantity uint64, price decimal.Decimal) decimal.Decimal
+
+// PercentFee returns a FeeFunc of rate of the execution amount with a minimum, e.g. PercentFee(0.0003, 3)
+func PercentFee(rate, min float64) FeeFunc {
+	r, m := decimal.NewFromFloat(rate), decimal.NewFromFloat(min)
+	return func(symbol string, side trade.OrderSide, quantity uint64, price decimal.Decimal) decimal.Decimal {
+		return decimal.Max(price.Mul(decimal.NewFromInt(int64(quantity))).Mul(r), m)
+	}
+}
+
+// Options for Broker
+type Options struct {
+	cash        map[string]decimal.Decimal
+	slippageBps decimal.Decimal
+	latency     time.Duration
+	fillRatio   decimal.Decimal
+	fee         FeeFunc
+	allowShort  bool
+	allowMargin bool
+	priceRound  int32
+	accountNo   string
+	channelName string
+	nowFunc     func() time.Time
+	afterFunc   func(d time.Duration, f func())
+}
+
+// Option for Broker
+type Option func(*Options)
+
+// WithCash to set initial cash of a currency, e.g. WithCash("HKD", 1000000)
+func WithCash(currency string, amount float64) Option {
+	return func(o *Options) {
+		o.cash[currency] = decimal.NewFromFloat(amount)
+	}
+}
+
+// WithSlippage to set slippage in basis points, fills are worse than the reference price by it
+// and limit orders are never filled beyond their limit price
+func WithSlippage(bps float64) Option {
+	return func(o *Options) {
+		o.slippageBps = decimal.NewFromFloat(bps)
+	}
+}
+
+// WithLatency to set the delay of order acknowledgements, replacements and cancellations
+func WithLatency(d time.Duration) Option {
+	return func(o *Options) {
+		if d >= 0 {
+			o.latency = d
+		}
+	}
+}
+
+// WithFillRatio to set the part of the order quantity filled by each matching quote, e.g. 0.25 fills an order
+// in at least four executions with PartialFilledStatus pushes before FilledStatus, default is 1 which fills in full
+func WithFillRatio(ratio float64) Option {
+	return func(o *Options) {
+		if ratio > 0 && ratio <= 1 {
+			o.fillRatio = decimal.NewFromFloat(ratio)
+		}
+	}
+}
+
+// WithFees to set fee of executions, default is no fee
+func WithFees(f FeeFunc) Option {
+	return func(o *Options) {
+		o.fee = f
+	}
+}
+
+// WithShortSelling to allow selling more than the position
+func WithShortSelling(allow bool) Option {
+	return func(o *Options) {
+		o.allowShort = allow
+	}
+}
+
+// WithMargin to allow buying with negative cash
+func WithMargin(allow bool) Option {
+	return func(o *Options) {
+		o.allowMargin = allow
+	}
+}
+
+// WithAccountNo to set account number of pushes
+func WithAccountNo(accountNo string) Option {
+	return func(o *Options) {
+		if accountNo != "" {
+			o.accountNo = accountNo
+		}
+	}
+}
+
+func newOptions(opt ...Option) *Options {
+	opts := &Options{
+		cash:        make(map[string]decimal.Decimal),
+		fillRatio:   decimal.NewFromInt(1),
+		priceRound:  4,
+		accountNo:   "PAPER",
+		channelName: "paper",
+		nowFunc:     time.Now,
+		afterFunc: func(d time.Duration, f func()) {
+			if d <= 0 {
+				f()
+				return
+			}
+			time.AfterFunc(d, f)
+		},
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	return opts
+}
@@ -0,0 +1,77 @@
+package trade
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	tradev1 "github.com/longportapp/openapi-protobufs/gen/go/trade"
+	protocol "github.com/longportapp/openapi-protocol/go"
+)
+
+func notifyPacket(t *testing.T, data string) *protocol.Packet {
+	body, err := json.Marshal(&tradev1.Notification{Topic: string(TopicPrivate), Data: []byte(data)})
+	assert.NoError(t, err)
+	return &protocol.Packet{Metadata: &protocol.Metadata{Codec: protocol.CodecJSON}, Body: body}
+}
+
+func TestParseNotify(t *testing.T) {
+	var events []*PushEvent
+	notify := parseNotifyFunc(func(ev *PushEvent) { events = append(events, ev) }, newOrderTracker())
+
+	notify(notifyPacket(t, `{"event":"order_changed_lb","data":{"order_id":"1","status":"NewStatus","symbol":"700.HK",
+		"limit_offset":"0.2","trailing_percent":"","submitted_at":"1700000000","updated_at":"1700000060","trigger_at":"1700000030"}}`))
+	notify(notifyPacket(t, `{"event":"asset_changed","data":{"currency":"HKD"}}`))
+
+	assert.Equal(t, 2, len(events))
+	ev := events[0]
+	assert.Equal(t, EventOrderChanged, ev.Event)
+	assert.Equal(t, "0.2", ev.Data.LimitOffset.String())
+	assert.Nil(t, ev.Data.TrailingPercent)
+	assert.Equal(t, time.Unix(1700000000, 0), ev.Data.SubmittedAt)
+	assert.Equal(t, time.Unix(1700000060, 0), ev.Data.UpdatedAt)
+	assert.Equal(t, time.Unix(1700000030, 0), ev.Data.TriggerAt)
+	assert.Equal(t, "1700000030", ev.Data.Raw.TriggerAt)
+	assert.Nil(t, ev.Raw)
+
+	ev = events[1]
+	assert.Equal(t, EventKind("asset_changed"), ev.Event)
+	assert.Nil(t, ev.Data)
+	assert.Equal(t, `{"currency":"HKD"}`, string(ev.Raw))
+}
+
+func TestDispatchHandlers(t *testing.T) {
+	c := &core{}
+	var calls []string
+	c.SetHandler(func(*PushEvent) { calls = append(calls, "a") })
+	// OnTrade replaces the handler
+	c.SetHandler(func(*PushEvent) { calls = append(calls, "b") })
+	remove := c.addHandler(func(*PushEvent) { calls = append(calls, "c") })
+	c.dispatch(&PushEvent{})
+	assert.Equal(t, []string{"b", "c"}, calls)
+
+	calls = nil
+	remove()
+	c.dispatch(&PushEvent{})
+	assert.Equal(t, []string{"b"}, calls)
+}
+
+func TestOrderTrackerEviction(t *testing.T) {
+	tracker := newOrderTracker()
+	assert.NoError(t, tracker.Apply("open", OrderNewStatus))
+	for i := 0; i < maxTerminalOrders+10; i++ {
+		id := strconv.Itoa(i)
+		assert.NoError(t, tracker.Apply(id, OrderNewStatus))
+		assert.NoError(t, tracker.Apply(id, OrderFilledStatus))
+	}
+	assert.Equal(t, maxTerminalOrders+1, len(tracker.statuses))
+	// the oldest terminal orders are evicted, open and recent ones are kept
+	assert.Equal(t, OrderStatus(""), tracker.Status("0"))
+	assert.Equal(t, OrderNewStatus, tracker.Status("open"))
+	last := strconv.Itoa(maxTerminalOrders + 9)
+	assert.Equal(t, OrderFilledStatus, tracker.Status(last))
+	// late pushes of recent terminal orders are still detected
+	assert.Error(t, tracker.Apply(last, OrderPartialFilledStatus))
+}
@@ -0,0 +1,25 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the mismatches of the report as CSV with a header, one mismatch per row
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"date", "kind", "key", "reason", "field", "expected", "actual"})
+	for _, m := range r.Mismatches {
+		_ = cw.Write([]string{r.Date, string(m.Kind), m.Key, string(m.Reason), m.Field, m.Expected, m.Actual})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,463 @@
+// Package reconcile compares the orders, executions, positions, cash and cash flows reported by the broker
+// with an expected ledger kept by the caller, e.g. for end-of-day reports
+package reconcile
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// Source is the part of trade.TradeContext used by Reconciler
+type Source interface {
+	TodayOrders(ctx context.Context, params *trade.GetTodayOrders) ([]*trade.Order, error)
+	TodayExecutions(ctx context.Context, params *trade.GetTodayExecutions) ([]*trade.Execution, error)
+	HistoryOrders(ctx context.Context, params *trade.GetHistoryOrders) ([]*trade.Order, bool, error)
+	HistoryExecutions(ctx context.Context, params *trade.GetHistoryExecutions) ([]*trade.Execution, error)
+	StockPositions(ctx context.Context, symbols []string) ([]*trade.StockPositionChannel, error)
+	AccountBalance(ctx context.Context, params *trade.GetAccountBalance) ([]*trade.AccountBalance, error)
+	CashFlow(ctx context.Context, params *trade.GetCashFlow) ([]*trade.CashFlow, error)
+}
+
+// ExpectedOrder is an order the caller submitted on the date
+type ExpectedOrder struct {
+	// OrderId of the order, empty to match the order by ClientOrderId
+	OrderId string
+	// ClientOrderId of trade.SubmitOrder, used when OrderId is empty
+	ClientOrderId    string
+	Symbol           string
+	Side             trade.OrderSide
+	Quantity         decimal.Decimal
+	ExecutedQuantity decimal.Decimal
+	// Status of the order, empty means not checked
+	Status trade.OrderStatus
+}
+
+// ExpectedExecution is a trade the caller booked on the date
+type ExpectedExecution struct {
+	TradeId  string
+	OrderId  string
+	Symbol   string
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+}
+
+// ExpectedPosition is the quantity of a symbol held at the end of the date, negative for short positions
+type ExpectedPosition struct {
+	Symbol   string
+	Quantity decimal.Decimal
+}
+
+// ExpectedCash is the available cash of a currency at the end of the date
+type ExpectedCash struct {
+	Currency  string
+	Available decimal.Decimal
+}
+
+// ExpectedCashFlow is a cash flow of the date, Amount is positive for inflows and negative for outflows.
+// Cash flows have no id, they are compared by the sum of the amounts of each currency and symbol.
+type ExpectedCashFlow struct {
+	Currency string
+	Symbol   string // empty for cash flows of no symbol
+	Amount   decimal.Decimal
+}
+
+// Ledger is what the caller's systems think was done on the date.
+// Empty lists are not reconciled and their records are not queried.
+type Ledger struct {
+	Orders     []ExpectedOrder
+	Executions []ExpectedExecution
+	Positions  []ExpectedPosition
+	Cash       []ExpectedCash
+	CashFlows  []ExpectedCashFlow
+}
+
+// Kind of the reconciled records
+type Kind string
+
+const (
+	KindOrder     Kind = "order"
+	KindExecution Kind = "execution"
+	KindPosition  Kind = "position"
+	KindCash      Kind = "cash"
+	KindCashFlow  Kind = "cash_flow"
+)
+
+// Reason of a Mismatch
+type Reason string
+
+const (
+	ReasonMissing    Reason = "missing"    // in the ledger but not reported by the broker
+	ReasonUnexpected Reason = "unexpected" // reported by the broker but not in the ledger
+	ReasonDiffer     Reason = "differ"     // Field differs
+)
+
+// Mismatch is a difference between the ledger and the broker
+type Mismatch struct {
+	Kind Kind `json:"kind"`
+	// Key is the order id, trade id, symbol, currency, or currency and symbol of cash flows separated by "|"
+	Key      string `json:"key"`
+	Reason   Reason `json:"reason"`
+	Field    string `json:"field,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// Report of a reconciliation
+type Report struct {
+	Date        string    `json:"date"` // 2006-01-02
+	GeneratedAt time.Time `json:"generated_at"`
+	// Matched is the number of records without mismatches of each kind
+	Matched    map[Kind]int `json:"matched"`
+	Mismatches []*Mismatch  `json:"mismatches"`
+}
+
+// OK reports whether there is no mismatch
+func (r *Report) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+// Option for Reconciler
+type Option func(*Reconciler)
+
+// WithTolerance to set the max absolute difference of cash and cash flow amounts treated as equal, default is zero
+func WithTolerance(d decimal.Decimal) Option {
+	return func(r *Reconciler) {
+		if !d.IsNegative() {
+			r.tolerance = d
+		}
+	}
+}
+
+// WithUnexpectedKinds to set the kinds reported as ReasonUnexpected, default is all kinds.
+// E.g. WithUnexpectedKinds(KindPosition, KindCash) ignores orders and executions not in the ledger
+// when the ledger only tracks part of the account.
+func WithUnexpectedKinds(kinds ...Kind) Option {
+	return func(r *Reconciler) {
+		r.unexpected = make(map[Kind]bool, len(kinds))
+		for _, k := range kinds {
+			r.unexpected[k] = true
+		}
+	}
+}
+
+// Reconciler compares a Ledger with the broker
+//
+// Example:
+//
+//	conf, err := config.NewFromEnv()
+//	tctx, err := trade.NewFromCfg(conf)
+//	rec := reconcile.New(tctx, reconcile.WithTolerance(decimal.RequireFromString("0.01")))
+//	report, err := rec.Run(context.Background(), time.Now(), &reconcile.Ledger{
+//	  Orders:    []reconcile.ExpectedOrder{{ClientOrderId: "my-key-1", Symbol: "700.HK", Side: trade.OrderSideBuy,
+//	    Quantity: decimal.NewFromInt(100), ExecutedQuantity: decimal.NewFromInt(100)}},
+//	  Positions: []reconcile.ExpectedPosition{{Symbol: "700.HK", Quantity: decimal.NewFromInt(100)}},
+//	})
+//	if !report.OK() {
+//	  err = report.WriteCSV(os.Stdout)
+//	}
+type Reconciler struct {
+	src        Source
+	tolerance  decimal.Decimal
+	unexpected map[Kind]bool
+	now        func() time.Time
+}
+
+// New returns a Reconciler of src, e.g. a trade.TradeContext
+func New(src Source, opt ...Option) *Reconciler {
+	r := &Reconciler{src: src, now: time.Now}
+	for _, o := range opt {
+		o(r)
+	}
+	return r
+}
+
+// Run reconciles the ledger with the broker for the date, in the location of date.
+// Orders, executions and cash flows are those of the date, today's orders and executions come from
+// TodayOrders and TodayExecutions, and those of former dates from HistoryOrders and HistoryExecutions.
+// Positions and cash are the current values of the account, so run it after the close of the date.
+func (r *Reconciler) Run(ctx context.Context, date time.Time, ledger *Ledger) (*Report, error) {
+	if ledger == nil {
+		ledger = &Ledger{}
+	}
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	next := start.AddDate(0, 0, 1)
+	end := next.Add(-time.Second)
+	now := r.now()
+	report := &Report{
+		Date:        start.Format("2006-01-02"),
+		GeneratedAt: now,
+		Matched:     make(map[Kind]int),
+	}
+	today := !now.Before(start) && now.Before(next)
+
+	if len(ledger.Orders) > 0 {
+		orders, err := r.orders(ctx, today, start, end)
+		if err != nil {
+			return nil, err
+		}
+		r.diffOrders(report, ledger.Orders, orders)
+	}
+	if len(ledger.Executions) > 0 {
+		executions, err := r.executions(ctx, today, start, end)
+		if err != nil {
+			return nil, err
+		}
+		r.diffExecutions(report, ledger.Executions, executions)
+	}
+	if len(ledger.Positions) > 0 {
+		channels, err := r.src.StockPositions(ctx, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "reconcile stock positions error")
+		}
+		r.diffPositions(report, ledger.Positions, channels)
+	}
+	if len(ledger.Cash) > 0 {
+		balances, err := r.src.AccountBalance(ctx, &trade.GetAccountBalance{})
+		if err != nil {
+			return nil, errors.Wrap(err, "reconcile account balance error")
+		}
+		r.diffCash(report, ledger.Cash, balances)
+	}
+	if len(ledger.CashFlows) > 0 {
+		var flows []*trade.CashFlow
+		it := trade.NewCashFlowIterator(ctx, r.src.CashFlow, &trade.GetCashFlow{StartAt: start.Unix(), EndAt: end.Unix()})
+		for it.Next() {
+			flows = append(flows, it.CashFlow())
+		}
+		if err := it.Err(); err != nil {
+			return nil, errors.Wrap(err, "reconcile cash flows error")
+		}
+		r.diffCashFlows(report, ledger.CashFlows, flows)
+	}
+	return report, nil
+}
+
+func (r *Reconciler) orders(ctx context.Context, today bool, start, end time.Time) ([]*trade.Order, error) {
+	if today {
+		orders, err := r.src.TodayOrders(ctx, &trade.GetTodayOrders{})
+		return orders, errors.Wrap(err, "reconcile today orders error")
+	}
+	var orders []*trade.Order
+	it := trade.NewOrderIterator(ctx, r.src.HistoryOrders, &trade.GetHistoryOrders{StartAt: start.Unix(), EndAt: end.Unix()})
+	for it.Next() {
+		orders = append(orders, it.Order())
+	}
+	return orders, errors.Wrap(it.Err(), "reconcile history orders error")
+}
+
+func (r *Reconciler) executions(ctx context.Context, today bool, start, end time.Time) ([]*trade.Execution, error) {
+	if today {
+		executions, err := r.src.TodayExecutions(ctx, &trade.GetTodayExecutions{})
+		return executions, errors.Wrap(err, "reconcile today executions error")
+	}
+	var executions []*trade.Execution
+	it := trade.NewExecutionIterator(ctx, r.src.HistoryExecutions, &trade.GetHistoryExecutions{StartAt: start, EndAt: end})
+	for it.Next() {
+		executions = append(executions, it.Execution())
+	}
+	return executions, errors.Wrap(it.Err(), "reconcile history executions error")
+}
+
+func (r *Reconciler) reportUnexpected(kind Kind) bool {
+	return r.unexpected == nil || r.unexpected[kind]
+}
+
+// diff is the mismatches of one record
+type diff struct {
+	report *Report
+	kind   Kind
+	key    string
+	n      int
+}
+
+func (d *diff) field(name string, expected, actual string) {
+	if expected == actual {
+		return
+	}
+	d.n++
+	d.report.Mismatches = append(d.report.Mismatches, &Mismatch{
+		Kind: d.kind, Key: d.key, Reason: ReasonDiffer, Field: name, Expected: expected, Actual: actual,
+	})
+}
+
+func (d *diff) amount(name string, expected, actual, tolerance decimal.Decimal) {
+	if expected.Sub(actual).Abs().GreaterThan(tolerance) {
+		d.field(name, expected.String(), actual.String())
+	}
+}
+
+func (d *diff) done() {
+	if d.n == 0 {
+		d.report.Matched[d.kind]++
+	}
+}
+
+func (r *Reconciler) missing(report *Report, kind Kind, key string) {
+	report.Mismatches = append(report.Mismatches, &Mismatch{Kind: kind, Key: key, Reason: ReasonMissing})
+}
+
+func (r *Reconciler) unexpectedRecord(report *Report, kind Kind, key string) {
+	if r.reportUnexpected(kind) {
+		report.Mismatches = append(report.Mismatches, &Mismatch{Kind: kind, Key: key, Reason: ReasonUnexpected})
+	}
+}
+
+func (r *Reconciler) diffOrders(report *Report, expected []ExpectedOrder, actual []*trade.Order) {
+	byId := make(map[string]*trade.Order, len(actual))
+	byClientId := make(map[string]*trade.Order)
+	for _, o := range actual {
+		byId[o.OrderId] = o
+		if cid := trade.ClientOrderIdFromRemark(o.Remark); cid != "" {
+			byClientId[cid] = o
+		}
+	}
+	seen := make(map[string]bool, len(expected))
+	for _, e := range expected {
+		key, o := e.OrderId, byId[e.OrderId]
+		if key == "" {
+			key, o = e.ClientOrderId, byClientId[e.ClientOrderId]
+		}
+		if o == nil {
+			r.missing(report, KindOrder, key)
+			continue
+		}
+		seen[o.OrderId] = true
+		d := &diff{report: report, kind: KindOrder, key: key}
+		d.field("symbol", e.Symbol, o.Symbol)
+		d.field("side", string(e.Side), string(o.Side))
+		d.amount("quantity", e.Quantity, o.Quantity, decimal.Zero)
+		d.amount("executed_quantity", e.ExecutedQuantity, o.ExecutedQuantity, decimal.Zero)
+		if e.Status != "" {
+			d.field("status", string(e.Status), string(o.Status))
+		}
+		d.done()
+	}
+	for _, o := range actual {
+		if !seen[o.OrderId] {
+			r.unexpectedRecord(report, KindOrder, o.OrderId)
+		}
+	}
+}
+
+func (r *Reconciler) diffExecutions(report *Report, expected []ExpectedExecution, actual []*trade.Execution) {
+	byId := make(map[string]*trade.Execution, len(actual))
+	for _, ex := range actual {
+		byId[ex.TradeId] = ex
+	}
+	seen := make(map[string]bool, len(expected))
+	for _, e := range expected {
+		ex := byId[e.TradeId]
+		if ex == nil {
+			r.missing(report, KindExecution, e.TradeId)
+			continue
+		}
+		seen[e.TradeId] = true
+		price := decimal.Zero
+		if ex.Price != nil {
+			price = *ex.Price
+		}
+		d := &diff{report: report, kind: KindExecution, key: e.TradeId}
+		d.field("order_id", e.OrderId, ex.OrderId)
+		d.field("symbol", e.Symbol, ex.Symbol)
+		d.amount("quantity", e.Quantity, ex.Quantity, decimal.Zero)
+		d.amount("price", e.Price, price, decimal.Zero)
+		d.done()
+	}
+	for _, ex := range actual {
+		if !seen[ex.TradeId] {
+			r.unexpectedRecord(report, KindExecution, ex.TradeId)
+		}
+	}
+}
+
+func (r *Reconciler) diffPositions(report *Report, expected []ExpectedPosition, channels []*trade.StockPositionChannel) {
+	actual := make(map[string]decimal.Decimal)
+	for _, ch := range channels {
+		for _, p := range ch.Positions {
+			actual[p.Symbol] = actual[p.Symbol].Add(p.Quantity)
+		}
+	}
+	want := make(map[string]decimal.Decimal, len(expected))
+	for _, e := range expected {
+		want[e.Symbol] = want[e.Symbol].Add(e.Quantity)
+	}
+	r.diffAmounts(report, KindPosition, "quantity", want, actual, decimal.Zero)
+}
+
+func (r *Reconciler) diffCash(report *Report, expected []ExpectedCash, balances []*trade.AccountBalance) {
+	actual := make(map[string]decimal.Decimal)
+	for _, b := range balances {
+		for _, c := range b.CashInfos {
+			if c.AvailableCash != nil {
+				currency := strings.ToUpper(c.Currency)
+				actual[currency] = actual[currency].Add(*c.AvailableCash)
+			}
+		}
+	}
+	want := make(map[string]decimal.Decimal, len(expected))
+	for _, e := range expected {
+		currency := strings.ToUpper(e.Currency)
+		want[currency] = want[currency].Add(e.Available)
+	}
+	r.diffAmounts(report, KindCash, "available", want, actual, r.tolerance)
+}
+
+func (r *Reconciler) diffCashFlows(report *Report, expected []ExpectedCashFlow, flows []*trade.CashFlow) {
+	actual := make(map[string]decimal.Decimal)
+	for _, f := range flows {
+		if f.Balance == nil {
+			continue
+		}
+		amount := *f.Balance
+		if f.Direction == trade.OfDirectionOut {
+			amount = amount.Neg()
+		}
+		key := cashFlowKey(f.Currency, f.Symbol)
+		actual[key] = actual[key].Add(amount)
+	}
+	want := make(map[string]decimal.Decimal, len(expected))
+	for _, e := range expected {
+		key := cashFlowKey(e.Currency, e.Symbol)
+		want[key] = want[key].Add(e.Amount)
+	}
+	r.diffAmounts(report, KindCashFlow, "amount", want, actual, r.tolerance)
+}
+
+func cashFlowKey(currency, symbol string) string {
+	return strings.ToUpper(currency) + "|" + symbol
+}
+
+// diffAmounts compares amounts by key in the order of keys, zero amounts missing on one side are equal
+func (r *Reconciler) diffAmounts(report *Report, kind Kind, field string, expected, actual map[string]decimal.Decimal, tolerance decimal.Decimal) {
+	keys := make([]string, 0, len(expected)+len(actual))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	for k := range actual {
+		if _, ok := expected[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e, inExpected := expected[k]
+		a, inActual := actual[k]
+		switch {
+		case !inActual && !e.IsZero():
+			r.missing(report, kind, k)
+		case !inExpected && !a.IsZero():
+			r.unexpectedRecord(report, kind, k)
+		default:
+			d := &diff{report: report, kind: kind, key: k}
+			d.amount(field, e, a, tolerance)
+			d.done()
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package reconcile_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+	"github.com/longportapp/openapi-go/trade/reconcile"
+	"github.com/longportapp/openapi-go/trade/tradetest"
+)
+
+func dec(s string) *decimal.Decimal {
+	d := decimal.RequireFromString(s)
+	return &d
+}
+
+func broker() *tradetest.Fake {
+	fake := tradetest.New()
+	fake.TodayOrdersFunc = func(ctx context.Context, params *trade.GetTodayOrders) ([]*trade.Order, error) {
+		return []*trade.Order{
+			{OrderId: "1", Symbol: "700.HK", Side: trade.OrderSideBuy, Quantity: *dec("100"), ExecutedQuantity: *dec("100"),
+				Status: trade.OrderFilledStatus, Remark: "cid:key-1"},
+			{OrderId: "2", Symbol: "AAPL.US", Side: trade.OrderSideSell, Quantity: *dec("10"), Status: trade.OrderNewStatus},
+		}, nil
+	}
+	fake.TodayExecutionsFunc = func(ctx context.Context, params *trade.GetTodayExecutions) ([]*trade.Execution, error) {
+		return []*trade.Execution{{OrderId: "1", TradeId: "t1", Symbol: "700.HK", Quantity: *dec("100"), Price: dec("320")}}, nil
+	}
+	fake.StockPositionsFunc = func(ctx context.Context, symbols []string) ([]*trade.StockPositionChannel, error) {
+		return []*trade.StockPositionChannel{
+			{Positions: []*trade.StockPosition{{Symbol: "700.HK", Quantity: *dec("200")}}},
+			{Positions: []*trade.StockPosition{{Symbol: "AAPL.US", Quantity: *dec("10")}}},
+		}, nil
+	}
+	fake.AccountBalanceFunc = func(ctx context.Context, params *trade.GetAccountBalance) ([]*trade.AccountBalance, error) {
+		return []*trade.AccountBalance{{CashInfos: []*trade.CashInfo{{Currency: "HKD", AvailableCash: dec("10000.004")}}}}, nil
+	}
+	fake.CashFlowFunc = func(ctx context.Context, params *trade.GetCashFlow) ([]*trade.CashFlow, error) {
+		if params.Page > 1 {
+			return nil, nil
+		}
+		return []*trade.CashFlow{
+			{Currency: "HKD", Symbol: "700.HK", Direction: trade.OfDirectionOut, Balance: dec("32000"), BusinessTime: time.Now()},
+			{Currency: "HKD", Symbol: "700.HK", Direction: trade.OfDirectionOut, Balance: dec("62.33"), BusinessTime: time.Now()},
+		}, nil
+	}
+	return fake
+}
+
+func TestReconcile(t *testing.T) {
+	rec := reconcile.New(broker(), reconcile.WithTolerance(decimal.RequireFromString("0.01")))
+	report, err := rec.Run(context.Background(), time.Now(), &reconcile.Ledger{
+		Orders: []reconcile.ExpectedOrder{
+			{ClientOrderId: "key-1", Symbol: "700.HK", Side: trade.OrderSideBuy, Quantity: *dec("100"), ExecutedQuantity: *dec("100")},
+			{OrderId: "3", Symbol: "MSFT.US", Side: trade.OrderSideBuy, Quantity: *dec("5")},
+		},
+		Executions: []reconcile.ExpectedExecution{{TradeId: "t1", OrderId: "1", Symbol: "700.HK", Quantity: *dec("100"), Price: *dec("321")}},
+		Positions:  []reconcile.ExpectedPosition{{Symbol: "700.HK", Quantity: *dec("200")}},
+		Cash:       []reconcile.ExpectedCash{{Currency: "hkd", Available: *dec("10000")}},
+		CashFlows:  []reconcile.ExpectedCashFlow{{Currency: "HKD", Symbol: "700.HK", Amount: *dec("-32062.33")}},
+	})
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+
+	var got []string
+	for _, m := range report.Mismatches {
+		got = append(got, strings.Join([]string{string(m.Kind), m.Key, string(m.Reason), m.Field, m.Expected, m.Actual}, ","))
+	}
+	assert.Equal(t, []string{
+		"order,3,missing,,,",
+		"order,2,unexpected,,,",
+		"execution,t1,differ,price,321,320",
+		"position,AAPL.US,unexpected,,,",
+	}, got)
+	assert.Equal(t, 1, report.Matched[reconcile.KindOrder])
+	assert.Equal(t, 1, report.Matched[reconcile.KindPosition])
+	assert.Equal(t, 1, report.Matched[reconcile.KindCash])
+	assert.Equal(t, 1, report.Matched[reconcile.KindCashFlow])
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteCSV(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.Equal(t, report.Date+",execution,t1,differ,price,321,320", lines[3])
+
+	buf.Reset()
+	assert.NoError(t, report.WriteJSON(&buf))
+	assert.True(t, strings.Contains(buf.String(), `"reason": "missing"`))
+}
+
+func TestReconcileUnexpectedKinds(t *testing.T) {
+	rec := reconcile.New(broker(), reconcile.WithUnexpectedKinds(reconcile.KindCash))
+	report, err := rec.Run(context.Background(), time.Now(), &reconcile.Ledger{
+		Positions: []reconcile.ExpectedPosition{{Symbol: "700.HK", Quantity: *dec("200")}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+}
@@ -76,6 +76,9 @@ type SubmitOrder struct {
 	OutsideRTH        OutsideRTH
 	Remark            string
 	TimeInForce       TimeType // required
+	// ClientOrderId is an optional idempotency key of letters, digits, `-` and `_`, at most 32 characters.
+	// It is carried in the order remark, so len(Remark) + len(ClientOrderId) must be at most 59.
+	ClientOrderId string
 }
 
 type GetEstimateMaxPurchaseQuantity struct {
@@ -0,0 +1,290 @@
+package trade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/config"
+	"github.com/longportapp/openapi-go/log"
+	"github.com/longportapp/openapi-go/quote"
+)
+
+// RiskRule is the name of a pre-trade risk control rule
+type RiskRule string
+
+const (
+	RiskRuleKillSwitch       RiskRule = "kill_switch"
+	RiskRuleMaxOrderNotional RiskRule = "max_order_notional"
+	RiskRuleMaxOrderQuantity RiskRule = "max_order_quantity"
+	RiskRuleAllowedSymbols   RiskRule = "allowed_symbols"
+	RiskRuleAllowedMarkets   RiskRule = "allowed_markets"
+	RiskRuleOrderRate        RiskRule = "max_orders_per_minute"
+	RiskRulePriceCollar      RiskRule = "price_collar"
+)
+
+// PriceSource provides last done prices for the price collar and notional checks.
+// *quote.QuoteContext implements it, the quotes of the symbols should be subscribed.
+type PriceSource interface {
+	RealtimeQuote(ctx context.Context, symbols []string) ([]*quote.Quote, error)
+}
+
+// RiskRejection is returned when an order is blocked by pre-trade risk controls
+type RiskRejection struct {
+	Rule    RiskRule
+	Symbol  string
+	OrderId string // set for replace requests
+	Limit   string
+	Actual  string
+	Reason  string
+	Time    time.Time
+}
+
+func (e *RiskRejection) Error() string {
+	return fmt.Sprintf("risk control rejected order, rule:%s symbol:%s order_id:%s limit:%s actual:%s reason:%s time:%s",
+		e.Rule, e.Symbol, e.OrderId, e.Limit, e.Actual, e.Reason, e.Time.Format(time.RFC3339Nano))
+}
+
+// riskOrder is the part of an order checked by risk controls
+type riskOrder struct {
+	orderId  string
+	symbol   string
+	quantity decimal.Decimal
+	price    decimal.Decimal // zero for market orders
+}
+
+type riskControl struct {
+	cfg         config.RiskConfig
+	priceSource PriceSource
+	killed      bool
+	mu          sync.Mutex
+	submitted   []time.Time
+}
+
+func newRiskControl(cfg *config.RiskConfig, priceSource PriceSource) *riskControl {
+	rc := &riskControl{priceSource: priceSource}
+	if cfg != nil {
+		rc.cfg = *cfg
+		rc.killed = cfg.KillSwitch
+	}
+	return rc
+}
+
+func (rc *riskControl) setKilled(killed bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.killed = killed
+}
+
+func (rc *riskControl) isKilled() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.killed
+}
+
+// needSymbol reports whether checking a replace request needs the symbol of the order
+func (rc *riskControl) needSymbol() bool {
+	c := rc.cfg
+	return c.MaxOrderNotional > 0 || len(c.MarketMaxOrderNotional) > 0 || len(c.SymbolMaxOrderQuantity) > 0 ||
+		c.PriceCollarPercent > 0 || len(c.AllowedSymbols) > 0 || len(c.AllowedMarkets) > 0
+}
+
+// Check returns *RiskRejection if the order breaks any rule.
+// A submitted order is counted for the order rate rule only when countRate is true.
+func (rc *riskControl) Check(ctx context.Context, o *riskOrder, countRate bool) error {
+	reject := func(rule RiskRule, limit, actual, reason string) error {
+		rej := &RiskRejection{
+			Rule:    rule,
+			Symbol:  o.symbol,
+			OrderId: o.orderId,
+			Limit:   limit,
+			Actual:  actual,
+			Reason:  reason,
+			Time:    time.Now(),
+		}
+		log.Warnf("%v", rej)
+		return rej
+	}
+	cfg := rc.cfg
+
+	if rc.isKilled() {
+		return reject(RiskRuleKillSwitch, "", "", "kill switch is active")
+	}
+
+	market := SymbolMarket(o.symbol)
+	if len(cfg.AllowedSymbols) > 0 && !containsFold(cfg.AllowedSymbols, o.symbol) {
+		return reject(RiskRuleAllowedSymbols, strings.Join(cfg.AllowedSymbols, ","), o.symbol, "symbol is not allowed")
+	}
+	if len(cfg.AllowedMarkets) > 0 {
+		markets := make([]string, 0, len(cfg.AllowedMarkets))
+		for _, m := range cfg.AllowedMarkets {
+			markets = append(markets, string(m))
+		}
+		if !containsFold(markets, string(market)) {
+			return reject(RiskRuleAllowedMarkets, strings.Join(markets, ","), string(market), "market is not allowed")
+		}
+	}
+
+	if maxQty := rc.maxQuantity(o.symbol); maxQty > 0 && o.quantity.GreaterThan(decimal.NewFromInt(int64(maxQty))) {
+		return reject(RiskRuleMaxOrderQuantity, fmt.Sprint(maxQty), o.quantity.String(), "order quantity exceeds limit")
+	}
+
+	maxNotional := cfg.MaxOrderNotional
+	if v, ok := cfg.MarketMaxOrderNotional[market]; ok {
+		maxNotional = v
+	}
+	if maxNotional > 0 || cfg.PriceCollarPercent > 0 {
+		lastDone := rc.lastDone(ctx, o.symbol)
+		price := o.price
+		if price.IsZero() {
+			price = lastDone
+		}
+		if maxNotional > 0 {
+			limit := decimal.NewFromFloat(maxNotional)
+			if price.IsZero() {
+				return reject(RiskRuleMaxOrderNotional, limit.String(), "", "no reference price to compute notional")
+			}
+			if notional := price.Mul(o.quantity); notional.GreaterThan(limit) {
+				return reject(RiskRuleMaxOrderNotional, limit.String(), notional.String(), "order notional exceeds limit")
+			}
+		}
+		if cfg.PriceCollarPercent > 0 && !o.price.IsZero() {
+			limit := decimal.NewFromFloat(cfg.PriceCollarPercent)
+			if lastDone.IsZero() {
+				return reject(RiskRulePriceCollar, limit.String()+"%", o.price.String(), "no last done price")
+			}
+			deviation := o.price.Sub(lastDone).Abs().Div(lastDone).Mul(decimal.NewFromInt(100))
+			if deviation.GreaterThan(limit) {
+				return reject(RiskRulePriceCollar, limit.String()+"%", deviation.StringFixed(2)+"%",
+					"order price "+o.price.String()+" deviates from last done "+lastDone.String())
+			}
+		}
+	}
+
+	if cfg.MaxOrdersPerMinute > 0 && countRate {
+		rc.mu.Lock()
+		now := time.Now()
+		kept := rc.submitted[:0]
+		for _, t := range rc.submitted {
+			if now.Sub(t) < time.Minute {
+				kept = append(kept, t)
+			}
+		}
+		rc.submitted = kept
+		if len(rc.submitted) >= cfg.MaxOrdersPerMinute {
+			rc.mu.Unlock()
+			return reject(RiskRuleOrderRate, fmt.Sprint(cfg.MaxOrdersPerMinute), fmt.Sprint(len(kept)+1), "too many orders in the last minute")
+		}
+		rc.submitted = append(rc.submitted, now)
+		rc.mu.Unlock()
+	}
+	return nil
+}
+
+func (rc *riskControl) maxQuantity(symbol string) uint64 {
+	for s, v := range rc.cfg.SymbolMaxOrderQuantity {
+		if strings.EqualFold(s, symbol) {
+			return v
+		}
+	}
+	return rc.cfg.MaxOrderQuantity
+}
+
+func (rc *riskControl) lastDone(ctx context.Context, symbol string) decimal.Decimal {
+	if rc.priceSource == nil {
+		return decimal.Zero
+	}
+	quotes, err := rc.priceSource.RealtimeQuote(ctx, []string{symbol})
+	if err != nil {
+		log.Warnf("risk control get realtime quote of %s error:%v", symbol, err)
+		return decimal.Zero
+	}
+	for _, q := range quotes {
+		if q != nil && q.LastDone != nil {
+			return *q.LastDone
+		}
+	}
+	return decimal.Zero
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *TradeContext) checkSubmitRisk(ctx context.Context, params *SubmitOrder) error {
+	return c.risk.Check(ctx, &riskOrder{
+		symbol:   params.Symbol,
+		quantity: decimal.NewFromInt(int64(params.SubmittedQuantity)),
+		price:    params.SubmittedPrice,
+	}, true)
+}
+
+func (c *TradeContext) checkReplaceRisk(ctx context.Context, params *ReplaceOrder) error {
+	o := &riskOrder{
+		orderId:  params.OrderId,
+		quantity: decimal.NewFromInt(int64(params.Quantity)),
+		price:    params.Price,
+	}
+	if !c.risk.isKilled() && c.risk.needSymbol() {
+		detail, err := c.OrderDetail(ctx, params.OrderId)
+		if err != nil {
+			return err
+		}
+		o.symbol = detail.Symbol
+	}
+	return c.risk.Check(ctx, o, false)
+}
+
+// KillSwitch blocks all new SubmitOrder and ReplaceOrder requests with *RiskRejection until ResumeTrading is called.
+// If cancelOpen is true, all of today's open orders are canceled, the returned error joins the cancel failures.
+//
+// Example:
+//
+//	conf, err := config.NewFromEnv()
+//	tctx, err := trade.NewFromCfg(conf)
+//	err = tctx.KillSwitch(context.Background(), true)
+func (c *TradeContext) KillSwitch(ctx context.Context, cancelOpen bool) (err error) {
+	c.risk.setKilled(true)
+	log.Warn("risk control kill switch activated")
+	if !cancelOpen {
+		return
+	}
+	orders, err := c.TodayOrders(ctx, nil)
+	if err != nil {
+		return
+	}
+	var failed []string
+	for _, o := range orders {
+		if !o.Status.IsOpen() {
+			continue
+		}
+		if cerr := c.CancelOrder(ctx, o.OrderId); cerr != nil {
+			log.Errorf("kill switch cancel order %s error:%v", o.OrderId, cerr)
+			failed = append(failed, o.OrderId+": "+cerr.Error())
+		}
+	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("kill switch failed to cancel %d orders, %s", len(failed), strings.Join(failed, "; "))
+	}
+	return
+}
+
+// ResumeTrading deactivates the kill switch
+func (c *TradeContext) ResumeTrading() {
+	c.risk.setKilled(false)
+	log.Warn("risk control kill switch deactivated")
+}
+
+// KillSwitchActive reports whether the kill switch is active
+func (c *TradeContext) KillSwitchActive() bool {
+	return c.risk.isKilled()
+}
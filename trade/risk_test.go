@@ -0,0 +1,65 @@
+package trade
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/config"
+	"github.com/longportapp/openapi-go/quote"
+)
+
+type staticPrices map[string]decimal.Decimal
+
+func (p staticPrices) RealtimeQuote(ctx context.Context, symbols []string) ([]*quote.Quote, error) {
+	quotes := make([]*quote.Quote, 0, len(symbols))
+	for _, s := range symbols {
+		if v, ok := p[s]; ok {
+			quotes = append(quotes, &quote.Quote{Symbol: s, LastDone: &v})
+		}
+	}
+	return quotes, nil
+}
+
+func ruleOf(t *testing.T, err error) RiskRule {
+	var rej *RiskRejection
+	if !errors.As(err, &rej) {
+		t.Fatalf("expect *RiskRejection, got %v", err)
+	}
+	return rej.Rule
+}
+
+func TestRiskControl(t *testing.T) {
+	ctx := context.Background()
+	rc := newRiskControl(&config.RiskConfig{
+		MaxOrderNotional:       10000,
+		MarketMaxOrderNotional: map[openapi.Market]float64{openapi.MarketHK: 100000},
+		MaxOrderQuantity:       1000,
+		SymbolMaxOrderQuantity: map[string]uint64{"700.HK": 500},
+		AllowedMarkets:         []openapi.Market{openapi.MarketUS, openapi.MarketHK},
+		MaxOrdersPerMinute:     3,
+		PriceCollarPercent:     5,
+	}, staticPrices{"AAPL.US": decimal.NewFromInt(100), "700.HK": decimal.NewFromInt(300)})
+
+	order := func(symbol string, qty, price int64) *riskOrder {
+		return &riskOrder{symbol: symbol, quantity: decimal.NewFromInt(qty), price: decimal.NewFromInt(price)}
+	}
+
+	assert.NoError(t, rc.Check(ctx, order("AAPL.US", 10, 101), true))
+	assert.Equal(t, RiskRuleAllowedMarkets, ruleOf(t, rc.Check(ctx, order("D05.SG", 10, 10), true)))
+	assert.Equal(t, RiskRuleMaxOrderQuantity, ruleOf(t, rc.Check(ctx, order("700.HK", 600, 300), true)))
+	assert.Equal(t, RiskRuleMaxOrderNotional, ruleOf(t, rc.Check(ctx, order("AAPL.US", 200, 100), true)))
+	assert.Equal(t, RiskRulePriceCollar, ruleOf(t, rc.Check(ctx, order("AAPL.US", 10, 110), true)))
+	// market order uses last done price for notional
+	assert.NoError(t, rc.Check(ctx, order("700.HK", 300, 0), true))
+	assert.NoError(t, rc.Check(ctx, order("AAPL.US", 1, 100), true))
+	assert.Equal(t, RiskRuleOrderRate, ruleOf(t, rc.Check(ctx, order("AAPL.US", 1, 100), true)))
+	assert.NoError(t, rc.Check(ctx, order("AAPL.US", 1, 100), false))
+
+	rc.setKilled(true)
+	assert.Equal(t, RiskRuleKillSwitch, ruleOf(t, rc.Check(ctx, order("AAPL.US", 1, 100), false)))
+}
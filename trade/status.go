@@ -0,0 +1,216 @@
+package trade
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	notReportedStatuses = []OrderStatus{
+		OrderNotReported,
+		OrderReplacedNotReported,
+		OrderProtectedNotReported,
+		OrderVarietiesNotReported,
+	}
+	pendingReplaceStatuses = []OrderStatus{OrderWaitToReplace, OrderPendingReplaceStatus}
+	pendingCancelStatuses  = []OrderStatus{OrderWaitToCancel, OrderPendingCancelStatus}
+	terminalStatuses       = []OrderStatus{
+		OrderFilledStatus,
+		OrderRejectedStatus,
+		OrderCanceledStatus,
+		OrderExpiredStatus,
+		OrderPartialWithdrawal,
+	}
+)
+
+// orderStatusTransitions lists the statuses an order can move to from a non-terminal status.
+// Terminal statuses have no entry, they can not move anywhere.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderNotReported:          fromNotReported(),
+	OrderReplacedNotReported:  fromNotReported(),
+	OrderProtectedNotReported: fromNotReported(),
+	OrderVarietiesNotReported: fromNotReported(),
+	OrderWaitToNew: statusList(
+		[]OrderStatus{OrderNewStatus, OrderPartialFilledStatus},
+		pendingReplaceStatuses, pendingCancelStatuses, terminalStatuses,
+	),
+	OrderNewStatus: fromWorking(),
+	OrderWaitToReplace: statusList(
+		[]OrderStatus{OrderPendingReplaceStatus, OrderReplacedStatus, OrderReplacedNotReported, OrderNewStatus, OrderPartialFilledStatus},
+		pendingCancelStatuses, terminalStatuses,
+	),
+	OrderPendingReplaceStatus: statusList(
+		[]OrderStatus{OrderReplacedStatus, OrderReplacedNotReported, OrderNewStatus, OrderPartialFilledStatus},
+		pendingCancelStatuses, terminalStatuses,
+	),
+	OrderReplacedStatus:      fromWorking(),
+	OrderPartialFilledStatus: statusList([]OrderStatus{OrderReplacedStatus}, pendingReplaceStatuses, pendingCancelStatuses, terminalStatuses),
+	OrderWaitToCancel: statusList(
+		[]OrderStatus{OrderPendingCancelStatus, OrderNewStatus, OrderReplacedStatus, OrderPartialFilledStatus},
+		notReportedStatuses, terminalStatuses,
+	),
+	OrderPendingCancelStatus: statusList(
+		[]OrderStatus{OrderNewStatus, OrderReplacedStatus, OrderPartialFilledStatus},
+		notReportedStatuses, terminalStatuses,
+	),
+}
+
+func fromNotReported() []OrderStatus {
+	return statusList(
+		[]OrderStatus{OrderWaitToNew, OrderNewStatus, OrderPartialFilledStatus},
+		notReportedStatuses, pendingReplaceStatuses, pendingCancelStatuses, terminalStatuses,
+	)
+}
+
+func fromWorking() []OrderStatus {
+	return statusList(
+		[]OrderStatus{OrderReplacedStatus, OrderPartialFilledStatus},
+		pendingReplaceStatuses, pendingCancelStatuses, terminalStatuses,
+	)
+}
+
+func statusList(groups ...[]OrderStatus) (list []OrderStatus) {
+	for _, g := range groups {
+		list = append(list, g...)
+	}
+	return
+}
+
+func containsStatus(list []OrderStatus, s OrderStatus) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKnown reports whether the status is one of the documented order statuses.
+func (s OrderStatus) IsKnown() bool {
+	_, ok := orderStatusTransitions[s]
+	return ok || s.IsTerminal()
+}
+
+// IsTerminal reports whether the order is finished and will not change any more.
+// Filled, rejected, canceled, expired and partial withdrawal orders are terminal.
+func (s OrderStatus) IsTerminal() bool {
+	return containsStatus(terminalStatuses, s)
+}
+
+// IsNotReported reports whether the order has not been reported to the exchange yet,
+// e.g. a conditional order waiting for its trigger.
+func (s OrderStatus) IsNotReported() bool {
+	return containsStatus(notReportedStatuses, s)
+}
+
+// IsWorking reports whether the order is live at the exchange and can still be executed,
+// including orders with a pending replace or cancel request.
+func (s OrderStatus) IsWorking() bool {
+	switch s {
+	case OrderNewStatus, OrderReplacedStatus, OrderPartialFilledStatus:
+		return true
+	}
+	return s.IsPendingReplace() || s.IsPendingCancel()
+}
+
+// IsPendingReplace reports whether a replace request of the order is in progress.
+func (s OrderStatus) IsPendingReplace() bool {
+	return containsStatus(pendingReplaceStatuses, s)
+}
+
+// IsPendingCancel reports whether a cancel request of the order is in progress.
+func (s OrderStatus) IsPendingCancel() bool {
+	return containsStatus(pendingCancelStatuses, s)
+}
+
+// IsOpen reports whether the order is not terminal, it can still be replaced or canceled.
+func (s OrderStatus) IsOpen() bool {
+	return s.IsKnown() && !s.IsTerminal()
+}
+
+// CanTransitionTo reports whether an order in status s can legally move to status next.
+// Receiving the same status again is always legal.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	return ValidateTransition(s, next) == nil
+}
+
+// TransitionError is returned when an order status update is out-of-order or impossible.
+type TransitionError struct {
+	OrderId string
+	From    OrderStatus
+	To      OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+	if e.OrderId != "" {
+		return fmt.Sprintf("order %s: invalid status transition from %s to %s", e.OrderId, e.From, e.To)
+	}
+	return fmt.Sprintf("invalid status transition from %s to %s", e.From, e.To)
+}
+
+// ValidateTransition returns *TransitionError if an order can not move from status `from` to status `to`.
+// An empty `from` means the order has not been seen yet, so any known status is accepted.
+func ValidateTransition(from, to OrderStatus) error {
+	if !to.IsKnown() || (from != "" && !from.IsKnown()) {
+		return &TransitionError{From: from, To: to}
+	}
+	if from == "" || from == to {
+		return nil
+	}
+	if !containsStatus(orderStatusTransitions[from], to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// maxTerminalOrders is the number of terminal orders kept by orderTracker to detect late pushes
+const maxTerminalOrders = 1024
+
+// orderTracker keeps the last accepted status of each order to detect out-of-order pushes.
+// Orders are evicted after they are terminal, only the latest maxTerminalOrders terminal orders are kept.
+type orderTracker struct {
+	mu       sync.Mutex
+	statuses map[string]OrderStatus
+	terminal []string // ring of terminal order ids, oldest at next
+	next     int
+}
+
+func newOrderTracker() *orderTracker {
+	return &orderTracker{statuses: make(map[string]OrderStatus)}
+}
+
+// finish records the order as terminal and evicts the oldest terminal order if there are too many
+func (t *orderTracker) finish(orderId string) {
+	if len(t.terminal) < maxTerminalOrders {
+		t.terminal = append(t.terminal, orderId)
+		return
+	}
+	delete(t.statuses, t.terminal[t.next])
+	t.terminal[t.next] = orderId
+	t.next = (t.next + 1) % maxTerminalOrders
+}
+
+// Apply moves the order to status `to` if the transition is legal.
+// Otherwise the tracked status is kept and *TransitionError is returned.
+func (t *orderTracker) Apply(orderId string, to OrderStatus) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	from := t.statuses[orderId]
+	if err := ValidateTransition(from, to); err != nil {
+		te := err.(*TransitionError)
+		te.OrderId = orderId
+		return te
+	}
+	t.statuses[orderId] = to
+	if to.IsTerminal() && !from.IsTerminal() {
+		t.finish(orderId)
+	}
+	return nil
+}
+
+// Status returns the last accepted status of the order, it is empty if the order is not tracked.
+func (t *orderTracker) Status(orderId string) OrderStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.statuses[orderId]
+}
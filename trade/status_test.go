@@ -0,0 +1,57 @@
+package trade_test
+
+import (
+	"testing"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/longportapp/openapi-go/trade"
+)
+
+func TestOrderStatusClassification(t *testing.T) {
+	assert.True(t, trade.OrderFilledStatus.IsTerminal())
+	assert.True(t, trade.OrderPartialWithdrawal.IsTerminal())
+	assert.False(t, trade.OrderPartialFilledStatus.IsTerminal())
+
+	assert.True(t, trade.OrderNewStatus.IsWorking())
+	assert.True(t, trade.OrderPendingCancelStatus.IsWorking())
+	assert.False(t, trade.OrderNotReported.IsWorking())
+
+	assert.True(t, trade.OrderWaitToReplace.IsPendingReplace())
+	assert.True(t, trade.OrderPendingReplaceStatus.IsPendingReplace())
+	assert.True(t, trade.OrderVarietiesNotReported.IsNotReported())
+
+	assert.True(t, trade.OrderWaitToNew.IsOpen())
+	assert.False(t, trade.OrderCanceledStatus.IsOpen())
+	assert.False(t, trade.OrderStatus("Unknown").IsOpen())
+}
+
+func TestValidateTransition(t *testing.T) {
+	cases := []struct {
+		from, to trade.OrderStatus
+		ok       bool
+	}{
+		{"", trade.OrderNewStatus, true},
+		{trade.OrderNotReported, trade.OrderNewStatus, true},
+		{trade.OrderWaitToNew, trade.OrderNewStatus, true},
+		{trade.OrderNewStatus, trade.OrderPartialFilledStatus, true},
+		{trade.OrderPartialFilledStatus, trade.OrderFilledStatus, true},
+		{trade.OrderPendingReplaceStatus, trade.OrderReplacedStatus, true},
+		{trade.OrderPendingCancelStatus, trade.OrderNewStatus, true},
+		{trade.OrderNewStatus, trade.OrderNewStatus, true},
+		{trade.OrderPartialFilledStatus, trade.OrderNewStatus, false},
+		{trade.OrderNewStatus, trade.OrderWaitToNew, false},
+		{trade.OrderFilledStatus, trade.OrderCanceledStatus, false},
+		{trade.OrderCanceledStatus, trade.OrderNewStatus, false},
+		{trade.OrderNewStatus, trade.OrderStatus("Unknown"), false},
+	}
+	for _, c := range cases {
+		err := trade.ValidateTransition(c.from, c.to)
+		if c.ok {
+			assert.NoError(t, err, "%s -> %s", c.from, c.to)
+		} else {
+			assert.Error(t, err, "%s -> %s", c.from, c.to)
+		}
+		assert.Equal(t, c.ok, c.from.CanTransitionTo(c.to))
+	}
+}
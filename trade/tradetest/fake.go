@@ -0,0 +1,479 @@
+// Package tradetest provides a fake trade.TradeAPI for tests
+package tradetest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/longportapp/openapi-go/trade"
+)
+
+// Fake is an in-memory trade.TradeAPI.
+// Responses are scripted by the XxxFunc fields, a method returns zero values if its func is nil.
+// Set the func fields before the fake is used concurrently.
+// Errors injected by InjectError are returned before the func is called.
+//
+// Push events are delivered to the handlers synchronously by Emit, which tracks order statuses
+// and sets PushEvent.TransitionErr like TradeContext does. SubmitAndWait, CancelAll, ReplaceAll, the iterators
+// and KillSwitch are implemented on top of the other methods, so they behave like TradeContext with the scripted responses.
+//
+// Example:
+//
+//	fake := tradetest.New()
+//	fake.OrderDetailFunc = func(ctx context.Context, orderId string) (trade.OrderDetail, error) {
+//	  return trade.OrderDetail{OrderId: orderId, Status: trade.OrderFilledStatus}, nil
+//	}
+//	fake.InjectError("CancelOrder", errors.New("timeout"))
+//	var api trade.TradeAPI = fake
+//	api.OnTrade(func(event *trade.PushEvent) {})
+//	fake.EmitOrderChanged(&trade.PushOrderChanged{OrderId: "1", Status: trade.OrderNewStatus})
+type Fake struct {
+	seq uint64 // keep 64-bit aligned for atomic
+
+	HistoryExecutionsFunc           func(context.Context, *trade.GetHistoryExecutions) ([]*trade.Execution, error)
+	TodayExecutionsFunc             func(context.Context, *trade.GetTodayExecutions) ([]*trade.Execution, error)
+	HistoryOrdersFunc               func(context.Context, *trade.GetHistoryOrders) ([]*trade.Order, bool, error)
+	TodayOrdersFunc                 func(context.Context, *trade.GetTodayOrders) ([]*trade.Order, error)
+	ReplaceOrderFunc                func(context.Context, *trade.ReplaceOrder) error
+	SubmitOrderFunc                 func(context.Context, *trade.SubmitOrder) (string, error)
+	CancelOrderFunc                 func(context.Context, string) error
+	AccountBalanceFunc              func(context.Context, *trade.GetAccountBalance) ([]*trade.AccountBalance, error)
+	CashFlowFunc                    func(context.Context, *trade.GetCashFlow) ([]*trade.CashFlow, error)
+	FundPositionsFunc               func(context.Context, []string) ([]*trade.FundPositionChannel, error)
+	StockPositionsFunc              func(context.Context, []string) ([]*trade.StockPositionChannel, error)
+	MarginRatioFunc                 func(context.Context, string) (trade.MarginRatio, error)
+	OrderDetailFunc                 func(context.Context, string) (trade.OrderDetail, error)
+	EstimateMaxPurchaseQuantityFunc func(context.Context, *trade.GetEstimateMaxPurchaseQuantity) (trade.EstimateMaxPurchaseQuantityResponse, error)
+	EstimateChargesFunc             func(context.Context, *trade.SubmitOrder) (trade.OrderChargeDetail, error)
+
+	mu       sync.Mutex
+	errs     map[string]error
+	calls    map[string]int
+	topics   []trade.Topic
+	handler  func(*trade.PushEvent)
+	handlers []pushHandler // added by AddTradeHandler, in the order of registration
+	hseq     uint64
+	statuses map[string]trade.OrderStatus
+	// client order id -> order id of the default SubmitOrder
+	clientOrders map[string]string
+	killed       bool
+	closed       bool
+}
+
+var _ trade.TradeAPI = (*Fake)(nil)
+
+type pushHandler struct {
+	id uint64
+	f  func(*trade.PushEvent)
+}
+
+// New return Fake
+func New() *Fake {
+	return &Fake{
+		errs:         make(map[string]error),
+		calls:        make(map[string]int),
+		statuses:     make(map[string]trade.OrderStatus),
+		clientOrders: make(map[string]string),
+	}
+}
+
+// InjectError makes the method return err until InjectError is called with nil err, method is the name of
+// the trade.TradeAPI method, e.g. "SubmitOrder"
+func (f *Fake) InjectError(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errs, method)
+		return
+	}
+	f.errs[method] = err
+}
+
+// Calls returns how many times the method has been called
+func (f *Fake) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// Closed reports whether Close has been called
+func (f *Fake) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *Fake) call(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[method]++
+	return f.errs[method]
+}
+
+// Emit delivers the push event to the handler set by OnTrade, then to the handlers added by AddTradeHandler
+// in the order they were added, as TradeContext does.
+// The order status of event.Data is tracked, and event.TransitionErr is set if the status can not follow
+// the tracked one.
+func (f *Fake) Emit(event *trade.PushEvent) {
+	f.mu.Lock()
+	if event.Data != nil {
+		from := f.statuses[event.Data.OrderId]
+		if err := trade.ValidateTransition(from, event.Data.Status); err != nil {
+			te := err.(*trade.TransitionError)
+			te.OrderId = event.Data.OrderId
+			event.TransitionErr = te
+		} else {
+			f.statuses[event.Data.OrderId] = event.Data.Status
+		}
+	}
+	handlers := make([]func(*trade.PushEvent), 0, len(f.handlers)+1)
+	if f.handler != nil {
+		handlers = append(handlers, f.handler)
+	}
+	for _, h := range f.handlers {
+		handlers = append(handlers, h.f)
+	}
+	f.mu.Unlock()
+	for _, h := range handlers {
+		h(event)
+	}
+}
+
+// EmitOrderChanged emits an order changed push event of data
+func (f *Fake) EmitOrderChanged(data *trade.PushOrderChanged) {
+	f.Emit(&trade.PushEvent{Event: trade.EventOrderChanged, Data: data})
+}
+
+// OnTrade set the handler called by Emit
+func (f *Fake) OnTrade(h func(*trade.PushEvent)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handler = h
+}
+
+// AddTradeHandler adds a handler called by Emit, the returned function removes it
+func (f *Fake) AddTradeHandler(h func(*trade.PushEvent)) (remove func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hseq++
+	id := f.hseq
+	f.handlers = append(f.handlers, pushHandler{id: id, f: h})
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, v := range f.handlers {
+			if v.id == id {
+				f.handlers = append(f.handlers[:i:i], f.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// TrackedOrderStatus returns the last valid order status emitted
+func (f *Fake) TrackedOrderStatus(orderId string) trade.OrderStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.statuses[orderId]
+}
+
+// Subscribe records the topics, all topics are subscribed successfully
+func (f *Fake) Subscribe(ctx context.Context, topics []trade.Topic) (subRes *trade.SubResponse, err error) {
+	if err = f.call("Subscribe"); err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, topic := range topics {
+		if !contains(f.topics, topic) {
+			f.topics = append(f.topics, topic)
+		}
+	}
+	return &trade.SubResponse{
+		Success: append([]trade.Topic(nil), topics...),
+		Current: append([]trade.Topic(nil), f.topics...),
+	}, nil
+}
+
+// Unsubscribe removes the topics recorded by Subscribe
+func (f *Fake) Unsubscribe(ctx context.Context, topics []trade.Topic) (unsubRes *trade.UnsubResponse, err error) {
+	if err = f.call("Unsubscribe"); err != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current := f.topics[:0]
+	for _, topic := range f.topics {
+		if !contains(topics, topic) {
+			current = append(current, topic)
+		}
+	}
+	f.topics = current
+	return &trade.UnsubResponse{Current: append([]trade.Topic(nil), f.topics...)}, nil
+}
+
+func contains(list []trade.Topic, s trade.Topic) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// HistoryExecutions calls HistoryExecutionsFunc, it returns zero values if HistoryExecutionsFunc is nil
+func (f *Fake) HistoryExecutions(ctx context.Context, params *trade.GetHistoryExecutions) (trades []*trade.Execution, err error) {
+	if err = f.call("HistoryExecutions"); err != nil || f.HistoryExecutionsFunc == nil {
+		return
+	}
+	return f.HistoryExecutionsFunc(ctx, params)
+}
+
+// TodayExecutions calls TodayExecutionsFunc, it returns zero values if TodayExecutionsFunc is nil
+func (f *Fake) TodayExecutions(ctx context.Context, params *trade.GetTodayExecutions) (trades []*trade.Execution, err error) {
+	if err = f.call("TodayExecutions"); err != nil || f.TodayExecutionsFunc == nil {
+		return
+	}
+	return f.TodayExecutionsFunc(ctx, params)
+}
+
+// HistoryOrders calls HistoryOrdersFunc, it returns zero values if HistoryOrdersFunc is nil
+func (f *Fake) HistoryOrders(ctx context.Context, params *trade.GetHistoryOrders) (orders []*trade.Order, hasMore bool, err error) {
+	if err = f.call("HistoryOrders"); err != nil || f.HistoryOrdersFunc == nil {
+		return
+	}
+	return f.HistoryOrdersFunc(ctx, params)
+}
+
+// TodayOrders calls TodayOrdersFunc, it returns zero values if TodayOrdersFunc is nil
+func (f *Fake) TodayOrders(ctx context.Context, params *trade.GetTodayOrders) (orders []*trade.Order, err error) {
+	if err = f.call("TodayOrders"); err != nil || f.TodayOrdersFunc == nil {
+		return
+	}
+	return f.TodayOrdersFunc(ctx, params)
+}
+
+// ReplaceOrder calls ReplaceOrderFunc, it returns zero values if ReplaceOrderFunc is nil.
+// It returns *trade.RiskRejection when the kill switch is active.
+func (f *Fake) ReplaceOrder(ctx context.Context, params *trade.ReplaceOrder) (err error) {
+	if err = f.call("ReplaceOrder"); err != nil {
+		return
+	}
+	if err = f.checkKilled("", params.OrderId); err != nil || f.ReplaceOrderFunc == nil {
+		return
+	}
+	return f.ReplaceOrderFunc(ctx, params)
+}
+
+// SubmitOrder calls SubmitOrderFunc, it returns a sequential order id if SubmitOrderFunc is nil,
+// or the same order id for the same ClientOrderId.
+// It returns *trade.RiskRejection when the kill switch is active.
+func (f *Fake) SubmitOrder(ctx context.Context, params *trade.SubmitOrder) (orderId string, err error) {
+	if err = f.call("SubmitOrder"); err != nil {
+		return
+	}
+	if err = f.checkKilled(params.Symbol, ""); err != nil {
+		return
+	}
+	if f.SubmitOrderFunc != nil {
+		return f.SubmitOrderFunc(ctx, params)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if orderId = f.clientOrders[params.ClientOrderId]; orderId != "" {
+		return
+	}
+	orderId = strconv.FormatUint(atomic.AddUint64(&f.seq, 1), 10)
+	if params.ClientOrderId != "" {
+		f.clientOrders[params.ClientOrderId] = orderId
+	}
+	return
+}
+
+// CancelOrder calls CancelOrderFunc, it returns zero values if CancelOrderFunc is nil
+func (f *Fake) CancelOrder(ctx context.Context, orderId string) (err error) {
+	if err = f.call("CancelOrder"); err != nil || f.CancelOrderFunc == nil {
+		return
+	}
+	return f.CancelOrderFunc(ctx, orderId)
+}
+
+// AccountBalance calls AccountBalanceFunc, it returns zero values if AccountBalanceFunc is nil
+func (f *Fake) AccountBalance(ctx context.Context, params *trade.GetAccountBalance) (accounts []*trade.AccountBalance, err error) {
+	if err = f.call("AccountBalance"); err != nil || f.AccountBalanceFunc == nil {
+		return
+	}
+	return f.AccountBalanceFunc(ctx, params)
+}
+
+// CashFlow calls CashFlowFunc, it returns zero values if CashFlowFunc is nil
+func (f *Fake) CashFlow(ctx context.Context, params *trade.GetCashFlow) (cashflows []*trade.CashFlow, err error) {
+	if err = f.call("CashFlow"); err != nil || f.CashFlowFunc == nil {
+		return
+	}
+	return f.CashFlowFunc(ctx, params)
+}
+
+// FundPositions calls FundPositionsFunc, it returns zero values if FundPositionsFunc is nil
+func (f *Fake) FundPositions(ctx context.Context, symbols []string) (fundPositionChannels []*trade.FundPositionChannel, err error) {
+	if err = f.call("FundPositions"); err != nil || f.FundPositionsFunc == nil {
+		return
+	}
+	return f.FundPositionsFunc(ctx, symbols)
+}
+
+// StockPositions calls StockPositionsFunc, it returns zero values if StockPositionsFunc is nil
+func (f *Fake) StockPositions(ctx context.Context, symbols []string) (stockPositionChannels []*trade.StockPositionChannel, err error) {
+	if err = f.call("StockPositions"); err != nil || f.StockPositionsFunc == nil {
+		return
+	}
+	return f.StockPositionsFunc(ctx, symbols)
+}
+
+// MarginRatio calls MarginRatioFunc, it returns zero values if MarginRatioFunc is nil
+func (f *Fake) MarginRatio(ctx context.Context, symbol string) (marginRatio trade.MarginRatio, err error) {
+	if err = f.call("MarginRatio"); err != nil || f.MarginRatioFunc == nil {
+		return
+	}
+	return f.MarginRatioFunc(ctx, symbol)
+}
+
+// OrderDetail calls OrderDetailFunc, it returns zero values if OrderDetailFunc is nil
+func (f *Fake) OrderDetail(ctx context.Context, orderId string) (orderDetail trade.OrderDetail, err error) {
+	if err = f.call("OrderDetail"); err != nil || f.OrderDetailFunc == nil {
+		return
+	}
+	return f.OrderDetailFunc(ctx, orderId)
+}
+
+// EstimateMaxPurchaseQuantity calls EstimateMaxPurchaseQuantityFunc, it returns zero values if EstimateMaxPurchaseQuantityFunc is nil
+func (f *Fake) EstimateMaxPurchaseQuantity(ctx context.Context, params *trade.GetEstimateMaxPurchaseQuantity) (empqr trade.EstimateMaxPurchaseQuantityResponse, err error) {
+	if err = f.call("EstimateMaxPurchaseQuantity"); err != nil || f.EstimateMaxPurchaseQuantityFunc == nil {
+		return
+	}
+	return f.EstimateMaxPurchaseQuantityFunc(ctx, params)
+}
+
+// EstimateCharges calls EstimateChargesFunc, it estimates by trade.DefaultFeeSchedules at SubmittedPrice
+// if EstimateChargesFunc is nil
+func (f *Fake) EstimateCharges(ctx context.Context, params *trade.SubmitOrder) (detail trade.OrderChargeDetail, err error) {
+	if err = f.call("EstimateCharges"); err != nil {
+		return
+	}
+	if f.EstimateChargesFunc != nil {
+		return f.EstimateChargesFunc(ctx, params)
+	}
+	if schedule := trade.DefaultFeeSchedules()[trade.SymbolMarket(params.Symbol)]; schedule != nil {
+		detail = schedule.Estimate(params.Side, params.SubmittedQuantity, params.SubmittedPrice)
+	}
+	return
+}
+
+// WithdrawOrder calls CancelOrder
+func (f *Fake) WithdrawOrder(ctx context.Context, orderId string) (err error) {
+	return f.CancelOrder(ctx, orderId)
+}
+
+// SubmitAndWait works like TradeContext.SubmitAndWait on the fake, it wakes up on Emit and polls OrderDetail
+func (f *Fake) SubmitAndWait(ctx context.Context, params *trade.SubmitOrder, until trade.WaitCondition, opt ...trade.WaitOption) (result *trade.SubmitAndWaitResult, err error) {
+	return trade.SubmitAndWaitWith(ctx, f, params, until, opt...)
+}
+
+// CancelAll works like TradeContext.CancelAll on the fake, it lists orders by TodayOrders and cancels them by CancelOrder
+func (f *Fake) CancelAll(ctx context.Context, filter *trade.OrderFilter, opt ...trade.BulkOption) (report *trade.BulkReport, err error) {
+	return trade.CancelAllWith(ctx, f, filter, opt...)
+}
+
+// ReplaceAll works like TradeContext.ReplaceAll on the fake, it lists orders by TodayOrders and replaces them by ReplaceOrder
+func (f *Fake) ReplaceAll(ctx context.Context, filter *trade.OrderFilter, mutate func(o *trade.Order) *trade.ReplaceOrder, opt ...trade.BulkOption) (report *trade.BulkReport, err error) {
+	return trade.ReplaceAllWith(ctx, f, filter, mutate, opt...)
+}
+
+// HistoryOrdersIter returns an iterator paging by HistoryOrders
+func (f *Fake) HistoryOrdersIter(ctx context.Context, params *trade.GetHistoryOrders, opt ...trade.IterOption) *trade.OrderIterator {
+	return trade.NewOrderIterator(ctx, f.HistoryOrders, params, opt...)
+}
+
+// HistoryExecutionsIter returns an iterator paging by HistoryExecutions
+func (f *Fake) HistoryExecutionsIter(ctx context.Context, params *trade.GetHistoryExecutions, opt ...trade.IterOption) *trade.ExecutionIterator {
+	return trade.NewExecutionIterator(ctx, f.HistoryExecutions, params, opt...)
+}
+
+// CashFlowIter returns an iterator paging by CashFlow
+func (f *Fake) CashFlowIter(ctx context.Context, params *trade.GetCashFlow, opt ...trade.IterOption) *trade.CashFlowIterator {
+	return trade.NewCashFlowIterator(ctx, f.CashFlow, params, opt...)
+}
+
+// KillSwitch blocks SubmitOrder and ReplaceOrder until ResumeTrading is called,
+// open orders returned by TodayOrders are canceled by CancelOrder if cancelOpen is true
+func (f *Fake) KillSwitch(ctx context.Context, cancelOpen bool) (err error) {
+	if err = f.call("KillSwitch"); err != nil {
+		return
+	}
+	f.mu.Lock()
+	f.killed = true
+	f.mu.Unlock()
+	if !cancelOpen {
+		return
+	}
+	orders, err := f.TodayOrders(ctx, nil)
+	if err != nil {
+		return
+	}
+	var failed []string
+	for _, o := range orders {
+		if !o.Status.IsOpen() {
+			continue
+		}
+		if cerr := f.CancelOrder(ctx, o.OrderId); cerr != nil {
+			failed = append(failed, o.OrderId+": "+cerr.Error())
+		}
+	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("kill switch failed to cancel %d orders, %s", len(failed), strings.Join(failed, "; "))
+	}
+	return
+}
+
+// ResumeTrading deactivates the kill switch
+func (f *Fake) ResumeTrading() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.killed = false
+}
+
+// KillSwitchActive reports whether the kill switch is active
+func (f *Fake) KillSwitchActive() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.killed
+}
+
+func (f *Fake) checkKilled(symbol, orderId string) error {
+	if !f.KillSwitchActive() {
+		return nil
+	}
+	return &trade.RiskRejection{
+		Rule:    trade.RiskRuleKillSwitch,
+		Symbol:  symbol,
+		OrderId: orderId,
+		Reason:  "kill switch is active",
+		Time:    time.Now(),
+	}
+}
+
+// Close marks the fake closed
+func (f *Fake) Close() error {
+	if err := f.call("Close"); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,85 @@
+package tradetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+
+	"github.com/longportapp/openapi-go/trade"
+	"github.com/longportapp/openapi-go/trade/tradetest"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	fake := tradetest.New()
+	fake.OrderDetailFunc = func(ctx context.Context, orderId string) (trade.OrderDetail, error) {
+		status := fake.TrackedOrderStatus(orderId)
+		if status == "" {
+			status = trade.OrderNewStatus
+		}
+		return trade.OrderDetail{OrderId: orderId, Status: status}, nil
+	}
+	fake.TodayExecutionsFunc = func(ctx context.Context, params *trade.GetTodayExecutions) ([]*trade.Execution, error) {
+		return []*trade.Execution{{OrderId: params.OrderId, TradeId: "t1"}}, nil
+	}
+
+	var api trade.TradeAPI = fake
+	var events []*trade.PushEvent
+	api.OnTrade(func(event *trade.PushEvent) {
+		events = append(events, event)
+	})
+	go func() {
+		for fake.Calls("OrderDetail") == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		fake.EmitOrderChanged(&trade.PushOrderChanged{OrderId: "1", Status: trade.OrderFilledStatus})
+	}()
+	res, err := api.SubmitAndWait(ctx, &trade.SubmitOrder{Symbol: "700.HK"}, trade.WaitFilled, trade.WithPollInterval(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", res.OrderId)
+	assert.Equal(t, trade.OrderFilledStatus, res.Detail.Status)
+	assert.Equal(t, 1, len(res.Executions))
+	assert.Equal(t, trade.OrderFilledStatus, api.TrackedOrderStatus("1"))
+
+	// out-of-order push
+	fake.EmitOrderChanged(&trade.PushOrderChanged{OrderId: "1", Status: trade.OrderNewStatus})
+	assert.Error(t, events[len(events)-1].TransitionErr)
+
+	injected := errors.New("timeout")
+	fake.InjectError("CancelOrder", injected)
+	assert.Equal(t, injected, api.CancelOrder(ctx, "1"))
+	fake.InjectError("CancelOrder", nil)
+	assert.NoError(t, api.CancelOrder(ctx, "1"))
+	assert.Equal(t, 2, fake.Calls("CancelOrder"))
+
+	assert.NoError(t, api.KillSwitch(ctx, false))
+	_, err = api.SubmitOrder(ctx, &trade.SubmitOrder{Symbol: "700.HK"})
+	var rejection *trade.RiskRejection
+	assert.Equal(t, true, errors.As(err, &rejection))
+}
+
+func TestFakeHandlerOrder(t *testing.T) {
+	fake := tradetest.New()
+	var calls []string
+	handler := func(name string) func(*trade.PushEvent) {
+		return func(*trade.PushEvent) { calls = append(calls, name) }
+	}
+	var removes []func()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		removes = append(removes, fake.AddTradeHandler(handler(name)))
+	}
+	// the OnTrade handler is called first even if it is set last
+	fake.OnTrade(handler("on"))
+	fake.EmitOrderChanged(&trade.PushOrderChanged{OrderId: "1", Status: trade.OrderNewStatus})
+	assert.Equal(t, []string{"on", "a", "b", "c", "d", "e"}, calls)
+
+	removes[1]()
+	removes[1]()
+	removes[3]()
+	calls = nil
+	fake.EmitOrderChanged(&trade.PushOrderChanged{OrderId: "1", Status: trade.OrderFilledStatus})
+	assert.Equal(t, []string{"on", "a", "c", "e"}, calls)
+}
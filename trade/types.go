@@ -1,11 +1,13 @@
 package trade
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/shopspring/decimal"
 
 	"github.com/longportapp/openapi-go"
+	"github.com/longportapp/openapi-go/trade/jsontypes"
 )
 
 type (
@@ -115,8 +117,10 @@ type Execution struct {
 	TradeId     string
 	Symbol      string
 	TradeDoneAt time.Time
-	Quantity    string
+	Quantity    decimal.Decimal
 	Price       *decimal.Decimal
+	// Raw is the execution returned by the API before conversion
+	Raw *jsontypes.Execution
 }
 
 // Executions has a Execution list
@@ -135,11 +139,11 @@ type Order struct {
 	OrderId          string
 	Status           OrderStatus
 	StockName        string
-	Quantity         string
-	ExecutedQuantity string
+	Quantity         decimal.Decimal
+	ExecutedQuantity decimal.Decimal
 	Price            *decimal.Decimal
 	ExecutedPrice    *decimal.Decimal
-	SubmittedAt      string
+	SubmittedAt      time.Time
 	Side             OrderSide
 	Symbol           string
 	OrderType        OrderType
@@ -148,9 +152,9 @@ type Order struct {
 	Msg              string
 	Tag              OrderTag
 	TimeInForce      TimeType
-	ExpireDate       string
-	UpdatedAt        string
-	TriggerAt        string
+	ExpireDate       time.Time // zero if the order has no expire date
+	UpdatedAt        time.Time
+	TriggerAt        time.Time // zero if the order is not triggered
 	TrailingAmount   *decimal.Decimal
 	TrailingPercent  *decimal.Decimal
 	LimitOffset      *decimal.Decimal
@@ -158,6 +162,8 @@ type Order struct {
 	Currency         string
 	OutsideRth       OutsideRTH
 	Remark           string
+	// Raw is the order returned by the API before conversion
+	Raw *jsontypes.Order
 }
 
 type OrderChargeItem struct {
@@ -173,9 +179,10 @@ type OrderChargeDetail struct {
 }
 
 type OrderChargeFee struct {
-	Code ChargeCategoryCode
-	Name string
-	Fees []OrderChargeFee
+	Code     string
+	Name     string
+	Amount   decimal.Decimal
+	Currency string
 }
 
 type OrderHistoryDetail struct {
@@ -184,21 +191,21 @@ type OrderHistoryDetail struct {
 	Price decimal.Decimal
 	// Executed quantity for executed orders, remaining quantity for expired,
 	// canceled, rejected orders, etc.
-	Quantity int64
+	Quantity decimal.Decimal
 	Status   OrderStatus
-	Msg      string // Execution or error message
-	Time     string // Occurrence time
+	Msg      string    // Execution or error message
+	Time     time.Time // Occurrence time
 }
 
 type OrderDetail struct {
 	OrderId                  string
 	Status                   OrderStatus
 	StockName                string
-	Quantity                 int64 // Submitted quantity
-	ExecutedQuantity         int64
+	Quantity                 decimal.Decimal // Submitted quantity
+	ExecutedQuantity         decimal.Decimal
 	Price                    *decimal.Decimal // Submitted price
 	ExecutedPrice            *decimal.Decimal
-	SubmittedAt              string    // Submitted time
+	SubmittedAt              time.Time // Submitted time
 	Side                     OrderSide /// Order side
 	Symbol                   string
 	OrderType                OrderType
@@ -207,9 +214,9 @@ type OrderDetail struct {
 	Msg                      string // Rejected Message or remark
 	Tag                      OrderTag
 	TimeInForce              TimeType
-	ExpireDate               string
-	UpdatedAt                string
-	TriggerAt                string // Conditional order trigger time
+	ExpireDate               time.Time // zero if the order has no expire date
+	UpdatedAt                time.Time
+	TriggerAt                time.Time // Conditional order trigger time, zero if the order is not triggered
 	TrailingAmount           *decimal.Decimal
 	TrailingPercent          *decimal.Decimal
 	LimitOffset              *decimal.Decimal
@@ -226,8 +233,10 @@ type OrderDetail struct {
 	PlatformDeductedStatus   DeductionStatus
 	PlatformDeductedAmount   *decimal.Decimal
 	PlatformDeductedCurrency string
-	History                  OrderHistoryDetail
+	History                  []OrderHistoryDetail
 	ChargeDetail             OrderChargeDetail
+	// Raw is the order detail returned by the API before conversion
+	Raw *jsontypes.OrderDetail
 }
 
 // AccountBalances has a AccountBalance list
@@ -286,11 +295,13 @@ type StockPositionChannel struct {
 type StockPosition struct {
 	Symbol            string
 	SymbolName        string
-	Quantity          string
-	AvailableQuantity string
+	Quantity          decimal.Decimal
+	AvailableQuantity decimal.Decimal
 	Currency          string
 	CostPrice         *decimal.Decimal
 	Market            openapi.Market
+	// Raw is the position returned by the API before conversion
+	Raw *jsontypes.StockPosition
 }
 
 // CashFlows has a CashFlow list
@@ -305,9 +316,11 @@ type CashFlow struct {
 	BusinessType        BalanceType
 	Balance             *decimal.Decimal
 	Currency            string
-	BusinessTime        string
+	BusinessTime        time.Time
 	Symbol              string
 	Description         string
+	// Raw is the cash flow returned by the API before conversion
+	Raw *jsontypes.CashFlow
 }
 
 // CashInfo
@@ -319,10 +332,32 @@ type CashInfo struct {
 	Currency      string
 }
 
-// PushEvent is quote context callback event
+// Topic is the topic of trade push events
+type Topic string
+
+const (
+	// TopicPrivate is the topic of the private events of the account, e.g. order changed
+	TopicPrivate Topic = "private"
+)
+
+// EventKind is the kind of trade push events
+type EventKind string
+
+const (
+	// EventOrderChanged is the kind of order changed events, PushEvent.Data is set for it
+	EventOrderChanged EventKind = "order_changed_lb"
+)
+
+// PushEvent is trade context callback event
 type PushEvent struct {
-	Event string
-	Data  *PushOrderChanged
+	Event EventKind
+	// Data is set for EventOrderChanged
+	Data *PushOrderChanged
+	// Raw is the JSON data of event kinds unknown to this SDK, Data is nil for them
+	Raw json.RawMessage
+	// TransitionErr is set when the order status in Data is out-of-order or impossible
+	// for the last status tracked by TradeContext. The tracked status is kept unchanged.
+	TransitionErr error
 }
 
 // PushOrderChanged is order change event details
@@ -333,42 +368,44 @@ type PushOrderChanged struct {
 	ExecutedQuantity *decimal.Decimal
 	LastPrice        *decimal.Decimal
 	LastShare        *decimal.Decimal
-	LimitOffset      string
+	LimitOffset      *decimal.Decimal
 	Msg              string
 	OrderId          string
 	OrderType        OrderType
 	Side             OrderSide
 	Status           OrderStatus
 	StockName        string
-	SubmittedAt      string
+	SubmittedAt      time.Time
 	Price            *decimal.Decimal
 	Quantity         *decimal.Decimal
 	Symbol           string
 	Tag              OrderTag
 	TrailingAmount   *decimal.Decimal
-	TrailingPercent  string
-	TriggerAt        string
+	TrailingPercent  *decimal.Decimal
+	TriggerAt        time.Time // zero if the order is not triggered
 	TriggerPrice     *decimal.Decimal
 	TriggerStatus    TriggerStatus
-	UpdatedAt        string
+	UpdatedAt        time.Time
 	Remark           string
+	// Raw is the event data pushed by the server before conversion
+	Raw *jsontypes.PushOrderChanged
 }
 
 // SubResponse is subscribe function response
 type SubResponse struct {
-	Success []string
+	Success []Topic
 	Fail    []*SubResponseFail
-	Current []string
+	Current []Topic
 }
 
 // SubResponseFail contains subscribe failed reason
 type SubResponseFail struct {
-	Topic  string
+	Topic  Topic
 	Reason string
 }
 
 type UnsubResponse struct {
-	Current []string
+	Current []Topic
 }
 
 // MarginRatio contains some ratio
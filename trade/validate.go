@@ -0,0 +1,242 @@
+package trade
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go"
+)
+
+// FieldError describes one invalid field of an order request
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ValidationError is returned when an order request is rejected before being sent
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Error())
+	}
+	return "invalid order request, " + strings.Join(msgs, "; ")
+}
+
+type fieldErrors []*FieldError
+
+func (fe *fieldErrors) Add(field, reason string) {
+	*fe = append(*fe, &FieldError{Field: field, Reason: reason})
+}
+
+func (fe fieldErrors) Err() error {
+	if len(fe) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: fe}
+}
+
+var knownOrderTypes = []OrderType{
+	OrderTypeLO, OrderTypeELO, OrderTypeMO, OrderTypeAO, OrderTypeALO, OrderTypeODD,
+	OrderTypeLIT, OrderTypeMIT, OrderTypeTSLPAMT, OrderTypeTSLPPCT, OrderTypeTSMAMT, OrderTypeTSMPCT, OrderTypeSLO,
+}
+
+func (t OrderType) isKnown() bool {
+	for _, v := range knownOrderTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// SymbolMarket returns the market of symbol by its suffix, e.g. `US` of `AAPL.US`, empty if symbol has no suffix
+func SymbolMarket(symbol string) openapi.Market {
+	idx := strings.LastIndex(symbol, ".")
+	if idx < 0 {
+		return ""
+	}
+	return openapi.Market(strings.ToUpper(symbol[idx+1:]))
+}
+
+// orderPrices holds the type-specific fields shared by SubmitOrder and ReplaceOrder
+type orderPrices struct {
+	priceField      string
+	price           decimal.Decimal
+	triggerPrice    decimal.Decimal
+	limitOffset     decimal.Decimal
+	trailingAmount  decimal.Decimal
+	trailingPercent decimal.Decimal
+}
+
+func (p *orderPrices) validate(orderType OrderType, fe *fieldErrors) {
+	requirePositive := func(field string, v decimal.Decimal) {
+		if !v.IsPositive() {
+			fe.Add(field, "required and must be positive for "+string(orderType)+" order")
+		}
+	}
+	switch orderType {
+	case OrderTypeLO, OrderTypeELO, OrderTypeALO, OrderTypeODD:
+		requirePositive(p.priceField, p.price)
+	case OrderTypeLIT:
+		requirePositive(p.priceField, p.price)
+		requirePositive("TriggerPrice", p.triggerPrice)
+	case OrderTypeMIT:
+		requirePositive("TriggerPrice", p.triggerPrice)
+	case OrderTypeTSLPAMT:
+		requirePositive("LimitOffset", p.limitOffset)
+		requirePositive("TrailingAmount", p.trailingAmount)
+	case OrderTypeTSLPPCT:
+		requirePositive("LimitOffset", p.limitOffset)
+		requirePositive("TrailingPercent", p.trailingPercent)
+	case OrderTypeTSMAMT:
+		requirePositive("TrailingAmount", p.trailingAmount)
+	case OrderTypeTSMPCT:
+		requirePositive("TrailingPercent", p.trailingPercent)
+	}
+	if p.trailingPercent.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		fe.Add("TrailingPercent", "must be less than 100")
+	}
+}
+
+// Validate checks the required fields of the order by OrderType, the applicability of OutsideRTH
+// for the market of Symbol and ExpireDate for GTD orders.
+// It returns *ValidationError which lists all invalid fields.
+func (o *SubmitOrder) Validate() error {
+	if o == nil {
+		return &ValidationError{Errors: []*FieldError{{Field: "SubmitOrder", Reason: "required"}}}
+	}
+	var fe fieldErrors
+
+	market := SymbolMarket(o.Symbol)
+	if o.Symbol == "" {
+		fe.Add("Symbol", "required")
+	} else if market == "" {
+		fe.Add("Symbol", "must have a market suffix, e.g. AAPL.US")
+	}
+
+	switch {
+	case o.OrderType == "":
+		fe.Add("OrderType", "required")
+	case !o.OrderType.isKnown():
+		fe.Add("OrderType", "unknown order type "+string(o.OrderType))
+	}
+
+	if o.Side != OrderSideBuy && o.Side != OrderSideSell {
+		fe.Add("Side", "must be Buy or Sell")
+	}
+
+	if o.SubmittedQuantity == 0 {
+		fe.Add("SubmittedQuantity", "required and must be positive")
+	}
+
+	prices := orderPrices{
+		priceField:      "SubmittedPrice",
+		price:           o.SubmittedPrice,
+		triggerPrice:    o.TriggerPrice,
+		limitOffset:     o.LimitOffset,
+		trailingAmount:  o.TrailingAmount,
+		trailingPercent: o.TrailingPercent,
+	}
+	prices.validate(o.OrderType, &fe)
+
+	switch o.OutsideRTH {
+	case "", OutsideRTHUnknown:
+	case OutsideRTHOnly, OutsideRTHAny:
+		if market != "" && market != openapi.MarketUS {
+			fe.Add("OutsideRTH", "only applicable to US market")
+		}
+	default:
+		fe.Add("OutsideRTH", "unknown value "+string(o.OutsideRTH))
+	}
+
+	switch o.TimeInForce {
+	case "":
+		fe.Add("TimeInForce", "required")
+	case TimeTypeDay, TimeTypeGTC:
+		if o.ExpireDate != nil {
+			fe.Add("ExpireDate", "only allowed when TimeInForce is GTD")
+		}
+	case TimeTypeGTD:
+		if o.ExpireDate == nil || o.ExpireDate.IsZero() {
+			fe.Add("ExpireDate", "required when TimeInForce is GTD")
+		} else if y, m, d := time.Now().Date(); o.ExpireDate.Before(time.Date(y, m, d, 0, 0, 0, 0, o.ExpireDate.Location())) {
+			fe.Add("ExpireDate", "must not be in the past")
+		}
+	default:
+		fe.Add("TimeInForce", "unknown value "+string(o.TimeInForce))
+	}
+
+	if o.ClientOrderId != "" {
+		if !validClientOrderId(o.ClientOrderId) {
+			fe.Add("ClientOrderId", "must be at most 32 letters, digits, - or _")
+		} else if len(o.remark()) > MaxRemarkLength {
+			fe.Add("Remark", "too long to carry ClientOrderId")
+		}
+	}
+
+	return fe.Err()
+}
+
+// Validate checks the fields required by every replace request.
+// The order type is not part of ReplaceOrder, use ValidateFor to also check type-specific fields.
+func (o *ReplaceOrder) Validate() error {
+	if o == nil {
+		return &ValidationError{Errors: []*FieldError{{Field: "ReplaceOrder", Reason: "required"}}}
+	}
+	var fe fieldErrors
+	o.validate(&fe)
+	return fe.Err()
+}
+
+// ValidateFor checks the replace request as Validate does, plus the required fields of orderType,
+// which is the type of the order being replaced.
+func (o *ReplaceOrder) ValidateFor(orderType OrderType) error {
+	if o == nil {
+		return o.Validate()
+	}
+	var fe fieldErrors
+	o.validate(&fe)
+	prices := orderPrices{
+		priceField:      "Price",
+		price:           o.Price,
+		triggerPrice:    o.TriggerPrice,
+		limitOffset:     o.LimitOffset,
+		trailingAmount:  o.TrailingAmount,
+		trailingPercent: o.TrailingPercent,
+	}
+	prices.validate(orderType, &fe)
+	return fe.Err()
+}
+
+func (o *ReplaceOrder) validate(fe *fieldErrors) {
+	if o.OrderId == "" {
+		fe.Add("OrderId", "required")
+	}
+	if o.Quantity == 0 {
+		fe.Add("Quantity", "required and must be positive")
+	}
+	for _, v := range []struct {
+		field string
+		value decimal.Decimal
+	}{
+		{"Price", o.Price},
+		{"TriggerPrice", o.TriggerPrice},
+		{"LimitOffset", o.LimitOffset},
+		{"TrailingAmount", o.TrailingAmount},
+		{"TrailingPercent", o.TrailingPercent},
+	} {
+		if v.value.IsNegative() {
+			fe.Add(v.field, "must not be negative")
+		}
+	}
+}
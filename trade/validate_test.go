@@ -0,0 +1,73 @@
+package trade_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+)
+
+func fieldsOf(t *testing.T, err error) []string {
+	var ve *trade.ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expect *trade.ValidationError, got %v", err)
+	}
+	fields := make([]string, 0, len(ve.Errors))
+	for _, fe := range ve.Errors {
+		fields = append(fields, fe.Field)
+	}
+	return fields
+}
+
+func TestSubmitOrderValidate(t *testing.T) {
+	order := &trade.SubmitOrder{
+		Symbol:            "AAPL.US",
+		OrderType:         trade.OrderTypeLO,
+		Side:              trade.OrderSideBuy,
+		SubmittedQuantity: 1,
+		SubmittedPrice:    decimal.NewFromInt(100),
+		OutsideRTH:        trade.OutsideRTHAny,
+		TimeInForce:       trade.TimeTypeDay,
+	}
+	assert.NoError(t, order.Validate())
+
+	lit := *order
+	lit.OrderType = trade.OrderTypeLIT
+	assert.Equal(t, []string{"TriggerPrice"}, fieldsOf(t, lit.Validate()))
+
+	trailing := *order
+	trailing.OrderType = trade.OrderTypeTSLPPCT
+	trailing.SubmittedPrice = decimal.Zero
+	trailing.TrailingPercent = decimal.NewFromInt(5)
+	assert.Equal(t, []string{"LimitOffset"}, fieldsOf(t, trailing.Validate()))
+
+	hk := *order
+	hk.Symbol = "700.HK"
+	assert.Equal(t, []string{"OutsideRTH"}, fieldsOf(t, hk.Validate()))
+
+	gtd := *order
+	gtd.TimeInForce = trade.TimeTypeGTD
+	assert.Equal(t, []string{"ExpireDate"}, fieldsOf(t, gtd.Validate()))
+	past := time.Now().AddDate(0, 0, -2)
+	gtd.ExpireDate = &past
+	assert.Equal(t, []string{"ExpireDate"}, fieldsOf(t, gtd.Validate()))
+	future := time.Now().AddDate(0, 0, 2)
+	gtd.ExpireDate = &future
+	assert.NoError(t, gtd.Validate())
+
+	empty := &trade.SubmitOrder{}
+	assert.Equal(t, []string{"Symbol", "OrderType", "Side", "SubmittedQuantity", "TimeInForce"}, fieldsOf(t, empty.Validate()))
+}
+
+func TestReplaceOrderValidate(t *testing.T) {
+	replace := &trade.ReplaceOrder{OrderId: "1", Quantity: 100}
+	assert.NoError(t, replace.Validate())
+	assert.Equal(t, []string{"Price"}, fieldsOf(t, replace.ValidateFor(trade.OrderTypeLO)))
+	assert.NoError(t, replace.ValidateFor(trade.OrderTypeMO))
+
+	assert.Equal(t, []string{"OrderId", "Quantity"}, fieldsOf(t, (&trade.ReplaceOrder{}).Validate()))
+}
@@ -0,0 +1,224 @@
+package trade
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/log"
+)
+
+// WaitCondition decides when SubmitAndWait stops waiting.
+// SubmitAndWait always stops when the order becomes terminal.
+type WaitCondition int
+
+const (
+	// WaitAccepted waits until the order is accepted, that is any status except WaitToNew.
+	// A conditional order waiting for its trigger (NotReported) is accepted.
+	WaitAccepted WaitCondition = iota
+	// WaitFilled waits until the order is fully filled.
+	WaitFilled
+	// WaitTerminal waits until the order is filled, canceled, rejected or expired.
+	WaitTerminal
+)
+
+const (
+	DefaultWaitPollInterval  = 3 * time.Second
+	DefaultWaitCancelTimeout = 10 * time.Second
+)
+
+// Satisfied reports whether an order in status s meets the condition.
+func (w WaitCondition) Satisfied(s OrderStatus) bool {
+	if s.IsTerminal() {
+		return true
+	}
+	switch w {
+	case WaitAccepted:
+		return s.IsKnown() && s != OrderWaitToNew
+	default:
+		return false
+	}
+}
+
+// WaitOptions for SubmitAndWait
+type WaitOptions struct {
+	// PollInterval is the interval of OrderDetail polling, used as fallback when no push event is received.
+	PollInterval time.Duration
+	// CancelOnAbort cancels the order if ctx is done before the condition is met.
+	CancelOnAbort bool
+	// CancelTimeout is the timeout of the cancel request sent after ctx is done.
+	CancelTimeout time.Duration
+}
+
+// WaitOption for SubmitAndWait
+type WaitOption func(*WaitOptions)
+
+// WithPollInterval to set OrderDetail polling interval
+func WithPollInterval(d time.Duration) WaitOption {
+	return func(o *WaitOptions) {
+		if d > 0 {
+			o.PollInterval = d
+		}
+	}
+}
+
+// WithCancelOnAbort to cancel the order when ctx is done before the condition is met
+func WithCancelOnAbort(timeout time.Duration) WaitOption {
+	return func(o *WaitOptions) {
+		o.CancelOnAbort = true
+		if timeout > 0 {
+			o.CancelTimeout = timeout
+		}
+	}
+}
+
+func newWaitOptions(opt ...WaitOption) *WaitOptions {
+	opts := WaitOptions{
+		PollInterval:  DefaultWaitPollInterval,
+		CancelTimeout: DefaultWaitCancelTimeout,
+	}
+	for _, o := range opt {
+		o(&opts)
+	}
+	return &opts
+}
+
+// SubmitAndWaitResult is the outcome of SubmitAndWait
+type SubmitAndWaitResult struct {
+	OrderId    string
+	Detail     OrderDetail
+	Executions []*Execution
+}
+
+// SubmitAndWait submits the order then blocks until the order meets the condition `until` or becomes terminal.
+// It wakes up on push events of the order (subscribe the `private` topic to receive them)
+// and polls OrderDetail periodically as a fallback.
+// The result contains the final OrderDetail and all executions of the order,
+// including executions of previous days queried by HistoryExecutions.
+//
+// If ctx is done first, ctx.Err() is returned with the order id in the result,
+// and the order is canceled when WithCancelOnAbort is set.
+//
+// Example:
+//
+//	conf, err := config.NewFromEnv()
+//	tctx, err := trade.NewFromCfg(conf)
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//	res, err := tctx.SubmitAndWait(ctx, &trade.SubmitOrder{
+//	  Symbol: "700.HK",
+//	  OrderType: trade.OrderTypeLO,
+//	  Side: trade.OrderSideBuy,
+//	  SubmittedPrice: decimal.NewFromInt(300),
+//	  SubmittedQuantity: 100,
+//	  TimeInForce: trade.TimeTypeDay,
+//	}, trade.WaitFilled, trade.WithCancelOnAbort(0))
+func (c *TradeContext) SubmitAndWait(ctx context.Context, params *SubmitOrder, until WaitCondition, opt ...WaitOption) (result *SubmitAndWaitResult, err error) {
+	return SubmitAndWaitWith(ctx, c, params, until, opt...)
+}
+
+// SubmitAndWaitWith is SubmitAndWait on api, e.g. a fake TradeAPI in tests
+func SubmitAndWaitWith(ctx context.Context, api TradeAPI, params *SubmitOrder, until WaitCondition, opt ...WaitOption) (result *SubmitAndWaitResult, err error) {
+	opts := newWaitOptions(opt...)
+
+	var (
+		mu      sync.Mutex
+		orderId string
+		notify  = make(chan struct{}, 1)
+	)
+	remove := api.AddTradeHandler(func(event *PushEvent) {
+		if event.Data == nil || event.TransitionErr != nil {
+			return
+		}
+		mu.Lock()
+		match := orderId != "" && event.Data.OrderId == orderId
+		mu.Unlock()
+		if match && until.Satisfied(event.Data.Status) {
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	id, err := api.SubmitOrder(ctx, params)
+	if err != nil {
+		return
+	}
+	mu.Lock()
+	orderId = id
+	mu.Unlock()
+	result = &SubmitAndWaitResult{OrderId: id}
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		detail, derr := api.OrderDetail(ctx, id)
+		if derr == nil {
+			result.Detail = detail
+			if until.Satisfied(detail.Status) {
+				break
+			}
+		} else if ctx.Err() == nil {
+			log.Warnf("submit and wait, query order %s detail error:%v", id, derr)
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			if opts.CancelOnAbort && !result.Detail.Status.IsTerminal() {
+				cctx, cancel := context.WithTimeout(context.Background(), opts.CancelTimeout)
+				if cerr := api.CancelOrder(cctx, id); cerr != nil {
+					log.Errorf("submit and wait, cancel order %s error:%v", id, cerr)
+				}
+				cancel()
+			}
+			return
+		case <-notify:
+		case <-ticker.C:
+		}
+	}
+
+	result.Executions, err = orderExecutions(ctx, api, &result.Detail)
+	return
+}
+
+// orderExecutions returns executions of the order, executions of previous days are queried by HistoryExecutions
+// when the executions of today don't add up to the executed quantity, e.g. a GTC order submitted before today
+func orderExecutions(ctx context.Context, api TradeAPI, detail *OrderDetail) (executions []*Execution, err error) {
+	executions, err = api.TodayExecutions(ctx, &GetTodayExecutions{OrderId: detail.OrderId})
+	if err != nil {
+		return
+	}
+	executed := decimal.Zero
+	seen := make(map[string]bool, len(executions))
+	for _, e := range executions {
+		executed = executed.Add(e.Quantity)
+		seen[e.TradeId] = true
+	}
+	if !executed.LessThan(detail.ExecutedQuantity) {
+		return
+	}
+	it := api.HistoryExecutionsIter(ctx, &GetHistoryExecutions{
+		Symbol:  detail.Symbol,
+		StartAt: detail.SubmittedAt,
+	})
+	var history []*Execution
+	for it.Next() {
+		if e := it.Execution(); e.OrderId == detail.OrderId && !seen[e.TradeId] {
+			history = append(history, e)
+		}
+	}
+	if err = it.Err(); err != nil {
+		return
+	}
+	// the iterator walks from the newest, executions are returned from the oldest
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	executions = append(history, executions...)
+	return
+}
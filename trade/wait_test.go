@@ -0,0 +1,170 @@
+package trade_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/longbridgeapp/assert"
+	"github.com/shopspring/decimal"
+
+	"github.com/longportapp/openapi-go/trade"
+	"github.com/longportapp/openapi-go/trade/tradetest"
+)
+
+// waitBroker scripts the order status returned by OrderDetail
+type waitBroker struct {
+	*tradetest.Fake
+	mu     sync.Mutex
+	status trade.OrderStatus
+}
+
+func newWaitBroker() *waitBroker {
+	b := &waitBroker{Fake: tradetest.New(), status: trade.OrderNewStatus}
+	b.OrderDetailFunc = func(ctx context.Context, orderId string) (trade.OrderDetail, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return trade.OrderDetail{OrderId: orderId, Symbol: "700.HK", Status: b.status}, nil
+	}
+	return b
+}
+
+func (b *waitBroker) setStatus(status trade.OrderStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status = status
+}
+
+func waitOrder() *trade.SubmitOrder {
+	return &trade.SubmitOrder{
+		Symbol: "700.HK", OrderType: trade.OrderTypeLO, Side: trade.OrderSideBuy,
+		SubmittedQuantity: 100, SubmittedPrice: decimal.NewFromInt(300), TimeInForce: trade.TimeTypeDay,
+	}
+}
+
+func TestSubmitAndWaitPush(t *testing.T) {
+	broker := newWaitBroker()
+	done := make(chan *trade.SubmitAndWaitResult)
+	go func() {
+		// polling never wakes up the wait
+		res, err := broker.SubmitAndWait(context.Background(), waitOrder(), trade.WaitFilled, trade.WithPollInterval(time.Hour))
+		assert.NoError(t, err)
+		done <- res
+	}()
+	eventually(t, func() bool { return broker.Calls("OrderDetail") == 1 })
+
+	broker.setStatus(trade.OrderFilledStatus)
+	broker.EmitOrderChanged(&trade.PushOrderChanged{OrderId: "1", Status: trade.OrderFilledStatus})
+	select {
+	case res := <-done:
+		assert.Equal(t, "1", res.OrderId)
+		assert.Equal(t, trade.OrderFilledStatus, res.Detail.Status)
+		assert.Equal(t, 2, broker.Calls("OrderDetail"))
+		assert.Equal(t, 1, broker.Calls("TodayExecutions"))
+	case <-time.After(time.Second):
+		t.Fatal("push event did not wake up SubmitAndWait")
+	}
+}
+
+func TestSubmitAndWaitPoll(t *testing.T) {
+	broker := newWaitBroker()
+	detail := broker.OrderDetailFunc
+	broker.OrderDetailFunc = func(ctx context.Context, orderId string) (trade.OrderDetail, error) {
+		// the push of the fill is missed, the third poll sees it
+		if broker.Calls("OrderDetail") == 3 {
+			broker.setStatus(trade.OrderFilledStatus)
+		}
+		return detail(ctx, orderId)
+	}
+	res, err := broker.SubmitAndWait(context.Background(), waitOrder(), trade.WaitFilled, trade.WithPollInterval(time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, trade.OrderFilledStatus, res.Detail.Status)
+	assert.Equal(t, 3, broker.Calls("OrderDetail"))
+}
+
+func TestSubmitAndWaitTerminal(t *testing.T) {
+	broker := newWaitBroker()
+	broker.setStatus(trade.OrderRejectedStatus)
+	res, err := broker.SubmitAndWait(context.Background(), waitOrder(), trade.WaitFilled, trade.WithPollInterval(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, trade.OrderRejectedStatus, res.Detail.Status)
+	assert.Equal(t, 0, broker.Calls("CancelOrder"))
+}
+
+func TestSubmitAndWaitAbort(t *testing.T) {
+	broker := newWaitBroker()
+	var canceled []string
+	broker.CancelOrderFunc = func(ctx context.Context, orderId string) error {
+		// the cancel request is not bound to the aborted ctx
+		assert.NoError(t, ctx.Err())
+		canceled = append(canceled, orderId)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	res, err := broker.SubmitAndWait(ctx, waitOrder(), trade.WaitFilled,
+		trade.WithPollInterval(time.Millisecond), trade.WithCancelOnAbort(time.Second))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, "1", res.OrderId)
+	assert.Equal(t, []string{"1"}, canceled)
+
+	// nothing is canceled without WithCancelOnAbort
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = broker.SubmitAndWait(ctx, waitOrder(), trade.WaitFilled, trade.WithPollInterval(time.Millisecond))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, len(canceled))
+}
+
+func TestSubmitAndWaitHistoryExecutions(t *testing.T) {
+	now := time.Now()
+	submittedAt := now.Add(-48 * time.Hour)
+	execution := func(orderId, tradeId string, at time.Time) *trade.Execution {
+		return &trade.Execution{OrderId: orderId, TradeId: tradeId, Symbol: "700.HK", TradeDoneAt: at, Quantity: decimal.NewFromInt(100)}
+	}
+	history := []*trade.Execution{
+		execution("1", "t3", now),
+		execution("2", "t2", now.Add(-24*time.Hour)),
+		execution("1", "t1", now.Add(-24*time.Hour)),
+	}
+
+	broker := tradetest.New()
+	broker.OrderDetailFunc = func(ctx context.Context, orderId string) (trade.OrderDetail, error) {
+		return trade.OrderDetail{
+			OrderId: orderId, Symbol: "700.HK", Status: trade.OrderFilledStatus,
+			ExecutedQuantity: decimal.NewFromInt(200), SubmittedAt: submittedAt,
+		}, nil
+	}
+	broker.TodayExecutionsFunc = func(ctx context.Context, params *trade.GetTodayExecutions) ([]*trade.Execution, error) {
+		return []*trade.Execution{execution("1", "t3", now)}, nil
+	}
+	broker.HistoryExecutionsFunc = func(ctx context.Context, params *trade.GetHistoryExecutions) ([]*trade.Execution, error) {
+		assert.Equal(t, "700.HK", params.Symbol)
+		var list []*trade.Execution
+		for _, e := range history {
+			if !e.TradeDoneAt.Before(params.StartAt) && !e.TradeDoneAt.After(params.EndAt) {
+				list = append(list, e)
+			}
+		}
+		return list, nil
+	}
+
+	// the GTC order submitted two days ago was filled yesterday and today
+	res, err := broker.SubmitAndWait(context.Background(), waitOrder(), trade.WaitFilled)
+	assert.NoError(t, err)
+	var trades []string
+	for _, e := range res.Executions {
+		trades = append(trades, e.TradeId)
+	}
+	assert.Equal(t, []string{"t1", "t3"}, trades)
+
+	// executions of today add up to the executed quantity
+	broker.TodayExecutionsFunc = func(ctx context.Context, params *trade.GetTodayExecutions) ([]*trade.Execution, error) {
+		return []*trade.Execution{execution("1", "t1", now), execution("1", "t3", now)}, nil
+	}
+	calls := broker.Calls("HistoryExecutions")
+	_, err = broker.SubmitAndWait(context.Background(), waitOrder(), trade.WaitFilled)
+	assert.NoError(t, err)
+	assert.Equal(t, calls, broker.Calls("HistoryExecutions"))
+}